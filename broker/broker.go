@@ -9,6 +9,7 @@ type Broker interface {
 	Connect() error
 	Disconnect() error
 	Publish(topic string, m *Message, opts ...PublishOption) error
+	// Subscribe to a topic. The topic may contain wildcards, see MatchTopic.
 	Subscribe(topic string, h Handler, opts ...SubscribeOption) (Subscriber, error)
 	String() string
 }
@@ -21,6 +22,9 @@ type ErrorHandler func(*Message, error)
 type Message struct {
 	Header map[string]string
 	Body   []byte
+	// Topic the message was published to, set when it's delivered
+	// so subscribers to a wildcard topic know which topic matched
+	Topic string `json:"-"`
 }
 
 // Subscriber is a convenience return type for the Subscribe method
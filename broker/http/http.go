@@ -19,11 +19,15 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/broker/schedule"
 	"github.com/micro/go-micro/v3/codec/json"
 	merr "github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/logger"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/registry/cache"
 	"github.com/micro/go-micro/v3/registry/mdns"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
 	maddr "github.com/micro/go-micro/v3/util/addr"
 	mnet "github.com/micro/go-micro/v3/util/net"
 	mls "github.com/micro/go-micro/v3/util/tls"
@@ -46,9 +50,18 @@ type httpBroker struct {
 	running     bool
 	exit        chan chan error
 
-	// offline message inbox
-	mtx   sync.RWMutex
-	inbox map[string][][]byte
+	// messages which haven't been delivered yet
+	spool *Spool
+
+	// topics being flushed from the spool, and whether
+	// they should be flushed again once it's done
+	mtx      sync.Mutex
+	flushing map[string]bool
+	// lock of each topic held while its backlog is drained
+	draining map[string]*sync.Mutex
+
+	// messages to publish later
+	schedule *schedule.Schedule
 }
 
 type httpSubscriber struct {
@@ -67,6 +80,12 @@ var (
 	broadcastVersion = "ff.http.broadcast"
 	registerTTL      = time.Minute
 	registerInterval = time.Second * 30
+	// how often the spool is flushed to subscribers which have come back
+	spoolInterval = time.Second * 5
+	// maximum number of messages kept per topic by the default spool
+	spoolLimit = 64
+	// number of messages read from the spool at a time
+	spoolBatch uint = 8
 )
 
 func init() {
@@ -130,9 +149,15 @@ func newHttpBroker(opts ...broker.Option) broker.Broker {
 		subscribers: make(map[string][]*httpSubscriber),
 		exit:        make(chan chan error),
 		mux:         http.NewServeMux(),
-		inbox:       make(map[string][][]byte),
+		flushing:    make(map[string]bool),
+		draining:    make(map[string]*sync.Mutex),
 	}
 
+	// spool messages in memory up to a limit, unless a store is given
+	h.spool = NewSpool(memory.NewStore())
+	h.spool.limit = spoolLimit
+	h.setSpool()
+
 	// specify the message handler
 	h.mux.Handle(DefaultPath, h)
 
@@ -149,6 +174,19 @@ func newHttpBroker(opts ...broker.Option) broker.Broker {
 	return h
 }
 
+// setSpool configures the spool from the options
+func (h *httpBroker) setSpool() {
+	if h.opts.Context == nil {
+		return
+	}
+	if s, ok := h.opts.Context.Value(spoolKey{}).(store.Store); ok && s != h.spool.store {
+		h.spool = NewSpool(s)
+	}
+	if l, ok := h.opts.Context.Value(spoolLimitKey{}).(int); ok {
+		h.spool.limit = l
+	}
+}
+
 func (h *httpSubscriber) Options() broker.SubscribeOptions {
 	return h.opts
 }
@@ -161,49 +199,6 @@ func (h *httpSubscriber) Unsubscribe() error {
 	return h.hb.unsubscribe(h)
 }
 
-func (h *httpBroker) saveMessage(topic string, msg []byte) {
-	h.mtx.Lock()
-	defer h.mtx.Unlock()
-
-	// get messages
-	c := h.inbox[topic]
-
-	// save message
-	c = append(c, msg)
-
-	// max length 64
-	if len(c) > 64 {
-		c = c[:64]
-	}
-
-	// save inbox
-	h.inbox[topic] = c
-}
-
-func (h *httpBroker) getMessage(topic string, num int) [][]byte {
-	h.mtx.Lock()
-	defer h.mtx.Unlock()
-
-	// get messages
-	c, ok := h.inbox[topic]
-	if !ok {
-		return nil
-	}
-
-	// more message than requests
-	if len(c) >= num {
-		msg := c[:num]
-		h.inbox[topic] = c[num:]
-		return msg
-	}
-
-	// reset inbox
-	h.inbox[topic] = nil
-
-	// return all messages
-	return c
-}
-
 func (h *httpBroker) subscribe(s *httpSubscriber) error {
 	h.Lock()
 	defer h.Unlock()
@@ -244,8 +239,14 @@ func (h *httpBroker) run(l net.Listener) {
 	t := time.NewTicker(registerInterval)
 	defer t.Stop()
 
+	st := time.NewTicker(spoolInterval)
+	defer st.Stop()
+
 	for {
 		select {
+		// redeliver the backlog to subscribers which have come back
+		case <-st.C:
+			go h.flushAll()
 		// heartbeat for each subscriber
 		case <-t.C:
 			h.RLock()
@@ -311,14 +312,21 @@ func (h *httpBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var subs []broker.Handler
 
 	h.RLock()
-	for _, subscriber := range h.subscribers[topic] {
-		if id != subscriber.id {
+	for pattern, subscribers := range h.subscribers {
+		if !broker.MatchTopic(pattern, topic) {
 			continue
 		}
-		subs = append(subs, subscriber.fn)
+		for _, subscriber := range subscribers {
+			if id != subscriber.id {
+				continue
+			}
+			subs = append(subs, subscriber.fn)
+		}
 	}
 	h.RUnlock()
 
+	msg.Topic = topic
+
 	// execute the handler
 	for _, fn := range subs {
 		fn(msg)
@@ -404,6 +412,17 @@ func (h *httpBroker) Connect() error {
 	// set cache
 	h.r = cache.New(reg)
 
+	// publish scheduled messages, including any stored before a restart
+	st := h.opts.Store
+	if st == nil {
+		st = memory.NewStore()
+	}
+	h.schedule = schedule.New(st, h.publish)
+	h.schedule.Start()
+
+	// redeliver any backlog spooled before a restart
+	go h.flushAll()
+
 	// set running
 	h.running = true
 	return nil
@@ -426,6 +445,9 @@ func (h *httpBroker) Disconnect() error {
 		rc.Stop()
 	}
 
+	// stop publishing scheduled messages
+	h.schedule.Stop()
+
 	// exit and return err
 	ch := make(chan error)
 	h.exit <- ch
@@ -459,6 +481,9 @@ func (h *httpBroker) Init(opts ...broker.Option) error {
 		h.id = "go.micro.http.broker-" + uuid.New().String()
 	}
 
+	// reconfigure the spool
+	h.setSpool()
+
 	// get registry
 	reg := h.opts.Registry
 	if reg == nil {
@@ -488,6 +513,38 @@ func (h *httpBroker) Options() broker.Options {
 }
 
 func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// carry the key in the header so it's kept if the message is scheduled
+	if len(options.Key) > 0 {
+		keyed := &broker.Message{Header: map[string]string{broker.KeyHeader: options.Key}, Body: msg.Body}
+		for k, v := range msg.Header {
+			if k != broker.KeyHeader {
+				keyed.Header[k] = v
+			}
+		}
+		msg = keyed
+	}
+
+	// store the message until it's due
+	if options.At.After(time.Now()) {
+		h.RLock()
+		sched := h.schedule
+		h.RUnlock()
+		if sched == nil {
+			return errors.New("not connected")
+		}
+		return sched.Add(topic, msg, options.At)
+	}
+
+	return h.publish(topic, msg)
+}
+
+// publish the message now
+func (h *httpBroker) publish(topic string, msg *broker.Message) error {
 	// create the message first
 	m := &broker.Message{
 		Header: make(map[string]string),
@@ -500,121 +557,274 @@ func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.P
 
 	m.Header["Micro-Topic"] = topic
 
-	// encode the message
-	b, err := h.opts.Codec.Marshal(m)
+	// spool the message until it's delivered
+	err := h.spool.Add(topic, m)
+	if err == ErrSpoolFull {
+		// deliver the backlog to make room for the message
+		if err := h.drain(topic); err != nil {
+			return err
+		}
+		err = h.spool.Add(topic, m)
+	}
 	if err != nil {
 		return err
 	}
 
-	// save the message
-	h.saveMessage(topic, b)
+	// do the rest async, the message is kept until there are subscribers
+	go h.flush(topic)
 
-	// now attempt to get the service
-	h.RLock()
-	s, err := h.r.GetService(serviceName)
+	return nil
+}
+
+// flushAll flushes the backlog of every topic in the spool
+func (h *httpBroker) flushAll() {
+	topics, err := h.spool.Topics()
 	if err != nil {
-		h.RUnlock()
-		return err
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error listing spooled topics: %v", err)
+		}
+		return
 	}
-	h.RUnlock()
+	for _, topic := range topics {
+		h.flush(topic)
+	}
+}
 
-	pub := func(node *registry.Node, t string, b []byte) error {
-		scheme := "http"
+// flush delivers the backlog of the topic in order. Only one flush of a topic
+// runs at a time; one requested meanwhile runs again once it's done.
+func (h *httpBroker) flush(topic string) {
+	h.mtx.Lock()
+	if _, ok := h.flushing[topic]; ok {
+		h.flushing[topic] = true
+		h.mtx.Unlock()
+		return
+	}
+	h.flushing[topic] = false
+	h.mtx.Unlock()
 
-		// check if secure is added in metadata
-		if node.Metadata["secure"] == "true" {
-			scheme = "https"
+	for {
+		if err := h.drain(topic); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error flushing spooled messages on %v: %v", topic, err)
 		}
 
-		vals := url.Values{}
-		vals.Add("id", node.Id)
+		h.mtx.Lock()
+		if !h.flushing[topic] {
+			delete(h.flushing, topic)
+			h.mtx.Unlock()
+			return
+		}
+		h.flushing[topic] = false
+		h.mtx.Unlock()
+	}
+}
+
+// drain delivers the backlog of the topic to the queues subscribed. The backlog
+// kept for each queue is sent first, so each gets the messages in order, and
+// those with the same key in the order they were published. Once a
+// message of the topic has been sent to the queues it's kept only for those which
+// failed to take it, so a queue which is down doesn't hold up the others.
+func (h *httpBroker) drain(topic string) error {
+	// only one drain of a topic runs at a time
+	h.mtx.Lock()
+	l, ok := h.draining[topic]
+	if !ok {
+		l = new(sync.Mutex)
+		h.draining[topic] = l
+	}
+	h.mtx.Unlock()
+
+	l.Lock()
+	defer l.Unlock()
+
+	h.RLock()
+	s, err := h.r.GetService(serviceName)
+	h.RUnlock()
+	if err == registry.ErrNotFound {
+		// nobody is subscribed yet
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	subs := subscribed(s, topic)
+	if len(subs) == 0 {
+		return nil
+	}
 
-		uri := fmt.Sprintf("%s://%s%s?%s", scheme, node.Address, DefaultPath, vals.Encode())
-		r, err := h.c.Post(uri, "application/json", bytes.NewReader(b))
+	// the queues which still have a backlog
+	backlog := make(map[string]bool)
+	for queue, nodes := range subs {
+		ok, err := h.drainQueue(topic, queue, nodes)
 		if err != nil {
 			return err
 		}
-
-		// discard response body
-		io.Copy(ioutil.Discard, r.Body)
-		r.Body.Close()
-		return nil
+		backlog[queue] = !ok
 	}
 
-	srv := func(s []*registry.Service, b []byte) {
-		for _, service := range s {
-			var nodes []*registry.Node
+	for {
+		recs, err := h.spool.next(topic, "", spoolBatch)
+		if err != nil {
+			return err
+		}
 
-			for _, node := range service.Nodes {
-				// only use nodes tagged with broker http
-				if node.Metadata["broker"] != "http" {
-					continue
-				}
+		for _, r := range recs {
+			b, key, err := h.encode(r)
+			if err != nil {
+				return err
+			}
 
-				// look for nodes for the topic
-				if node.Metadata["topic"] != topic {
+			for queue, nodes := range subs {
+				if b == nil || (!backlog[queue] && h.deliver(nodes, queue, topic, key, b)) {
 					continue
 				}
-
-				nodes = append(nodes, node)
+				// keep the message and those after it for the queue
+				backlog[queue] = true
+				if err := h.spool.keep(topic, queue, r.Value); err != nil {
+					return err
+				}
 			}
 
-			// only process if we have nodes
-			if len(nodes) == 0 {
-				continue
+			if err := h.spool.remove(r.Key); err != nil {
+				return err
 			}
+		}
 
-			switch service.Version {
-			// broadcast version means broadcast to all nodes
-			case broadcastVersion:
-				var success bool
+		if uint(len(recs)) < spoolBatch {
+			return nil
+		}
+	}
+}
 
-				// publish to all nodes
-				for _, node := range nodes {
-					// publish async
-					if err := pub(node, topic, b); err == nil {
-						success = true
-					}
-				}
+// drainQueue delivers the backlog kept for the queue to its nodes, returning
+// whether all of it was delivered
+func (h *httpBroker) drainQueue(topic, queue string, nodes []*registry.Node) (bool, error) {
+	for {
+		recs, err := h.spool.next(topic, queue, spoolBatch)
+		if err != nil {
+			return false, err
+		}
 
-				// save if it failed to publish at least once
-				if !success {
-					h.saveMessage(topic, b)
-				}
-			default:
-				// select node to publish to
-				node := nodes[rand.Int()%len(nodes)]
-
-				// publish async to one node
-				if err := pub(node, topic, b); err != nil {
-					// if failed save it
-					h.saveMessage(topic, b)
-				}
+		for _, r := range recs {
+			b, key, err := h.encode(r)
+			if err != nil {
+				return false, err
+			}
+			if b != nil && !h.deliver(nodes, queue, topic, key, b) {
+				return false, nil
+			}
+			if err := h.spool.remove(r.Key); err != nil {
+				return false, err
 			}
 		}
+
+		if uint(len(recs)) < spoolBatch {
+			return true, nil
+		}
 	}
+}
 
-	// do the rest async
-	go func() {
-		// get a third of the backlog
-		messages := h.getMessage(topic, 8)
-		delay := (len(messages) > 1)
-
-		// publish all the messages
-		for _, msg := range messages {
-			// serialize here
-			srv(s, msg)
-
-			// sending a backlog of messages
-			if delay {
-				time.Sleep(time.Millisecond * 100)
+// encode a spooled message to be sent to subscribers, returning its ordering key.
+// A message which can't be decoded never will be, so nil is returned for it to be
+// dropped.
+func (h *httpBroker) encode(r *store.Record) ([]byte, string, error) {
+	m, err := decode(r)
+	if err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Dropping invalid spooled message %v: %v", r.Key, err)
+		}
+		return nil, "", nil
+	}
+	b, err := h.opts.Codec.Marshal(&broker.Message{Header: m.Header, Body: m.Body})
+	return b, m.Header[broker.KeyHeader], err
+}
+
+// subscribed returns the nodes subscribed to the topic, by the version of
+// the service they're registered with, which is the queue they're in
+func subscribed(s []*registry.Service, topic string) map[string][]*registry.Node {
+	subs := make(map[string][]*registry.Node)
+	for _, service := range s {
+		if nodes := topicNodes(service, topic); len(nodes) > 0 {
+			subs[service.Version] = append(subs[service.Version], nodes...)
+		}
+	}
+	return subs
+}
+
+// deliver an encoded message to every broadcast subscriber, or to one member of
+// a queue, which is the owner of the key of a keyed message. It returns whether
+// the message was taken.
+func (h *httpBroker) deliver(nodes []*registry.Node, queue, topic, key string, b []byte) bool {
+	// broadcast version means broadcast to all nodes
+	if queue == broadcastVersion {
+		// publish to all nodes, succeeding if any get it
+		var success bool
+		for _, node := range nodes {
+			if err := h.post(node, topic, b); err == nil {
+				success = true
 			}
 		}
-	}()
+		return success
+	}
+
+	// messages with the same key go to the same member, in the order of the backlog
+	if len(key) > 0 {
+		ids := make([]string, len(nodes))
+		for i, node := range nodes {
+			ids[i] = node.Id
+		}
+		return h.post(nodes[broker.Partition(key, ids)], topic, b) == nil
+	}
+
+	// select node to publish to
+	node := nodes[rand.Int()%len(nodes)]
+	return h.post(node, topic, b) == nil
+}
 
+// post an encoded message to a node
+func (h *httpBroker) post(node *registry.Node, t string, b []byte) error {
+	scheme := "http"
+
+	// check if secure is added in metadata
+	if node.Metadata["secure"] == "true" {
+		scheme = "https"
+	}
+
+	vals := url.Values{}
+	vals.Add("id", node.Id)
+
+	uri := fmt.Sprintf("%s://%s%s?%s", scheme, node.Address, DefaultPath, vals.Encode())
+	r, err := h.c.Post(uri, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	// discard response body
+	io.Copy(ioutil.Discard, r.Body)
+	r.Body.Close()
 	return nil
 }
 
+// topicNodes returns the nodes of the service subscribed to the topic
+func topicNodes(service *registry.Service, topic string) []*registry.Node {
+	var nodes []*registry.Node
+
+	for _, node := range service.Nodes {
+		// only use nodes tagged with broker http
+		if node.Metadata["broker"] != "http" {
+			continue
+		}
+
+		// look for nodes subscribed to the topic, including by wildcard
+		if !broker.MatchTopic(node.Metadata["topic"], topic) {
+			continue
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
 func (h *httpBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	var err error
 	var host, port string
@@ -1,14 +1,19 @@
 package http
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/avro"
+	"github.com/micro/go-micro/v3/codec/msgpack"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/registry/memory"
+	memstore "github.com/micro/go-micro/v3/store/memory"
 )
 
 var (
@@ -231,6 +236,105 @@ func TestBroker(t *testing.T) {
 	}
 }
 
+func TestCodecBroker(t *testing.T) {
+	if err := avro.Register(new(broker.Message), avro.MessageSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []codec.Marshaler{avro.Marshaler{}, msgpack.Marshaler{}} {
+		t.Run(c.String(), func(t *testing.T) {
+			m := newTestRegistry()
+			b := NewBroker(broker.Registry(m), broker.Codec(c))
+
+			if err := b.Connect(); err != nil {
+				t.Fatalf("Unexpected connect error: %v", err)
+			}
+
+			msg := &broker.Message{
+				Header: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: []byte(`{"message": "Hello World"}`),
+			}
+
+			done := make(chan *broker.Message, 1)
+			topic := uuid.New().String()
+
+			sub, err := b.Subscribe(topic, func(m *broker.Message) error {
+				done <- m
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Unexpected subscribe error: %v", err)
+			}
+
+			if err := b.Publish(topic, msg); err != nil {
+				t.Fatalf("Unexpected publish error: %v", err)
+			}
+
+			select {
+			case got := <-done:
+				if string(got.Body) != string(msg.Body) {
+					t.Fatalf("Unexpected msg %s, expected %s", string(got.Body), string(msg.Body))
+				}
+				if got.Header["Content-Type"] != "application/json" {
+					t.Fatalf("Unexpected header %v", got.Header)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatal("Timed out waiting for message")
+			}
+
+			sub.Unsubscribe()
+
+			if err := b.Disconnect(); err != nil {
+				t.Fatalf("Unexpected disconnect error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWildcardBroker(t *testing.T) {
+	m := newTestRegistry()
+	b := NewBroker(broker.Registry(m))
+
+	if err := b.Init(); err != nil {
+		t.Fatalf("Unexpected init error: %v", err)
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+
+	topics := make(chan string, 1)
+
+	sub, err := b.Subscribe("orders.>", func(m *broker.Message) error {
+		topics <- m.Topic
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	if err := b.Publish("orders.eu.created", &broker.Message{Body: []byte(`{}`)}); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	select {
+	case topic := <-topics:
+		if topic != "orders.eu.created" {
+			t.Fatalf("Expected the message topic to be orders.eu.created, got %s", topic)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Message was not received")
+	}
+
+	sub.Unsubscribe()
+
+	if err := b.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error: %v", err)
+	}
+}
+
 func TestConcurrentSubBroker(t *testing.T) {
 	m := newTestRegistry()
 	b := NewBroker(broker.Registry(m))
@@ -375,3 +479,329 @@ func BenchmarkPub64(b *testing.B) {
 func BenchmarkPub128(b *testing.B) {
 	pub(b, 128)
 }
+
+func TestKeyedBroker(t *testing.T) {
+	m := newTestRegistry()
+
+	type delivery struct {
+		broker int
+		key    string
+		body   string
+	}
+	deliveries := make(chan delivery, 100)
+
+	// two brokers share a queue
+	var brokers []broker.Broker
+	for i := 0; i < 2; i++ {
+		i := i
+		b := NewBroker(broker.Registry(m))
+		if err := b.Connect(); err != nil {
+			t.Fatalf("Unexpected connect error: %v", err)
+		}
+		defer b.Disconnect()
+
+		sub, err := b.Subscribe("orders", func(m *broker.Message) error {
+			deliveries <- delivery{i, m.Header[broker.KeyHeader], string(m.Body)}
+			return nil
+		}, broker.Queue("shared"))
+		if err != nil {
+			t.Fatalf("Unexpected subscribe error: %v", err)
+		}
+		defer sub.Unsubscribe()
+
+		brokers = append(brokers, b)
+	}
+
+	// wait for the subscribers to be visible to the publisher
+	time.Sleep(time.Millisecond * 100)
+
+	for i := 0; i < 40; i++ {
+		msg := &broker.Message{Body: []byte(fmt.Sprintf("%02d", i))}
+		if err := brokers[0].Publish("orders", msg, broker.PublishKey(fmt.Sprintf("order-%d", i%4))); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	owners := map[string]int{}
+	last := map[string]string{}
+	for i := 0; i < 40; i++ {
+		select {
+		case d := <-deliveries:
+			if o, ok := owners[d.key]; ok && o != d.broker {
+				t.Fatalf("Expected %v to be delivered to broker %v, got %v", d.key, o, d.broker)
+			}
+			owners[d.key] = d.broker
+			if d.body < last[d.key] {
+				t.Fatalf("Expected the messages of %v in order, got %v after %v", d.key, d.body, last[d.key])
+			}
+			last[d.key] = d.body
+		case <-time.After(time.Second * 5):
+			t.Fatal("Message was not received")
+		}
+	}
+}
+
+func TestSpoolBroker(t *testing.T) {
+	m := newTestRegistry()
+	st := memstore.NewStore()
+
+	pub := NewBroker(broker.Registry(m), SpoolStore(st))
+	if err := pub.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+
+	// register the broker service without a subscriber to the topic
+	other, err := pub.Subscribe("other", func(m *broker.Message) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer other.Unsubscribe()
+
+	for i := 0; i < 3; i++ {
+		if err := pub.Publish("orders", &broker.Message{Body: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	spool := NewSpool(st)
+	if msgs, err := spool.List("orders"); err != nil || len(msgs) != 3 {
+		t.Fatalf("Expected 3 spooled messages, got %v %v", len(msgs), err)
+	}
+
+	// the backlog outlives the broker
+	if err := pub.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error: %v", err)
+	}
+
+	received := make(chan string, 3)
+	sb := NewBroker(broker.Registry(m))
+	if err := sb.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer sb.Disconnect()
+
+	sub, err := sb.Subscribe("orders", func(m *broker.Message) error {
+		received <- string(m.Body)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// a broker on the same spool redelivers the backlog in order
+	pub = NewBroker(broker.Registry(m), SpoolStore(st))
+	if err := pub.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer pub.Disconnect()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case body := <-received:
+			if body != fmt.Sprint(i) {
+				t.Fatalf("Expected message %v, got %v", i, body)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Spooled message was not redelivered")
+		}
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if msgs, err := spool.List("orders"); err != nil || len(msgs) != 0 {
+		t.Fatalf("Expected the spool to be empty, got %v %v", len(msgs), err)
+	}
+}
+
+func TestSpoolDelivery(t *testing.T) {
+	m := newTestRegistry()
+	st := memstore.NewStore()
+	spool := NewSpool(st)
+
+	b := NewBroker(broker.Registry(m), SpoolStore(st))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	// a message is spooled once when there are no subscribers
+	if err := b.Publish("orders", &broker.Message{Body: []byte("spooled")}); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+	if msgs, err := spool.List("orders"); err != nil || len(msgs) != 1 {
+		t.Fatalf("Expected 1 spooled message, got %v %v", len(msgs), err)
+	}
+	if err := spool.Purge("orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a queue whose only member is down
+	dead := &registry.Service{
+		Name:    serviceName,
+		Version: "dead",
+		Nodes: []*registry.Node{{
+			Id:       "orders-dead",
+			Address:  "127.0.0.1:1",
+			Metadata: map[string]string{"broker": "http", "topic": "orders"},
+		}},
+	}
+	if err := m.Register(dead); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan string, 10)
+	sub, err := b.Subscribe("orders", func(m *broker.Message) error {
+		received <- string(m.Body)
+		return nil
+	}, broker.Queue("live"))
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	expect := func(bodies ...string) {
+		t.Helper()
+		for _, body := range bodies {
+			select {
+			case got := <-received:
+				if got != body {
+					t.Fatalf("Expected message %v, got %v", body, got)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatalf("Message %v was not received", body)
+			}
+		}
+		select {
+		case got := <-received:
+			t.Fatalf("Unexpected message %v", got)
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+
+	// the live queue gets each message once, while they're kept for the dead one
+	for i := 0; i < 3; i++ {
+		if err := b.Publish("orders", &broker.Message{Body: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+		expect(fmt.Sprint(i))
+	}
+	if msgs, err := spool.List("orders"); err != nil || len(msgs) != 0 {
+		t.Fatalf("Expected no messages spooled for the topic, got %v %v", len(msgs), err)
+	}
+	if msgs, err := spool.ListQueue("orders", "dead"); err != nil || len(msgs) != 3 {
+		t.Fatalf("Expected 3 spooled messages for the dead queue, got %v %v", len(msgs), err)
+	}
+}
+
+func TestSpoolDeadQueue(t *testing.T) {
+	m := newTestRegistry()
+
+	b := NewBroker(broker.Registry(m))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	// a queue whose only member is down
+	dead := &registry.Service{
+		Name:    serviceName,
+		Version: "dead",
+		Nodes: []*registry.Node{{
+			Id:       "orders-dead",
+			Address:  "127.0.0.1:1",
+			Metadata: map[string]string{"broker": "http", "topic": "orders"},
+		}},
+	}
+	if err := m.Register(dead); err != nil {
+		t.Fatal(err)
+	}
+
+	var mtx sync.Mutex
+	received := map[string]bool{}
+	sub, err := b.Subscribe("orders", func(m *broker.Message) error {
+		mtx.Lock()
+		received[string(m.Body)] = true
+		mtx.Unlock()
+		return nil
+	}, broker.Queue("live"))
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// the live queue gets every message, beyond the limit of the spool
+	n := spoolLimit * 2
+	for i := 0; i < n; i++ {
+		if err := b.Publish("orders", &broker.Message{Body: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	var got int
+	for i := 0; i < 50 && got < n; i++ {
+		time.Sleep(time.Millisecond * 100)
+		mtx.Lock()
+		got = len(received)
+		mtx.Unlock()
+	}
+	if got != n {
+		t.Fatalf("Expected %d messages to be received, got %d", n, got)
+	}
+
+	// the dead queue keeps the latest messages up to the limit
+	msgs, err := b.(*httpBroker).spool.ListQueue("orders", "dead")
+	if err != nil || len(msgs) != spoolLimit {
+		t.Fatalf("Expected %d spooled messages for the dead queue, got %v %v", spoolLimit, len(msgs), err)
+	}
+	if string(msgs[0].Body) != fmt.Sprint(n-spoolLimit) {
+		t.Fatalf("Expected the oldest messages to be dropped, got %s first", msgs[0].Body)
+	}
+}
+
+func TestSpoolKeyed(t *testing.T) {
+	m := newTestRegistry()
+	st := memstore.NewStore()
+
+	b := NewBroker(broker.Registry(m), SpoolStore(st))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	// keyed messages are spooled like others when nobody is subscribed
+	for i := 0; i < 3; i++ {
+		if err := b.Publish("orders", &broker.Message{Body: []byte(fmt.Sprint(i))}, broker.PublishKey("order-1")); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+	if msgs, err := NewSpool(st).List("orders"); err != nil || len(msgs) != 3 {
+		t.Fatalf("Expected 3 spooled messages, got %v %v", len(msgs), err)
+	}
+
+	received := make(chan string, 3)
+	sub, err := b.Subscribe("orders", func(m *broker.Message) error {
+		if m.Header[broker.KeyHeader] != "order-1" {
+			t.Errorf("Expected the key to be kept, got %v", m.Header[broker.KeyHeader])
+		}
+		received <- string(m.Body)
+		return nil
+	}, broker.Queue("shared"))
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// and redelivered in order once there is a subscriber
+	b.(*httpBroker).flush("orders")
+	for i := 0; i < 3; i++ {
+		select {
+		case body := <-received:
+			if body != fmt.Sprint(i) {
+				t.Fatalf("Expected message %v, got %v", i, body)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Spooled message was not redelivered")
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/store"
 )
 
 // Handle registers the handler for the given pattern.
@@ -21,3 +22,30 @@ func Handle(pattern string, handler http.Handler) broker.Option {
 		o.Context = context.WithValue(o.Context, "http_handlers", handlers)
 	}
 }
+
+type spoolKey struct{}
+type spoolLimitKey struct{}
+
+// SpoolStore keeps the messages which haven't been delivered in the store given,
+// rather than in memory, so they can be redelivered after a restart. The
+// backlog can be inspected and purged by opening a Spool on the same store.
+func SpoolStore(s store.Store) broker.Option {
+	return setOption(spoolKey{}, s)
+}
+
+// SpoolLimit sets the maximum number of messages spooled per topic, and per queue
+// which failed to take them. Publishing to a topic which is still at the limit once
+// its backlog has been delivered returns ErrSpoolFull, while the oldest messages of
+// a queue at the limit are dropped. Zero is unlimited.
+func SpoolLimit(n int) broker.Option {
+	return setOption(spoolLimitKey{}, n)
+}
+
+func setOption(k, v interface{}) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
@@ -0,0 +1,260 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// prefix of the keys spooled messages are stored under
+	spoolPrefix = "spool/"
+	// segment of the keys of the messages not yet delivered to any queue,
+	// which can't be that of an escaped queue name
+	spoolShared = "*"
+
+	// ErrSpoolFull is returned when a message is added to a backlog at the limit
+	ErrSpoolFull = errors.New("spool is full")
+)
+
+// Spool holds the messages published by the broker until they're delivered. The
+// messages are kept in a store, so with a persistent store they survive restarts
+// and are redelivered once subscribers come back. Operators can open a spool on
+// the store a broker uses to inspect or purge its backlog.
+//
+// A message is first added to the backlog of its topic. Once it has been sent to
+// the queues subscribed, it's kept only for the queues which failed to take it,
+// in a backlog of each, so a queue which is down doesn't hold up the others.
+type Spool struct {
+	store store.Store
+	// maximum number of messages kept per topic and per queue, if above zero
+	limit int
+
+	sync.Mutex
+	// time of the last message added, so keys are unique and ordered
+	last int64
+}
+
+// spooled message as it's stored
+type spooled struct {
+	Header map[string]string
+	Body   []byte
+}
+
+// NewSpool returns a spool which keeps messages in the store
+func NewSpool(s store.Store) *Spool {
+	return &Spool{store: s}
+}
+
+// topicPrefix is the prefix of the keys of the messages of a topic. The topic
+// is escaped so topics which share a prefix aren't confused.
+func topicPrefix(topic string) string {
+	return spoolPrefix + url.PathEscape(topic) + "/"
+}
+
+// queuePrefix is the prefix of the keys of the backlog of the queue subscribed
+// to the topic, or of the topic itself if the queue is empty
+func queuePrefix(topic, queue string) string {
+	if len(queue) == 0 {
+		return topicPrefix(topic) + spoolShared + "/"
+	}
+	return topicPrefix(topic) + url.PathEscape(queue) + "/"
+}
+
+// Add a message to the backlog of the topic. ErrSpoolFull is returned if the
+// backlog of the topic is at the limit of the spool.
+func (s *Spool) Add(topic string, msg *broker.Message) error {
+	b, err := json.Marshal(&spooled{Header: msg.Header, Body: msg.Body})
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	prefix := queuePrefix(topic, "")
+
+	if s.limit > 0 {
+		keys, err := s.store.List(store.ListPrefix(prefix), store.ListLimit(uint(s.limit)))
+		if err != nil {
+			return err
+		}
+		if len(keys) >= s.limit {
+			return ErrSpoolFull
+		}
+	}
+
+	return s.write(prefix, b)
+}
+
+// keep a message of the topic for a queue which failed to take it. The oldest
+// messages of the queue are dropped if its backlog is at the limit of the spool.
+func (s *Spool) keep(topic, queue string, b []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	prefix := queuePrefix(topic, queue)
+
+	if s.limit > 0 {
+		keys, err := s.store.List(store.ListPrefix(prefix))
+		if err != nil {
+			return err
+		}
+		for i := 0; i <= len(keys)-s.limit; i++ {
+			if err := s.store.Delete(keys[i]); err != nil {
+				return err
+			}
+		}
+		if n := len(keys) - s.limit + 1; n > 0 && logger.V(logger.WarnLevel, logger.DefaultLogger) {
+			logger.Warnf("Dropped %d spooled messages of queue %v on %v", n, queue, topic)
+		}
+	}
+
+	return s.write(prefix, b)
+}
+
+// write a message under the prefix, after those written before it
+func (s *Spool) write(prefix string, b []byte) error {
+	now := time.Now().UnixNano()
+	if now <= s.last {
+		now = s.last + 1
+	}
+	s.last = now
+
+	return s.store.Write(&store.Record{
+		Key:   fmt.Sprintf("%s%020d/%s", prefix, now, uuid.New().String()),
+		Value: b,
+	})
+}
+
+// Topics returns the topics which have a backlog
+func (s *Spool) Topics() ([]string, error) {
+	keys, err := s.store.List(store.ListPrefix(spoolPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var topics []string
+	seen := make(map[string]bool)
+
+	for _, k := range keys {
+		parts := strings.SplitN(strings.TrimPrefix(k, spoolPrefix), "/", 2)
+		topic, err := url.PathUnescape(parts[0])
+		if err != nil || seen[topic] {
+			continue
+		}
+		seen[topic] = true
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// Queues returns the queues subscribed to the topic which have a backlog
+func (s *Spool) Queues(topic string) ([]string, error) {
+	keys, err := s.store.List(store.ListPrefix(topicPrefix(topic)))
+	if err != nil {
+		return nil, err
+	}
+
+	var queues []string
+	seen := make(map[string]bool)
+
+	for _, k := range keys {
+		parts := strings.SplitN(strings.TrimPrefix(k, topicPrefix(topic)), "/", 2)
+		if parts[0] == spoolShared {
+			continue
+		}
+		queue, err := url.PathUnescape(parts[0])
+		if err != nil || seen[queue] {
+			continue
+		}
+		seen[queue] = true
+		queues = append(queues, queue)
+	}
+
+	sort.Strings(queues)
+	return queues, nil
+}
+
+// List the backlog of the topic which hasn't been sent to any queue yet, oldest first
+func (s *Spool) List(topic string) ([]*broker.Message, error) {
+	return s.list(topic, "")
+}
+
+// ListQueue lists the backlog kept for the queue subscribed to the topic, oldest first
+func (s *Spool) ListQueue(topic, queue string) ([]*broker.Message, error) {
+	if len(queue) == 0 {
+		return nil, nil
+	}
+	return s.list(topic, queue)
+}
+
+func (s *Spool) list(topic, queue string) ([]*broker.Message, error) {
+	recs, err := s.store.Read(queuePrefix(topic, queue), store.ReadPrefix())
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+
+	msgs := make([]*broker.Message, 0, len(recs))
+	for _, r := range recs {
+		m, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &broker.Message{Header: m.Header, Body: m.Body, Topic: topic})
+	}
+
+	return msgs, nil
+}
+
+// Purge the backlog of the topic, including that kept for its queues
+func (s *Spool) Purge(topic string) error {
+	keys, err := s.store.List(store.ListPrefix(topicPrefix(topic)))
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.store.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next returns the oldest records of the backlog of the queue subscribed to the
+// topic, or of the topic if the queue is empty. Records are removed once they're
+// handled, so the next are always at the head of the backlog.
+func (s *Spool) next(topic, queue string, num uint) ([]*store.Record, error) {
+	recs, err := s.store.Read(queuePrefix(topic, queue), store.ReadPrefix(), store.ReadLimit(num))
+	if err == store.ErrNotFound {
+		// a message was removed while it was read
+		return recs, nil
+	}
+	return recs, err
+}
+
+// remove a message which has been handled
+func (s *Spool) remove(key string) error {
+	return s.store.Delete(key)
+}
+
+// decode a record returned by next
+func decode(r *store.Record) (*spooled, error) {
+	var m spooled
+	if err := json.Unmarshal(r.Value, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
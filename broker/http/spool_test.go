@@ -0,0 +1,94 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func TestSpool(t *testing.T) {
+	s := NewSpool(memory.NewStore())
+
+	for i := 0; i < 3; i++ {
+		for _, topic := range []string{"foo", "foo/bar"} {
+			msg := &broker.Message{Header: map[string]string{"id": fmt.Sprint(i)}, Body: []byte(topic)}
+			if err := s.Add(topic, msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	topics, err := s.Topics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(topics) != "[foo foo/bar]" {
+		t.Fatalf("Expected topics [foo foo/bar], got %v", topics)
+	}
+
+	msgs, err := s.List("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("Expected 3 messages, got %v", len(msgs))
+	}
+	for i, m := range msgs {
+		if m.Header["id"] != fmt.Sprint(i) || string(m.Body) != "foo" || m.Topic != "foo" {
+			t.Fatalf("Unexpected message %v: %+v", i, m)
+		}
+	}
+
+	if err := s.Purge("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if msgs, _ := s.List("foo"); len(msgs) != 0 {
+		t.Fatalf("Expected the backlog of foo to be purged, got %v", len(msgs))
+	}
+	if msgs, _ := s.List("foo/bar"); len(msgs) != 3 {
+		t.Fatalf("Expected the backlog of foo/bar to be kept, got %v", len(msgs))
+	}
+}
+
+func TestSpoolLimit(t *testing.T) {
+	s := NewSpool(memory.NewStore())
+	s.limit = 2
+
+	for i := 0; i < 3; i++ {
+		err := s.Add("foo", &broker.Message{Body: []byte(fmt.Sprint(i))})
+		if i < 2 && err != nil {
+			t.Fatal(err)
+		} else if i == 2 && err != ErrSpoolFull {
+			t.Fatalf("Expected the spool to be full, got %v", err)
+		}
+	}
+
+	msgs, err := s.List("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || string(msgs[1].Body) != "1" {
+		t.Fatalf("Expected the messages over the limit to be dropped, got %v", len(msgs))
+	}
+
+	// the oldest messages kept for a queue are dropped
+	for i := 0; i < 3; i++ {
+		b, _ := json.Marshal(&spooled{Body: []byte(fmt.Sprint(i))})
+		if err := s.keep("foo", "bar", b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queues, err := s.Queues("foo"); err != nil || fmt.Sprint(queues) != "[bar]" {
+		t.Fatalf("Expected queues [bar], got %v %v", queues, err)
+	}
+	msgs, err = s.ListQueue("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || string(msgs[0].Body) != "1" || string(msgs[1].Body) != "2" {
+		t.Fatalf("Expected the oldest messages of the queue to be dropped, got %v", len(msgs))
+	}
+}
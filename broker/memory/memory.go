@@ -10,6 +10,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/broker/schedule"
+	"github.com/micro/go-micro/v3/store/memory"
 	maddr "github.com/micro/go-micro/v3/util/addr"
 	mnet "github.com/micro/go-micro/v3/util/net"
 )
@@ -21,6 +23,9 @@ type memoryBroker struct {
 	sync.RWMutex
 	connected   bool
 	Subscribers map[string][]*memorySubscriber
+
+	// messages to publish later
+	schedule *schedule.Schedule
 }
 
 type memorySubscriber struct {
@@ -59,6 +64,14 @@ func (m *memoryBroker) Connect() error {
 	m.addr = addr
 	m.connected = true
 
+	// publish scheduled messages, including any stored before a restart
+	st := m.opts.Store
+	if st == nil {
+		st = memory.NewStore()
+	}
+	m.schedule = schedule.New(st, m.publish)
+	m.schedule.Start()
+
 	return nil
 }
 
@@ -71,6 +84,7 @@ func (m *memoryBroker) Disconnect() error {
 	}
 
 	m.connected = false
+	m.schedule.Stop()
 
 	return nil
 }
@@ -83,22 +97,73 @@ func (m *memoryBroker) Init(opts ...broker.Option) error {
 }
 
 func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// carry the key in the header so it's kept if the message is scheduled
+	if len(options.Key) > 0 {
+		keyed := &broker.Message{Header: map[string]string{broker.KeyHeader: options.Key}, Body: msg.Body}
+		for k, v := range msg.Header {
+			if k != broker.KeyHeader {
+				keyed.Header[k] = v
+			}
+		}
+		msg = keyed
+	}
+
 	m.RLock()
 	if !m.connected {
 		m.RUnlock()
 		return errors.New("not connected")
 	}
 
-	subs, ok := m.Subscribers[topic]
+	// store the message until it's due
+	if options.At.After(time.Now()) {
+		sched := m.schedule
+		m.RUnlock()
+		return sched.Add(topic, msg, options.At)
+	}
 	m.RUnlock()
-	if !ok {
-		return nil
+
+	return m.publish(topic, msg)
+}
+
+// publish the message to the subscribers now. Every subscriber without a queue
+// gets the message, as does one member of each queue; the owner of the key if
+// the message has one, otherwise a random member.
+func (m *memoryBroker) publish(topic string, msg *broker.Message) error {
+	m.RLock()
+
+	// find the subscribers whose topic matches, including wildcards
+	var subs []*memorySubscriber
+	queues := make(map[string][]*memorySubscriber)
+	for pattern, s := range m.Subscribers {
+		if !broker.MatchTopic(pattern, topic) {
+			continue
+		}
+		for _, sub := range s {
+			if q := sub.opts.Queue; len(q) > 0 {
+				queues[q] = append(queues[q], sub)
+				continue
+			}
+			subs = append(subs, sub)
+		}
+	}
+	m.RUnlock()
+
+	for _, members := range queues {
+		subs = append(subs, members[member(members, msg.Header[broker.KeyHeader])])
 	}
 
 	for _, sub := range subs {
-		if err := sub.handler(msg); err != nil {
+		// each subscriber gets a copy of the message with the topic set
+		delivered := *msg
+		delivered.Topic = topic
+		if err := sub.handler(&delivered); err != nil {
 			if eh := sub.opts.ErrorHandler; eh != nil {
-				eh(msg, err)
+				eh(&delivered, err)
 			}
 			continue
 		}
@@ -107,6 +172,18 @@ func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker
 	return nil
 }
 
+// member returns the index of the member of a queue to deliver a message to
+func member(members []*memorySubscriber, key string) int {
+	if len(key) == 0 {
+		return rand.Intn(len(members))
+	}
+	ids := make([]string, len(members))
+	for i, sub := range members {
+		ids[i] = sub.id
+	}
+	return broker.Partition(key, ids)
+}
+
 func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	m.RLock()
 	if !m.connected {
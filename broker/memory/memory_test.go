@@ -2,7 +2,9 @@ package memory
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/micro/go-micro/v3/broker"
 )
@@ -48,3 +50,157 @@ func TestMemoryBroker(t *testing.T) {
 		t.Fatalf("Unexpected connect error %v", err)
 	}
 }
+
+func TestMemoryBrokerWildcards(t *testing.T) {
+	b := NewBroker()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	received := map[string][]string{}
+	for _, pattern := range []string{"orders.*", "orders.>", "orders.created", "*.created"} {
+		pattern := pattern
+		_, err := b.Subscribe(pattern, func(m *broker.Message) error {
+			received[pattern] = append(received[pattern], m.Topic)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+	}
+
+	for _, topic := range []string{"orders.created", "orders.eu.created", "orders", "users.created"} {
+		if err := b.Publish(topic, &broker.Message{Body: []byte(topic)}); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		"orders.*":       "[orders.created]",
+		"orders.>":       "[orders.created orders.eu.created]",
+		"orders.created": "[orders.created]",
+		"*.created":      "[orders.created users.created]",
+	}
+	for pattern, topics := range expected {
+		if got := fmt.Sprint(received[pattern]); got != topics {
+			t.Fatalf("Expected %s to receive %s, got %s", pattern, topics, got)
+		}
+	}
+}
+
+func TestMemoryBrokerPublishAfter(t *testing.T) {
+	b := NewBroker()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	received := make(chan time.Time, 1)
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		received <- time.Now()
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	published := time.Now()
+	if err := b.Publish("test", &broker.Message{Body: []byte("later")}, broker.PublishAfter(time.Millisecond*100)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	select {
+	case at := <-received:
+		if at.Sub(published) < time.Millisecond*100 {
+			t.Fatalf("The message was delivered before it was due")
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatalf("The message was not delivered")
+	}
+}
+
+func TestMemoryBrokerKeys(t *testing.T) {
+	b := NewBroker()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	// the members of the queue which received each key, and the messages of each key
+	owners := map[string]map[int]bool{}
+	received := map[string][]int{}
+
+	var subs []broker.Subscriber
+	for i := 0; i < 3; i++ {
+		i := i
+		sub, err := b.Subscribe("orders", func(m *broker.Message) error {
+			key := m.Header[broker.KeyHeader]
+			if owners[key] == nil {
+				owners[key] = map[int]bool{}
+			}
+			owners[key][i] = true
+			n, _ := strconv.Atoi(string(m.Body))
+			received[key] = append(received[key], n)
+			return nil
+		}, broker.Queue("q"))
+		if err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	publish := func(from, to int) {
+		for i := from; i < to; i++ {
+			key := fmt.Sprintf("order-%d", i%10)
+			msg := &broker.Message{Body: []byte(fmt.Sprintf("%d", i))}
+			if err := b.Publish("orders", msg, broker.PublishKey(key)); err != nil {
+				t.Fatalf("Unexpected error publishing %v", err)
+			}
+		}
+	}
+
+	publish(0, 100)
+
+	before := map[string]int{}
+	for key, members := range owners {
+		if len(members) != 1 {
+			t.Fatalf("Expected %v to be delivered to one member, got %v", key, members)
+		}
+		for i := range members {
+			before[key] = i
+		}
+		if len(received[key]) != 10 {
+			t.Fatalf("Expected 10 messages for %v, got %v", key, len(received[key]))
+		}
+		for j := 1; j < len(received[key]); j++ {
+			if received[key][j] < received[key][j-1] {
+				t.Fatalf("Expected the messages of %v in order, got %v", key, received[key])
+			}
+		}
+	}
+
+	// the keys of a member which leaves move to the others
+	leaving := before["order-0"]
+	subs[leaving].Unsubscribe()
+	time.Sleep(time.Millisecond * 10)
+
+	owners = map[string]map[int]bool{}
+	publish(100, 200)
+
+	for key, members := range owners {
+		if len(members) != 1 {
+			t.Fatalf("Expected %v to be delivered to one member, got %v", key, members)
+		}
+		for i := range members {
+			if i == leaving {
+				t.Fatalf("Expected %v to move from the member which left", key)
+			}
+			if before[key] != leaving && before[key] != i {
+				t.Fatalf("Expected %v to stay on member %v, got %v", key, before[key], i)
+			}
+		}
+	}
+}
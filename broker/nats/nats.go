@@ -193,6 +193,7 @@ func (n *natsBroker) Subscribe(topic string, handler broker.Handler, opts ...bro
 			}
 			return
 		}
+		m.Topic = msg.Subject
 		if err := handler(m); err != nil {
 			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
 				logger.Error(err)
@@ -3,9 +3,11 @@ package broker
 import (
 	"context"
 	"crypto/tls"
+	"time"
 
 	"github.com/micro/go-micro/v3/codec"
 	"github.com/micro/go-micro/v3/registry"
+	"github.com/micro/go-micro/v3/store"
 )
 
 type Options struct {
@@ -16,12 +18,19 @@ type Options struct {
 	TLSConfig *tls.Config
 	// Registry used for clustering
 	Registry registry.Registry
+	// Store used to persist messages, such as those scheduled for later
+	Store store.Store
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
 }
 
 type PublishOptions struct {
+	// At is the time to publish the message, if it's in the future
+	At time.Time
+	// Key orders the message. Messages with the same key are delivered
+	// to the same member of a queue, in the order they were published.
+	Key string
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -52,6 +61,29 @@ func PublishContext(ctx context.Context) PublishOption {
 	}
 }
 
+// PublishAt publishes the message at the time given, rather than immediately
+func PublishAt(t time.Time) PublishOption {
+	return func(o *PublishOptions) {
+		o.At = t
+	}
+}
+
+// PublishAfter publishes the message once the duration has passed
+func PublishAfter(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.At = time.Now().Add(d)
+	}
+}
+
+// PublishKey sets the ordering key of the message. Messages with the same key are
+// delivered to the same member of each queue in order, and are rebalanced across
+// the members as they join or leave.
+func PublishKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.Key = key
+	}
+}
+
 type SubscribeOption func(*SubscribeOptions)
 
 func NewSubscribeOptions(opts ...SubscribeOption) SubscribeOptions {
@@ -94,6 +126,13 @@ func Queue(name string) SubscribeOption {
 	}
 }
 
+// Store sets the store used to persist messages
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
 func Registry(r registry.Registry) Option {
 	return func(o *Options) {
 		o.Registry = r
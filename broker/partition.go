@@ -0,0 +1,39 @@
+package broker
+
+import "hash/fnv"
+
+// KeyHeader is the header the ordering key of a message is carried in
+const KeyHeader = "Micro-Key"
+
+// Partition returns the index of the member of a queue which owns the key, or
+// -1 if there are no members. Keys are assigned by rendezvous hashing of the
+// member ids, so every publisher picks the same member for a key regardless of
+// the order of the members, and when members join or leave only the keys owned
+// by those members move.
+func Partition(key string, members []string) int {
+	idx := -1
+	var best uint64
+
+	for i, id := range members {
+		h := fnv.New64a()
+		h.Write([]byte(id))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+
+		if w := mix(h.Sum64()); idx < 0 || w > best {
+			idx, best = i, w
+		}
+	}
+
+	return idx
+}
+
+// mix the bits of the hash so the weights of similar ids are independent
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
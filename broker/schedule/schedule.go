@@ -0,0 +1,168 @@
+// Package schedule persists broker messages to be published at a later time
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// DefaultInterval is how often the schedule is checked for due messages
+	DefaultInterval = time.Second
+
+	// prefix of the keys scheduled messages are stored under
+	keyPrefix = "schedule/"
+	// number of due messages read at a time
+	batchSize uint = 100
+)
+
+// PublishFunc publishes a message which is due
+type PublishFunc func(topic string, msg *broker.Message) error
+
+// Schedule stores messages until they're due and then publishes them. The messages
+// are kept in a store, so they survive restarts if the store is persistent. A due
+// message is published before it's removed, so it's delivered at least once.
+type Schedule struct {
+	store    store.Store
+	publish  PublishFunc
+	interval time.Duration
+
+	sync.Mutex
+	exit chan bool
+}
+
+// message as it's stored
+type message struct {
+	Topic  string
+	Header map[string]string
+	Body   []byte
+}
+
+// New returns a schedule which publishes due messages with the function given
+func New(s store.Store, fn PublishFunc) *Schedule {
+	return &Schedule{
+		store:    s,
+		publish:  fn,
+		interval: DefaultInterval,
+	}
+}
+
+// key of a message due at the time. The time is padded so the
+// messages are stored in the order they're due.
+func key(at time.Time, id string) string {
+	return fmt.Sprintf("%s%020d/%s", keyPrefix, at.UnixNano(), id)
+}
+
+// Add a message to be published at the time given
+func (s *Schedule) Add(topic string, msg *broker.Message, at time.Time) error {
+	b, err := json.Marshal(&message{
+		Topic:  topic,
+		Header: msg.Header,
+		Body:   msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+	return s.store.Write(&store.Record{
+		Key:   key(at, uuid.New().String()),
+		Value: b,
+	})
+}
+
+// Start checking for due messages, including any stored before a restart
+func (s *Schedule) Start() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.exit != nil {
+		return
+	}
+	s.exit = make(chan bool)
+	go s.run(s.exit)
+}
+
+// Stop checking for due messages. Messages which aren't due yet remain in the store.
+func (s *Schedule) Stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.exit == nil {
+		return
+	}
+	close(s.exit)
+	s.exit = nil
+}
+
+func (s *Schedule) run(exit chan bool) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		s.flush()
+
+		select {
+		case <-t.C:
+		case <-exit:
+			return
+		}
+	}
+}
+
+// flush publishes every message which is due
+func (s *Schedule) flush() {
+	end := key(time.Now(), "~")
+
+	for {
+		keys, err := s.store.List(store.ListRange(keyPrefix, end), store.ListLimit(batchSize))
+		if err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error listing scheduled messages: %v", err)
+			}
+			return
+		}
+
+		for _, k := range keys {
+			if err := s.publishKey(k); err != nil {
+				// leave the message to be retried on the next check
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("Error publishing scheduled message: %v", err)
+				}
+				return
+			}
+		}
+
+		if uint(len(keys)) < batchSize {
+			return
+		}
+	}
+}
+
+func (s *Schedule) publishKey(k string) error {
+	recs, err := s.store.Read(k)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var m message
+	if err := json.Unmarshal(recs[0].Value, &m); err != nil {
+		// a message which can't be decoded never will be
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Dropping invalid scheduled message %v: %v", k, err)
+		}
+		return s.store.Delete(k)
+	}
+
+	if err := s.publish(m.Topic, &broker.Message{Header: m.Header, Body: m.Body}); err != nil {
+		return err
+	}
+	return s.store.Delete(k)
+}
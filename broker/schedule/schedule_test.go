@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func TestSchedule(t *testing.T) {
+	st := memory.NewStore()
+
+	// messages added before a restart are published by the next schedule using the store
+	s := New(st, func(topic string, msg *broker.Message) error {
+		t.Fatalf("Unexpected publish before the schedule was started")
+		return nil
+	})
+	if err := s.Add("later", &broker.Message{Body: []byte("later")}, time.Now().Add(time.Millisecond*200)); err != nil {
+		t.Fatalf("Unexpected error adding a message: %v", err)
+	}
+	if err := s.Add("first", &broker.Message{Body: []byte("first")}, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Unexpected error adding a message: %v", err)
+	}
+
+	published := make(chan string, 2)
+	s = New(st, func(topic string, msg *broker.Message) error {
+		published <- topic
+		return nil
+	})
+	s.interval = time.Millisecond * 10
+	s.Start()
+	defer s.Stop()
+
+	start := time.Now()
+	for _, expected := range []string{"first", "later"} {
+		select {
+		case topic := <-published:
+			if topic != expected {
+				t.Fatalf("Expected %v to be published, got %v", expected, topic)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %v to be published", expected)
+		}
+	}
+	if time.Since(start) < time.Millisecond*150 {
+		t.Fatalf("The message was published before it was due")
+	}
+
+	// published messages are removed from the store
+	if keys, _ := st.List(); len(keys) != 0 {
+		t.Fatalf("Expected the schedule to be empty, got %v", keys)
+	}
+}
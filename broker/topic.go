@@ -0,0 +1,30 @@
+package broker
+
+import "strings"
+
+// MatchTopic returns true if the topic matches the pattern a subscriber used.
+// Topics are split into tokens by dots. In a pattern, a "*" token matches any
+// single token and a final ">" token matches one or more tokens, so
+// "orders.*" matches "orders.created" and "orders.>" matches "orders.eu.created".
+func MatchTopic(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+
+	pt := strings.Split(pattern, ".")
+	tt := strings.Split(topic, ".")
+
+	for i, p := range pt {
+		if p == ">" && i == len(pt)-1 {
+			return len(tt) > i
+		}
+		if i >= len(tt) {
+			return false
+		}
+		if p != "*" && p != tt[i] {
+			return false
+		}
+	}
+
+	return len(pt) == len(tt)
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// number of buckets the window of a budget is split into
+const budgetBuckets = 10
+
+// Budget limits the retries made to each service to a ratio of the requests
+// made to it over a window, so retries don't multiply the load on a service
+// which is failing. Hedged calls are counted as retries. A nil Budget allows
+// every retry.
+type Budget struct {
+	ratio  float64
+	min    int
+	window time.Duration
+
+	sync.Mutex
+	services map[string]*budget
+}
+
+// budget counts the requests and retries to a service in each bucket of the window
+type budget struct {
+	requests, retries [budgetBuckets]int
+	// the current bucket and when it started
+	bucket int
+	start  time.Time
+}
+
+// NewBudget returns a budget which allows retries of up to the ratio of the
+// requests made to a service in the window, in addition to the min retries.
+func NewBudget(ratio float64, min int, window time.Duration) *Budget {
+	return &Budget{
+		ratio:    ratio,
+		min:      min,
+		window:   window,
+		services: make(map[string]*budget),
+	}
+}
+
+// Request records a request to the service
+func (b *Budget) Request(service string) {
+	if b == nil {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	s := b.service(service)
+	s.requests[s.bucket]++
+}
+
+// Retry reports whether the budget allows a retry to the service, and if so
+// records it
+func (b *Budget) Retry(service string) bool {
+	if b == nil {
+		return true
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	s := b.service(service)
+
+	var requests, retries int
+	for i := 0; i < budgetBuckets; i++ {
+		requests += s.requests[i]
+		retries += s.retries[i]
+	}
+
+	if float64(retries+1) > float64(b.min)+b.ratio*float64(requests) {
+		return false
+	}
+
+	s.retries[s.bucket]++
+	return true
+}
+
+// service returns the counts of the service, moving on to the current bucket
+func (b *Budget) service(name string) *budget {
+	now := time.Now()
+
+	s, ok := b.services[name]
+	if !ok {
+		s = &budget{start: now}
+		b.services[name] = s
+		return s
+	}
+
+	width := b.window / budgetBuckets
+	if width <= 0 {
+		width = 1
+	}
+
+	// clear the buckets which have passed
+	n := int(now.Sub(s.start) / width)
+	if n > budgetBuckets {
+		n = budgetBuckets
+	}
+	for i := 0; i < n; i++ {
+		s.bucket = (s.bucket + 1) % budgetBuckets
+		s.requests[s.bucket] = 0
+		s.retries[s.bucket] = 0
+	}
+	if n > 0 {
+		s.start = now.Add(-now.Sub(s.start) % width)
+	}
+
+	return s
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(0.2, 1, time.Millisecond*100)
+
+	for i := 0; i < 10; i++ {
+		b.Request("foo")
+	}
+
+	// the min retry plus a fifth of the requests
+	for i := 0; i < 3; i++ {
+		if !b.Retry("foo") {
+			t.Fatalf("Expected retry %d to be allowed", i)
+		}
+	}
+	if b.Retry("foo") {
+		t.Fatal("Expected the budget to be spent")
+	}
+
+	// each service has its own budget
+	if !b.Retry("bar") {
+		t.Fatal("Expected the min retry of another service to be allowed")
+	}
+
+	// the budget is replenished once the window has passed
+	time.Sleep(time.Millisecond * 110)
+	if !b.Retry("foo") {
+		t.Fatal("Expected a retry to be allowed in the next window")
+	}
+	if b.Retry("foo") {
+		t.Fatal("Expected the requests of the last window to be forgotten")
+	}
+
+	// a nil budget allows every retry
+	var nb *Budget
+	nb.Request("foo")
+	if !nb.Retry("foo") {
+		t.Fatal("Expected a nil budget to allow retries")
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/avro"
 	"github.com/micro/go-micro/v3/codec/bytes"
+	"github.com/micro/go-micro/v3/codec/msgpack"
 	"github.com/oxtoacart/bpool"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/encoding"
@@ -21,6 +23,12 @@ type protoCodec struct{}
 type bytesCodec struct{}
 type wrapCodec struct{ encoding.Codec }
 
+// marshalerCodec adapts a go-micro marshaler to a grpc codec
+type marshalerCodec struct{ codec.Marshaler }
+
+var msgpackCodec = marshalerCodec{msgpack.Marshaler{}}
+var avroCodec = marshalerCodec{avro.Marshaler{}}
+
 var jsonpbMarshaler = &jsonpb.Marshaler{}
 var useNumber bool
 
@@ -37,6 +45,11 @@ var (
 		"application/grpc+json":    jsonCodec{},
 		"application/grpc+proto":   protoCodec{},
 		"application/grpc+bytes":   bytesCodec{},
+		"application/msgpack":      msgpackCodec,
+		"application/x-msgpack":    msgpackCodec,
+		"application/grpc+msgpack": msgpackCodec,
+		"application/avro":         avroCodec,
+		"application/grpc+avro":    avroCodec,
 	}
 )
 
@@ -45,6 +58,10 @@ func UseNumber() {
 	useNumber = true
 }
 
+func (m marshalerCodec) Name() string {
+	return m.Marshaler.String()
+}
+
 func (w wrapCodec) String() string {
 	return w.Codec.Name()
 }
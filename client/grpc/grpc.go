@@ -16,6 +16,8 @@ import (
 	raw "github.com/micro/go-micro/v3/codec/bytes"
 	"github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/selector"
+	"github.com/micro/go-micro/v3/util/compress"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -33,6 +35,8 @@ func init() {
 	encoding.RegisterCodec(wrapCodec{jsonCodec{}})
 	encoding.RegisterCodec(wrapCodec{protoCodec{}})
 	encoding.RegisterCodec(wrapCodec{bytesCodec{}})
+	encoding.RegisterCodec(wrapCodec{msgpackCodec})
+	encoding.RegisterCodec(wrapCodec{avroCodec})
 }
 
 // secure returns the dial option for whether its a secure or insecure connection
@@ -411,7 +415,7 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, callOpts.SelectOptions...)
 	if err != nil {
 		return err
 	}
@@ -429,14 +433,24 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 			time.Sleep(t)
 		}
 
-		// get the next node
-		node := next()
+		// make the call, hedged with another node if it's slow
+		err = callOpts.Hedge.Call(ctx, req, rsp, next, callOpts, func(ctx context.Context, node string, rsp interface{}) error {
+			// track the call to inform future routing decisions
+			done := selector.Track(callOpts.Selector, node)
 
-		// make the call
-		err = gcall(ctx, node, req, rsp, callOpts)
+			// make the call
+			err := gcall(ctx, node, req, rsp, callOpts)
+
+			// record the result of the call, unless it was abandoned
+			// for the other hedged call, which says nothing of the node
+			if ctx.Err() == context.Canceled {
+				done(context.Canceled)
+			} else {
+				done(err)
+			}
 
-		// record the result of the call to inform future routing decisions
-		g.opts.Selector.Record(node, err)
+			return err
+		})
 
 		// try and transform the error to a go-micro error
 		if verr, ok := err.(*errors.Error); ok {
@@ -446,6 +460,9 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 		return err
 	}
 
+	// record the request against the retry budget
+	callOpts.RetryBudget.Request(req.Service())
+
 	ch := make(chan error, callOpts.Retries+1)
 	var gerr error
 
@@ -472,6 +489,11 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 				return err
 			}
 
+			// stop retrying once the budget of the service is spent
+			if i < callOpts.Retries && !callOpts.RetryBudget.Retry(req.Service()) {
+				return err
+			}
+
 			gerr = err
 		}
 	}
@@ -526,7 +548,7 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, callOpts.SelectOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -546,19 +568,21 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 		// get the next node
 		node := next()
 
+		// track the call to inform future routing decisions
+		done := selector.Track(callOpts.Selector, node)
+
 		// make the call
 		stream := &grpcStream{}
 		err = g.stream(ctx, node, req, stream, callOpts)
 
-		// record the result of the call to inform future routing decisions
-		g.opts.Selector.Record(node, err)
+		// record the result of the call
+		done(err)
 
 		// try and transform the error to a go-micro error
 		if verr, ok := err.(*errors.Error); ok {
 			return nil, verr
 		}
 
-		g.opts.Selector.Record(node, err)
 		return stream, err
 	}
 
@@ -567,6 +591,9 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 		err    error
 	}
 
+	// record the request against the retry budget
+	callOpts.RetryBudget.Request(req.Service())
+
 	ch := make(chan response, callOpts.Retries+1)
 	var grr error
 
@@ -594,6 +621,11 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 				return nil, rsp.err
 			}
 
+			// stop retrying once the budget of the service is spent
+			if i < callOpts.Retries && !callOpts.RetryBudget.Retry(req.Service()) {
+				return nil, rsp.err
+			}
+
 			grr = rsp.err
 		}
 	}
@@ -641,6 +673,15 @@ func (g *grpcClient) Publish(ctx context.Context, p client.Message, opts ...clie
 		body = b
 	}
 
+	// compress the body if it's been asked for
+	if len(g.opts.PublishCompression) > 0 {
+		var err error
+		body, err = compress.Encode(md, body, g.opts.PublishCompression, g.opts.CompressThreshold)
+		if err != nil {
+			return errors.InternalServerError("go.micro.client", err.Error())
+		}
+	}
+
 	topic := p.Topic()
 
 	// get the exchange
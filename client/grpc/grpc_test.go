@@ -3,14 +3,19 @@ package grpc
 import (
 	"context"
 	"net"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/micro/go-micro/v3/broker"
+	bmemory "github.com/micro/go-micro/v3/broker/memory"
 	"github.com/micro/go-micro/v3/client"
 	"github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/registry/memory"
 	"github.com/micro/go-micro/v3/router"
 	regRouter "github.com/micro/go-micro/v3/router/registry"
+	"github.com/micro/go-micro/v3/util/compress"
 	pgrpc "google.golang.org/grpc"
 	pb "google.golang.org/grpc/examples/helloworld/helloworld"
 )
@@ -106,3 +111,43 @@ func TestGRPCClient(t *testing.T) {
 	}
 
 }
+
+func TestGRPCPublishCompression(t *testing.T) {
+	brk := bmemory.NewBroker()
+	if err := brk.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer brk.Disconnect()
+
+	msgs := make(chan *broker.Message, 1)
+	sub, err := brk.Subscribe("test.compress", func(m *broker.Message) error {
+		msgs <- m
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	large := strings.Repeat("hello ", 1024)
+	c := NewClient(client.Broker(brk), client.PublishCompression("gzip"))
+	if err := c.Publish(context.Background(), c.NewMessage("test.compress", &pb.HelloRequest{Name: large})); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	select {
+	case m := <-msgs:
+		if e := m.Header[compress.EncodingKey]; e != "gzip" {
+			t.Fatalf("Expected the message to be compressed with gzip, got %q", e)
+		}
+		body, err := compress.Decode(m.Header, m.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), large) {
+			t.Fatalf("Expected the message to decompress to the payload")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/micro/go-micro/v3/selector"
+)
+
+const (
+	// number of latencies of each endpoint the percentile is taken from
+	hedgeSamples = 100
+	// number of times the next node is selected looking for another node
+	hedgeSelects = 3
+)
+
+// Hedge makes a second call to another node when a call hasn't returned
+// after a percentile of the latency of the endpoint. The first response is
+// used and the other call is cancelled. It should only be used to call
+// idempotent endpoints. A nil Hedge makes a single call.
+type Hedge struct {
+	percentile float64
+	min        int
+
+	sync.Mutex
+	latencies map[string]*latencies
+}
+
+// latencies of the successful calls to an endpoint
+type latencies struct {
+	samples []time.Duration
+	next    int
+}
+
+// NewHedge returns a hedge which makes the second call after the percentile,
+// e.g. 0.95, of the latency of the endpoint. Calls aren't hedged until there
+// have been min successful calls to the endpoint.
+func NewHedge(percentile float64, min int) *Hedge {
+	return &Hedge{
+		percentile: percentile,
+		min:        min,
+		latencies:  make(map[string]*latencies),
+	}
+}
+
+// Call calls fn with the next node, and again with another node if the call
+// hasn't returned after the hedge delay and the retry budget of the call
+// options allows it. The response of the first successful call is set in rsp,
+// the calls are made with their own response. The context of the other call is
+// cancelled, so its result can be told apart from a failure of the node.
+func (h *Hedge) Call(ctx context.Context, req Request, rsp interface{}, next selector.Next, opts CallOptions, fn func(ctx context.Context, node string, rsp interface{}) error) error {
+	node := next()
+
+	// the response is copied from that of the successful call
+	rv := reflect.ValueOf(rsp)
+	if h == nil || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fn(ctx, node, rsp)
+	}
+
+	key := req.Service() + " " + req.Endpoint()
+	delay, ok := h.delay(key)
+	if !ok {
+		start := time.Now()
+		err := fn(ctx, node, rsp)
+		if err == nil {
+			h.record(key, time.Since(start))
+		}
+		return err
+	}
+
+	type result struct {
+		rsp interface{}
+		err error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan result, 2)
+	start := time.Now()
+	call := func(node string) {
+		r := reflect.New(rv.Elem().Type()).Interface()
+		ch <- result{r, fn(ctx, node, r)}
+	}
+
+	go call(node)
+	calls := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var err error
+	for {
+		select {
+		case <-timer.C:
+			// look for another node to call
+			other := next()
+			for i := 1; other == node && i < hedgeSelects; i++ {
+				other = next()
+			}
+			if other == node || !opts.RetryBudget.Retry(req.Service()) {
+				continue
+			}
+			go call(other)
+			calls++
+		case r := <-ch:
+			calls--
+			if r.err == nil {
+				h.record(key, time.Since(start))
+				setResponse(rv, r.rsp)
+				return nil
+			}
+			err = r.err
+			// wait for the other call
+			if calls > 0 {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// setResponse sets the response to that of the call which returned first.
+// Protobuf messages are merged rather than copied, since their internal state
+// mustn't be copied.
+func setResponse(rv reflect.Value, rsp interface{}) {
+	if m, ok := rv.Interface().(proto.Message); ok {
+		m.Reset()
+		proto.Merge(m, rsp.(proto.Message))
+		return
+	}
+	rv.Elem().Set(reflect.ValueOf(rsp).Elem())
+}
+
+// delay returns the percentile of the latency of the endpoint
+func (h *Hedge) delay(key string) (time.Duration, bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	l, ok := h.latencies[key]
+	if !ok || len(l.samples) == 0 || len(l.samples) < h.min {
+		return 0, false
+	}
+
+	samples := make([]time.Duration, len(l.samples))
+	copy(samples, l.samples)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	i := int(math.Ceil(h.percentile*float64(len(samples)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(samples) {
+		i = len(samples) - 1
+	}
+	return samples[i], true
+}
+
+// record the latency of a successful call to the endpoint
+func (h *Hedge) record(key string, d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	l, ok := h.latencies[key]
+	if !ok {
+		l = &latencies{}
+		h.latencies[key] = l
+	}
+
+	if len(l.samples) < hedgeSamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % hedgeSamples
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testResponse struct {
+	Node string
+}
+
+// testNext returns the nodes in turn
+func testNext(nodes ...string) func() string {
+	var i int32 = -1
+	return func() string {
+		return nodes[int(atomic.AddInt32(&i, 1))%len(nodes)]
+	}
+}
+
+func TestHedge(t *testing.T) {
+	req := newRequest("foo", "Foo.Bar", nil, "")
+	h := NewHedge(0.9, 10)
+	for i := 0; i < 10; i++ {
+		h.record("foo Foo.Bar", time.Millisecond*10)
+	}
+
+	var cancelled int32
+	fn := func(ctx context.Context, node string, rsp interface{}) error {
+		// the first node is slow
+		if node == "a" {
+			select {
+			case <-ctx.Done():
+				atomic.AddInt32(&cancelled, 1)
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+		rsp.(*testResponse).Node = node
+		return nil
+	}
+
+	rsp := &testResponse{}
+	start := time.Now()
+	if err := h.Call(context.Background(), req, rsp, testNext("a", "b"), CallOptions{}, fn); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Node != "b" {
+		t.Fatalf("Expected the response of the hedged call, got %v", rsp.Node)
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Fatalf("Expected the hedged call to return first, took %v", d)
+	}
+
+	// the slow call is cancelled
+	time.Sleep(time.Millisecond * 10)
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Fatal("Expected the slow call to be cancelled")
+	}
+
+	// the budget limits the hedged calls
+	b := NewBudget(0, 0, time.Minute)
+	rsp = &testResponse{}
+	if err := h.Call(context.Background(), req, rsp, testNext("a", "b"), CallOptions{RetryBudget: b}, fn); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Node != "a" {
+		t.Fatalf("Expected a single call without a retry budget, got %v", rsp.Node)
+	}
+}
+
+func TestHedgeErrors(t *testing.T) {
+	req := newRequest("foo", "Foo.Bar", nil, "")
+	h := NewHedge(0.5, 1)
+
+	// calls aren't hedged until the latency is known
+	var calls int32
+	fail := func(ctx context.Context, node string, rsp interface{}) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond * 20)
+		return errors.New("failed")
+	}
+	if err := h.Call(context.Background(), req, &testResponse{}, testNext("a", "b"), CallOptions{}, fail); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected a single call, got %d", calls)
+	}
+
+	// the error is returned once both calls fail
+	h.record("foo Foo.Bar", time.Millisecond)
+	calls = 0
+	if err := h.Call(context.Background(), req, &testResponse{}, testNext("a", "b"), CallOptions{}, fail); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Expected the call to be hedged, got %d calls", calls)
+	}
+
+	// a call to the same node isn't hedged
+	calls = 0
+	if err := h.Call(context.Background(), req, &testResponse{}, testNext("a"), CallOptions{}, fail); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected a single call to the node, got %d", calls)
+	}
+}
+
+func TestHedgeProto(t *testing.T) {
+	req := newRequest("foo", "Foo.Bar", nil, "")
+	h := NewHedge(0.5, 1)
+	h.record("foo Foo.Bar", time.Millisecond)
+
+	fn := func(ctx context.Context, node string, rsp interface{}) error {
+		if node == "a" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		rsp.(*wrapperspb.StringValue).Value = node
+		return nil
+	}
+
+	// protobuf responses are merged from that of the hedged call
+	rsp := &wrapperspb.StringValue{Value: "stale"}
+	if err := h.Call(context.Background(), req, rsp, testNext("a", "b"), CallOptions{}, fn); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Value != "b" {
+		t.Fatalf("Expected the response of the hedged call, got %v", rsp.Value)
+	}
+}
@@ -4,6 +4,7 @@ package mucp
 import (
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -15,7 +16,9 @@ import (
 	"github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/metadata"
 	"github.com/micro/go-micro/v3/network/transport"
+	"github.com/micro/go-micro/v3/selector"
 	"github.com/micro/go-micro/v3/util/buf"
+	"github.com/micro/go-micro/v3/util/compress"
 	"github.com/micro/go-micro/v3/util/pool"
 )
 
@@ -24,6 +27,9 @@ type rpcClient struct {
 	opts client.Options
 	pool pool.Pool
 	seq  uint64
+
+	// encodings accepted by each server address
+	accepted sync.Map
 }
 
 // NewClient returns a new micro client interface
@@ -63,6 +69,33 @@ func (r *rpcClient) newCodec(contentType string) (codec.NewCodec, error) {
 	return nil, fmt.Errorf("Unsupported Content-Type: %s", contentType)
 }
 
+// compression sets the encodings the client accepts in the header, and returns
+// the compression of messages sent to the address. Requests are compressed with
+// the encodings the server accepted when it last responded.
+func (r *rpcClient) compression(addr string, hdr map[string]string) compression {
+	if len(r.opts.Compression) == 0 {
+		return compression{}
+	}
+
+	hdr[compress.AcceptKey] = compress.Accept(r.opts.Compression)
+
+	v, _ := r.accepted.Load(addr)
+	accept, _ := v.(string)
+
+	return compression{
+		encoding:  compress.Negotiate(accept, r.opts.Compression),
+		encodings: r.opts.Compression,
+		threshold: r.opts.CompressThreshold,
+		accepted: func(accept string) {
+			if len(accept) == 0 {
+				r.accepted.Delete(addr)
+				return
+			}
+			r.accepted.Store(addr, accept)
+		},
+	}
+}
+
 func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, resp interface{}, opts client.CallOptions) error {
 	msg := &transport.Message{
 		Header: make(map[string]string),
@@ -106,7 +139,7 @@ func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, r
 	}
 
 	seq := atomic.AddUint64(&r.seq, 1) - 1
-	codec := newRpcCodec(msg, c, cf, "")
+	codec := newRpcCodec(msg, c, cf, "", r.compression(addr, msg.Header))
 
 	rsp := &rpcResponse{
 		socket: c,
@@ -217,7 +250,7 @@ func (r *rpcClient) stream(ctx context.Context, addr string, req client.Request,
 	id := fmt.Sprintf("%v", seq)
 
 	// create codec with stream id
-	codec := newRpcCodec(msg, c, cf, id)
+	codec := newRpcCodec(msg, c, cf, id, r.compression(addr, msg.Header))
 
 	rsp := &rpcResponse{
 		socket: c,
@@ -360,7 +393,7 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, callOpts.SelectOptions...)
 	if err != nil {
 		return err
 	}
@@ -378,16 +411,24 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 			time.Sleep(t)
 		}
 
-		// get the next node
-		node := next()
+		// make the call, hedged with another node if it's slow
+		return callOpts.Hedge.Call(ctx, request, response, next, callOpts, func(ctx context.Context, node string, rsp interface{}) error {
+			// track the call to inform future routing decisions
+			done := selector.Track(callOpts.Selector, node)
 
-		// make the call
-		err = rcall(ctx, node, request, response, callOpts)
+			// make the call
+			err := rcall(ctx, node, request, rsp, callOpts)
 
-		// record the result of the call to inform future routing decisions
-		r.opts.Selector.Record(node, err)
+			// record the result of the call, unless it was abandoned
+			// for the other hedged call, which says nothing of the node
+			if ctx.Err() == context.Canceled {
+				done(context.Canceled)
+			} else {
+				done(err)
+			}
 
-		return err
+			return err
+		})
 	}
 
 	// get the retries
@@ -398,6 +439,9 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 		retries = 0
 	}
 
+	// record the request against the retry budget
+	callOpts.RetryBudget.Request(request.Service())
+
 	ch := make(chan error, retries+1)
 	var gerr error
 
@@ -424,6 +468,11 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 				return err
 			}
 
+			// stop retrying once the budget of the service is spent
+			if i < retries && !callOpts.RetryBudget.Retry(request.Service()) {
+				return err
+			}
+
 			gerr = err
 		}
 	}
@@ -468,7 +517,7 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, callOpts.SelectOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -488,11 +537,14 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		// get the next node
 		node := next()
 
+		// track the call to inform future routing decisions
+		done := selector.Track(callOpts.Selector, node)
+
 		// perform the call
 		stream, err := r.stream(ctx, node, request, callOpts)
 
-		// record the result of the call to inform future routing decisions
-		r.opts.Selector.Record(node, err)
+		// record the result of the call
+		done(err)
 
 		return stream, err
 	}
@@ -510,6 +562,9 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		retries = 0
 	}
 
+	// record the request against the retry budget
+	callOpts.RetryBudget.Request(request.Service())
+
 	ch := make(chan response, retries+1)
 	var grr error
 
@@ -537,6 +592,11 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 				return nil, rsp.err
 			}
 
+			// stop retrying once the budget of the service is spent
+			if i < retries && !callOpts.RetryBudget.Retry(request.Service()) {
+				return nil, rsp.err
+			}
+
 			grr = rsp.err
 		}
 	}
@@ -600,6 +660,14 @@ func (r *rpcClient) Publish(ctx context.Context, msg client.Message, opts ...cli
 		body = b.Bytes()
 	}
 
+	// compress the body if it's been asked for
+	if len(r.opts.PublishCompression) > 0 {
+		body, err = compress.Encode(md, body, r.opts.PublishCompression, r.opts.CompressThreshold)
+		if err != nil {
+			return errors.InternalServerError("go.micro.client", err.Error())
+		}
+	}
+
 	if !r.once.Load().(bool) {
 		if err = r.opts.Broker.Connect(); err != nil {
 			return errors.InternalServerError("go.micro.client", err.Error())
@@ -3,17 +3,21 @@ package mucp
 import (
 	"bytes"
 	errs "errors"
+	"sync"
 
 	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/avro"
 	raw "github.com/micro/go-micro/v3/codec/bytes"
 	"github.com/micro/go-micro/v3/codec/grpc"
 	"github.com/micro/go-micro/v3/codec/json"
 	"github.com/micro/go-micro/v3/codec/jsonrpc"
+	"github.com/micro/go-micro/v3/codec/msgpack"
 	"github.com/micro/go-micro/v3/codec/proto"
 	"github.com/micro/go-micro/v3/codec/protorpc"
 	"github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/network/transport"
 	"github.com/micro/go-micro/v3/registry"
+	"github.com/micro/go-micro/v3/util/compress"
 )
 
 const (
@@ -42,6 +46,24 @@ type rpcCodec struct {
 
 	// signify if its a stream
 	stream string
+
+	// compression of the messages sent, the encoding of a stream is
+	// negotiated again once the first response arrives
+	sync.RWMutex
+	compression compression
+	negotiated  bool
+}
+
+// compression of the message bodies sent to a server
+type compression struct {
+	// encoding to compress with, if any
+	encoding string
+	// encodings the client compresses with, in order of preference
+	encodings []string
+	// minimum size of body compressed
+	threshold int
+	// accepted is called with the encodings the server accepts
+	accepted func(string)
 }
 
 type readWriteCloser struct {
@@ -61,6 +83,9 @@ var (
 		"application/json-rpc":     jsonrpc.NewCodec,
 		"application/proto-rpc":    protorpc.NewCodec,
 		"application/octet-stream": raw.NewCodec,
+		"application/msgpack":      msgpack.NewCodec,
+		"application/x-msgpack":    msgpack.NewCodec,
+		"application/avro":         avro.NewCodec,
 	}
 
 	// TODO: remove legacy codec list
@@ -151,17 +176,18 @@ func setupProtocol(msg *transport.Message, node *registry.Node) codec.NewCodec {
 	return defaultCodecs[msg.Header["Content-Type"]]
 }
 
-func newRpcCodec(req *transport.Message, client transport.Client, c codec.NewCodec, stream string) codec.Codec {
+func newRpcCodec(req *transport.Message, client transport.Client, c codec.NewCodec, stream string, comp compression) codec.Codec {
 	rwc := &readWriteCloser{
 		wbuf: bytes.NewBuffer(nil),
 		rbuf: bytes.NewBuffer(nil),
 	}
 	r := &rpcCodec{
-		buf:    rwc,
-		client: client,
-		codec:  c(rwc),
-		req:    req,
-		stream: stream,
+		buf:         rwc,
+		client:      client,
+		codec:       c(rwc),
+		req:         req,
+		stream:      stream,
+		compression: comp,
 	}
 	return r
 }
@@ -197,10 +223,19 @@ func (c *rpcCodec) Write(m *codec.Message, body interface{}) error {
 		}
 	}
 
+	// compress the body if it's large enough
+	c.RLock()
+	encoding := c.compression.encoding
+	c.RUnlock()
+	data, err := compress.Encode(m.Header, m.Body, encoding, c.compression.threshold)
+	if err != nil {
+		return errors.InternalServerError("go.micro.client.codec", err.Error())
+	}
+
 	// create new transport message
 	msg := transport.Message{
 		Header: m.Header,
-		Body:   m.Body,
+		Body:   data,
 	}
 
 	// send the request
@@ -219,14 +254,35 @@ func (c *rpcCodec) ReadHeader(m *codec.Message, r codec.MessageType) error {
 		return errors.InternalServerError("go.micro.client.transport", err.Error())
 	}
 
+	// remember the encodings the server accepts
+	if c.compression.accepted != nil {
+		accept := tm.Header[compress.AcceptKey]
+		c.compression.accepted(accept)
+
+		// the stream may have been opened before the server was known to
+		// accept any, so compress the messages sent from now on as it asks
+		c.Lock()
+		if len(c.stream) > 0 && !c.negotiated {
+			c.compression.encoding = compress.Negotiate(accept, c.compression.encodings)
+			c.negotiated = true
+		}
+		c.Unlock()
+	}
+
+	// decompress the body
+	body, err := compress.Decode(tm.Header, tm.Body)
+	if err != nil {
+		return errors.InternalServerError("go.micro.client.codec", err.Error())
+	}
+
 	c.buf.rbuf.Reset()
-	c.buf.rbuf.Write(tm.Body)
+	c.buf.rbuf.Write(body)
 
 	// set headers from transport
 	m.Header = tm.Header
 
 	// read header
-	err := c.codec.ReadHeader(m, r)
+	err = c.codec.ReadHeader(m, r)
 
 	// get headers
 	getHeaders(m)
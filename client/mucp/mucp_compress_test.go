@@ -0,0 +1,282 @@
+package mucp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	bmemory "github.com/micro/go-micro/v3/broker/memory"
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/network/transport"
+	tmemory "github.com/micro/go-micro/v3/network/transport/memory"
+	"github.com/micro/go-micro/v3/registry/memory"
+	"github.com/micro/go-micro/v3/server"
+	smucp "github.com/micro/go-micro/v3/server/mucp"
+	"github.com/micro/go-micro/v3/util/compress"
+)
+
+// testTransport records the headers of the messages a client sends and receives
+type testTransport struct {
+	transport.Transport
+
+	sync.Mutex
+	sent     []map[string]string
+	received []map[string]string
+}
+
+type testTransportClient struct {
+	transport.Client
+	t *testTransport
+}
+
+type TestPayload struct {
+	Data string `json:"data"`
+}
+
+type TestCompress struct{}
+
+func (TestCompress) Echo(ctx context.Context, req *TestPayload, rsp *TestPayload) error {
+	rsp.Data = req.Data
+	return nil
+}
+
+func (TestCompress) EchoStream(ctx context.Context, stream server.Stream) error {
+	for {
+		var req TestPayload
+		if err := stream.Recv(&req); err != nil {
+			return nil
+		}
+		if err := stream.Send(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *testTransport) Dial(addr string, opts ...transport.DialOption) (transport.Client, error) {
+	c, err := t.Transport.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &testTransportClient{c, t}, nil
+}
+
+func (t *testTransport) reset() {
+	t.Lock()
+	t.sent = nil
+	t.received = nil
+	t.Unlock()
+}
+
+func copyHeader(h map[string]string) map[string]string {
+	c := make(map[string]string, len(h))
+	for k, v := range h {
+		c[k] = v
+	}
+	return c
+}
+
+func (c *testTransportClient) Send(m *transport.Message) error {
+	c.t.Lock()
+	c.t.sent = append(c.t.sent, copyHeader(m.Header))
+	c.t.Unlock()
+	return c.Client.Send(m)
+}
+
+func (c *testTransportClient) Recv(m *transport.Message) error {
+	if err := c.Client.Recv(m); err != nil {
+		return err
+	}
+	c.t.Lock()
+	c.t.received = append(c.t.received, copyHeader(m.Header))
+	c.t.Unlock()
+	return nil
+}
+
+func TestCallCompression(t *testing.T) {
+	reg := memory.NewRegistry()
+	brk := bmemory.NewBroker(broker.Registry(reg))
+	tr := &testTransport{Transport: tmemory.NewTransport()}
+
+	srv := smucp.NewServer(
+		server.Name("test.compress"),
+		server.Address("127.0.0.1:0"),
+		server.Broker(brk),
+		server.Registry(reg),
+		server.Transport(tr),
+	)
+	if err := srv.Handle(srv.NewHandler(TestCompress{})); err != nil {
+		t.Fatal(err)
+	}
+
+	published := make(chan string, 1)
+	if err := srv.Subscribe(srv.NewSubscriber("test.compress", func(ctx context.Context, msg *TestPayload) error {
+		published <- msg.Data
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	c := NewClient(
+		client.Broker(brk),
+		client.Transport(tr),
+		client.ContentType("application/json"),
+	)
+	addr := srv.Options().Address
+
+	call := func(data string) {
+		var rsp TestPayload
+		req := c.NewRequest("test.compress", "TestCompress.Echo", &TestPayload{Data: data})
+		if err := c.Call(context.Background(), req, &rsp, client.WithAddress(addr)); err != nil {
+			t.Fatalf("Unexpected call error: %v", err)
+		}
+		if rsp.Data != data {
+			t.Fatalf("Expected response of %v bytes, got %v", len(data), len(rsp.Data))
+		}
+	}
+
+	encoding := func(headers []map[string]string) string {
+		tr.Lock()
+		defer tr.Unlock()
+		if len(headers) != 1 {
+			t.Fatalf("Expected one message, got %v", len(headers))
+		}
+		return headers[0][compress.EncodingKey]
+	}
+
+	large := strings.Repeat("hello world ", 1024)
+
+	// the first request isn't compressed since the server hasn't said what it
+	// accepts, but the response is
+	call(large)
+	if e := encoding(tr.sent); len(e) > 0 {
+		t.Fatalf("Expected the first request not to be compressed, got %v", e)
+	}
+	if e := encoding(tr.received); e != "zstd" {
+		t.Fatalf("Expected the response to be compressed with zstd, got %q", e)
+	}
+
+	// now the request is compressed too
+	tr.reset()
+	call(large)
+	if e := encoding(tr.sent); e != "zstd" {
+		t.Fatalf("Expected the request to be compressed with zstd, got %q", e)
+	}
+
+	// small messages aren't compressed
+	tr.reset()
+	call("hello")
+	if e := encoding(tr.sent); len(e) > 0 {
+		t.Fatalf("Expected small request not to be compressed, got %v", e)
+	}
+	if e := encoding(tr.received); len(e) > 0 {
+		t.Fatalf("Expected small response not to be compressed, got %v", e)
+	}
+
+	// published messages aren't compressed unless asked for
+	raw := make(chan *broker.Message, 1)
+	sub, err := brk.Subscribe("test.compress", func(m *broker.Message) error {
+		raw <- m
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	publish := func(c client.Client, encoding string) {
+		if err := c.Publish(context.Background(), c.NewMessage("test.compress", &TestPayload{Data: large})); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+
+		select {
+		case m := <-raw:
+			if e := m.Header[compress.EncodingKey]; e != encoding {
+				t.Fatalf("Expected the message to be compressed with %q, got %q", encoding, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+
+		select {
+		case data := <-published:
+			if data != large {
+				t.Fatalf("Expected message of %v bytes, got %v", len(large), len(data))
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for subscriber")
+		}
+	}
+
+	publish(c, "")
+
+	// and are decompressed by the subscriber when they are
+	publish(NewClient(
+		client.Broker(brk),
+		client.Transport(tr),
+		client.ContentType("application/json"),
+		client.PublishCompression("zstd"),
+	), "zstd")
+}
+
+func TestStreamCompression(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := &testTransport{Transport: tmemory.NewTransport()}
+
+	srv := smucp.NewServer(
+		server.Name("test.compress"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(reg),
+		server.Transport(tr),
+	)
+	if err := srv.Handle(srv.NewHandler(TestCompress{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	c := NewClient(client.Transport(tr), client.ContentType("application/json"))
+	req := c.NewRequest("test.compress", "TestCompress.EchoStream", &TestPayload{})
+	stream, err := c.Stream(context.Background(), req, client.WithAddress(srv.Options().Address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	large := strings.Repeat("hello world ", 1024)
+	echo := func() string {
+		tr.reset()
+		if err := stream.Send(&TestPayload{Data: large}); err != nil {
+			t.Fatalf("Unexpected send error: %v", err)
+		}
+		var rsp TestPayload
+		if err := stream.Recv(&rsp); err != nil {
+			t.Fatalf("Unexpected recv error: %v", err)
+		}
+		if rsp.Data != large {
+			t.Fatalf("Expected response of %v bytes, got %v", len(large), len(rsp.Data))
+		}
+
+		tr.Lock()
+		defer tr.Unlock()
+		return tr.sent[len(tr.sent)-1][compress.EncodingKey]
+	}
+
+	// the stream is opened before the server has said what it accepts, but the
+	// messages sent after its first response are compressed
+	if e := echo(); len(e) > 0 {
+		t.Fatalf("Expected the first message not to be compressed, got %v", e)
+	}
+	if e := echo(); e != "zstd" {
+		t.Fatalf("Expected the message to be compressed with zstd, got %q", e)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/micro/go-micro/v3/client"
 	"github.com/micro/go-micro/v3/errors"
@@ -11,6 +12,8 @@ import (
 	"github.com/micro/go-micro/v3/registry/memory"
 	"github.com/micro/go-micro/v3/router"
 	regRouter "github.com/micro/go-micro/v3/router/registry"
+	"github.com/micro/go-micro/v3/selector"
+	"github.com/micro/go-micro/v3/selector/roundrobin"
 )
 
 func newTestRouter() router.Router {
@@ -157,3 +160,125 @@ func TestCallWrapper(t *testing.T) {
 		t.Fatal("wrapper not called")
 	}
 }
+
+type testObserver struct {
+	selector.Selector
+	started []string
+	done    []time.Duration
+	errs    []error
+}
+
+func (o *testObserver) Start(route string) {
+	o.started = append(o.started, route)
+}
+
+func (o *testObserver) Done(route string, d time.Duration, err error) {
+	o.done = append(o.done, d)
+	o.errs = append(o.errs, err)
+}
+
+func TestCallObserver(t *testing.T) {
+	address := "10.1.10.1:8080"
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			time.Sleep(time.Millisecond * 10)
+			return errors.BadRequest("test.service", "bad request")
+		}
+	}
+
+	o := &testObserver{Selector: roundrobin.NewSelector()}
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Selector(o),
+		client.WrapCall(wrap),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+	if err := c.Call(context.Background(), req, nil, client.WithAddress(address), client.WithRetries(0)); err == nil {
+		t.Fatal("Expected call to return an error")
+	}
+
+	if len(o.started) != 1 || o.started[0] != address {
+		t.Fatalf("Expected call to %v to be started, got %v", address, o.started)
+	}
+	if len(o.done) != 1 || o.done[0] < time.Millisecond*10 {
+		t.Fatalf("Expected duration of the call to be observed, got %v", o.done)
+	}
+	if o.errs[0] == nil {
+		t.Fatal("Expected error of the call to be observed")
+	}
+}
+
+type testAffinity struct {
+	selector.Selector
+	affinity string
+}
+
+func (s *testAffinity) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	s.affinity = selector.NewSelectOptions(opts...).Affinity
+	return s.Selector.Select(routes, opts...)
+}
+
+func TestCallAffinity(t *testing.T) {
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			return nil
+		}
+	}
+
+	s := &testAffinity{Selector: roundrobin.NewSelector()}
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Selector(s),
+		client.WrapCall(wrap),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+	if err := c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1:8080"), client.WithAffinity("user-1")); err != nil {
+		t.Fatal(err)
+	}
+	if s.affinity != "user-1" {
+		t.Fatalf("Expected the affinity key to be passed to the selector, got %q", s.affinity)
+	}
+}
+
+func TestCallRetryBudget(t *testing.T) {
+	var called int
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			called++
+			return errors.InternalServerError("test.error", "retry request")
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+		client.Retries(3),
+		client.Backoff(func(ctx context.Context, req client.Request, attempts int) (time.Duration, error) {
+			return 0, nil
+		}),
+		client.RetryBudget(client.NewBudget(0, 1, time.Minute)),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+
+	// the first call uses the budget of a single retry
+	if err := c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1")); err == nil {
+		t.Fatal("Expected call to return an error")
+	}
+	if called != 2 {
+		t.Fatalf("Expected the call to be retried once, got %d calls", called)
+	}
+
+	// the budget is spent so the next call isn't retried
+	called = 0
+	if err := c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1")); err == nil {
+		t.Fatal("Expected call to return an error")
+	}
+	if called != 1 {
+		t.Fatalf("Expected the call not to be retried, got %d calls", called)
+	}
+}
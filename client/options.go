@@ -14,6 +14,7 @@ import (
 	regRouter "github.com/micro/go-micro/v3/router/registry"
 	"github.com/micro/go-micro/v3/selector"
 	"github.com/micro/go-micro/v3/selector/roundrobin"
+	"github.com/micro/go-micro/v3/util/compress"
 )
 
 type Options struct {
@@ -36,6 +37,14 @@ type Options struct {
 	PoolSize int
 	PoolTTL  time.Duration
 
+	// Compression encodings negotiated with servers, in order of preference
+	Compression []string
+	// CompressThreshold is the minimum size of message body compressed
+	CompressThreshold int
+	// PublishCompression is the encoding published messages are compressed
+	// with. Subscribers can't negotiate it, so it's off by default.
+	PublishCompression string
+
 	// Middleware for client
 	Wrappers []Wrapper
 
@@ -58,6 +67,10 @@ type CallOptions struct {
 	Retries int
 	// Check if retriable func
 	Retry RetryFunc
+	// RetryBudget limits the retries to each service
+	RetryBudget *Budget
+	// Hedge makes a second call to another node for slow calls
+	Hedge *Hedge
 	// Request/Response timeout
 	RequestTimeout time.Duration
 	// Router to use for this call
@@ -114,13 +127,15 @@ func NewOptions(options ...Option) Options {
 			RequestTimeout: DefaultRequestTimeout,
 			DialTimeout:    transport.DefaultDialTimeout,
 		},
-		Lookup:    LookupRoute,
-		PoolSize:  DefaultPoolSize,
-		PoolTTL:   DefaultPoolTTL,
-		Broker:    http.NewBroker(),
-		Router:    regRouter.NewRouter(),
-		Selector:  roundrobin.NewSelector(),
-		Transport: thttp.NewTransport(),
+		Lookup:            LookupRoute,
+		PoolSize:          DefaultPoolSize,
+		PoolTTL:           DefaultPoolTTL,
+		Compression:       compress.DefaultEncodings,
+		CompressThreshold: compress.DefaultThreshold,
+		Broker:            http.NewBroker(),
+		Router:            regRouter.NewRouter(),
+		Selector:          roundrobin.NewSelector(),
+		Transport:         thttp.NewTransport(),
 	}
 
 	for _, o := range options {
@@ -172,6 +187,30 @@ func PoolTTL(d time.Duration) Option {
 	}
 }
 
+// Compression sets the encodings used to compress message bodies, in order of
+// preference. Requests are compressed once a server has said which encodings
+// it accepts. Passing none turns compression off.
+func Compression(encodings ...string) Option {
+	return func(o *Options) {
+		o.Compression = encodings
+	}
+}
+
+// CompressThreshold sets the minimum size of message body compressed
+func CompressThreshold(n int) Option {
+	return func(o *Options) {
+		o.CompressThreshold = n
+	}
+}
+
+// PublishCompression sets the encoding published messages are compressed with.
+// Only use it when every subscriber to the topics can decompress the messages.
+func PublishCompression(encoding string) Option {
+	return func(o *Options) {
+		o.PublishCompression = encoding
+	}
+}
+
 // Transport to use for communication e.g http, rabbitmq, etc
 func Transport(t transport.Transport) Option {
 	return func(o *Options) {
@@ -244,6 +283,13 @@ func Retry(fn RetryFunc) Option {
 	}
 }
 
+// RetryBudget sets the budget which limits the retries to each service
+func RetryBudget(b *Budget) Option {
+	return func(o *Options) {
+		o.CallOptions.RetryBudget = b
+	}
+}
+
 // The request timeout.
 // Should this be a Call Option?
 func RequestTimeout(d time.Duration) Option {
@@ -320,6 +366,22 @@ func WithRetries(i int) CallOption {
 	}
 }
 
+// WithRetryBudget is a CallOption which overrides that which
+// set in Options.CallOptions
+func WithRetryBudget(b *Budget) CallOption {
+	return func(o *CallOptions) {
+		o.RetryBudget = b
+	}
+}
+
+// WithHedge is a CallOption which hedges the call, making a second call
+// to another node if it's slow. The endpoint called must be idempotent.
+func WithHedge(h *Hedge) CallOption {
+	return func(o *CallOptions) {
+		o.Hedge = h
+	}
+}
+
 // WithRequestTimeout is a CallOption which overrides that which
 // set in Options.CallOptions
 func WithRequestTimeout(d time.Duration) CallOption {
@@ -372,6 +434,17 @@ func WithSelector(s selector.Selector) CallOption {
 	}
 }
 
+// WithAffinity sets a key, such as a user or tenant id, so a selector which
+// supports affinity sends every request with the key to the same node
+func WithAffinity(key string) CallOption {
+	return func(o *CallOptions) {
+		// copy the options so the defaults aren't changed
+		sops := make([]selector.SelectOption, 0, len(o.SelectOptions)+1)
+		sops = append(sops, o.SelectOptions...)
+		o.SelectOptions = append(sops, selector.Affinity(key))
+	}
+}
+
 // WithSelectOptions sets the options to pass to the selector for this call
 func WithSelectOptions(sops ...selector.SelectOption) CallOption {
 	return func(o *CallOptions) {
@@ -0,0 +1,156 @@
+package avro
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/bytes"
+)
+
+var (
+	// ErrNoSchema is returned when encoding a type with no schema
+	ErrNoSchema = errors.New("avro: no schema for type")
+)
+
+// Schema is implemented by types which know their Avro schema, such as
+// those generated from an Avro schema definition
+type Schema interface {
+	Schema() string
+}
+
+// MessageSchema is the schema of a broker message. Register it to use avro as
+// the broker codec:
+//
+//	avro.Register(new(broker.Message), avro.MessageSchema)
+const MessageSchema = `{
+	"type": "record",
+	"name": "Message",
+	"namespace": "micro.broker",
+	"fields": [
+		{"name": "Header", "type": {"type": "map", "values": "string"}},
+		{"name": "Body", "type": "bytes"}
+	]
+}`
+
+type avroCodec struct {
+	schema *schema
+	codec  *goavro.Codec
+}
+
+var (
+	mtx sync.RWMutex
+	// schemas of the types registered
+	schemas = make(map[reflect.Type]string)
+	// codecs built for each schema
+	codecs = make(map[string]*avroCodec)
+)
+
+// Register the schema used for values of the type given, for types which don't
+// implement Schema. Fields of structs are matched to fields of records by their
+// json tag, or their name if they don't have one.
+func Register(v interface{}, schema string) error {
+	if _, err := newCodec(schema); err != nil {
+		return err
+	}
+
+	mtx.Lock()
+	schemas[typeOf(v)] = schema
+	mtx.Unlock()
+	return nil
+}
+
+func typeOf(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// codecFor returns the codec for the schema of the value
+func codecFor(v interface{}) (*avroCodec, error) {
+	if s, ok := v.(Schema); ok {
+		return newCodec(s.Schema())
+	}
+
+	mtx.RLock()
+	s, ok := schemas[typeOf(v)]
+	mtx.RUnlock()
+	if !ok {
+		return nil, ErrNoSchema
+	}
+	return newCodec(s)
+}
+
+func newCodec(s string) (*avroCodec, error) {
+	mtx.RLock()
+	c, ok := codecs[s]
+	mtx.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	parsed, err := parseSchema(s)
+	if err != nil {
+		return nil, err
+	}
+	gc, err := goavro.NewCodec(s)
+	if err != nil {
+		return nil, err
+	}
+	c = &avroCodec{schema: parsed, codec: gc}
+
+	mtx.Lock()
+	codecs[s] = c
+	mtx.Unlock()
+	return c, nil
+}
+
+// Marshaler encodes values as Avro binary using the schema of their type,
+// either from its Schema method or as registered with Register
+type Marshaler struct{}
+
+func (Marshaler) Marshal(v interface{}) ([]byte, error) {
+	if f, ok := v.(*bytes.Frame); ok {
+		return f.Data, nil
+	}
+
+	c, err := codecFor(v)
+	if err != nil {
+		return nil, err
+	}
+	n, err := native(c.schema, reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.BinaryFromNative(nil, n)
+}
+
+func (Marshaler) Unmarshal(data []byte, v interface{}) error {
+	if f, ok := v.(*bytes.Frame); ok {
+		f.Data = data
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return codec.ErrInvalidMessage
+	}
+
+	c, err := codecFor(v)
+	if err != nil {
+		return err
+	}
+	n, _, err := c.codec.NativeFromBinary(data)
+	if err != nil {
+		return err
+	}
+	return decode(c.schema, n, rv.Elem())
+}
+
+func (Marshaler) String() string {
+	return "avro"
+}
@@ -0,0 +1,473 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// logicalTypes goavro decodes into native Go types, named as their union branches are
+var logicalTypes = map[string]bool{
+	"long.timestamp-millis": true,
+	"long.timestamp-micros": true,
+	"int.time-millis":       true,
+	"long.time-micros":      true,
+	"int.date":              true,
+}
+
+// schema is the parsed form of an Avro schema, used to convert Go values to
+// and from the generic values goavro encodes
+type schema struct {
+	// primitive type, or one of record, enum, fixed, array, map or union
+	typ string
+	// full name of named types, or the type and its logical type
+	name     string
+	fields   []*field
+	items    *schema
+	values   *schema
+	branches []*schema
+}
+
+type field struct {
+	name   string
+	schema *schema
+}
+
+func parseSchema(s string) (*schema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	return parse(v, "", make(map[string]*schema))
+}
+
+func parse(v interface{}, ns string, names map[string]*schema) (*schema, error) {
+	switch t := v.(type) {
+	case string:
+		switch t {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &schema{typ: t, name: t}, nil
+		}
+		if s, ok := names[fullName(t, ns)]; ok {
+			return s, nil
+		}
+		if s, ok := names[t]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("avro: unknown type %q", t)
+	case []interface{}:
+		s := &schema{typ: "union", name: "union"}
+		for _, b := range t {
+			branch, err := parse(b, ns, names)
+			if err != nil {
+				return nil, err
+			}
+			s.branches = append(s.branches, branch)
+		}
+		return s, nil
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			return parse(t["type"], ns, names)
+		}
+
+		switch typ {
+		case "array":
+			items, err := parse(t["items"], ns, names)
+			if err != nil {
+				return nil, err
+			}
+			return &schema{typ: typ, name: typ, items: items}, nil
+		case "map":
+			values, err := parse(t["values"], ns, names)
+			if err != nil {
+				return nil, err
+			}
+			return &schema{typ: typ, name: typ, values: values}, nil
+		case "record", "error", "enum", "fixed":
+		default:
+			s, err := parse(typ, ns, names)
+			if err != nil {
+				return nil, err
+			}
+			if lt, ok := t["logicalType"].(string); ok && logicalTypes[typ+"."+lt] {
+				return &schema{typ: s.typ, name: typ + "." + lt}, nil
+			}
+			return s, nil
+		}
+
+		// named types
+		name, _ := t["name"].(string)
+		if n, ok := t["namespace"].(string); ok && !strings.Contains(name, ".") {
+			ns = n
+		}
+		s := &schema{typ: typ, name: fullName(name, ns)}
+		if typ == "error" {
+			s.typ = "record"
+		}
+		names[s.name] = s
+
+		if s.typ != "record" {
+			return s, nil
+		}
+
+		// fields are in the namespace of the record
+		if i := strings.LastIndex(s.name, "."); i > 0 {
+			ns = s.name[:i]
+		}
+		fields, _ := t["fields"].([]interface{})
+		for _, f := range fields {
+			fm, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("avro: invalid field in record %s", s.name)
+			}
+			fs, err := parse(fm["type"], ns, names)
+			if err != nil {
+				return nil, err
+			}
+			name, _ := fm["name"].(string)
+			s.fields = append(s.fields, &field{name: name, schema: fs})
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("avro: invalid schema %v", v)
+}
+
+func fullName(name, ns string) string {
+	if len(ns) == 0 || strings.Contains(name, ".") {
+		return name
+	}
+	return ns + "." + name
+}
+
+// indirect follows pointers and interfaces, returning false if it finds nil
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
+
+// native converts the value to the generic form goavro encodes for the schema
+func native(s *schema, v reflect.Value) (interface{}, error) {
+	v, ok := indirect(v)
+	if !ok {
+		if s.typ == "null" {
+			return nil, nil
+		}
+		if s.typ == "union" {
+			for _, b := range s.branches {
+				if b.typ == "null" {
+					return nil, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("avro: cannot encode nil as %s", s.name)
+	}
+
+	switch s.typ {
+	case "union":
+		for _, b := range s.branches {
+			if b.typ == "null" {
+				continue
+			}
+			if n, err := native(b, v); err == nil {
+				return goavro.Union(b.name, n), nil
+			}
+		}
+	case "boolean":
+		if v.Kind() == reflect.Bool {
+			return v.Bool(), nil
+		}
+	case "int", "long":
+		if v.Type() == timeType && logicalTypes[s.name] {
+			return v.Interface(), nil
+		}
+		var i int64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			i = int64(v.Uint())
+		default:
+			return nil, mismatch(s, v)
+		}
+		if s.typ == "int" {
+			return int32(i), nil
+		}
+		return i, nil
+	case "float", "double":
+		var f float64
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f = v.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		default:
+			return nil, mismatch(s, v)
+		}
+		if s.typ == "float" {
+			return float32(f), nil
+		}
+		return f, nil
+	case "string", "enum":
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+	case "bytes", "fixed":
+		switch {
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			return v.Bytes(), nil
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		case v.Kind() == reflect.String && s.typ == "bytes":
+			return []byte(v.String()), nil
+		}
+	case "array":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			break
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			n, err := native(s.items, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = n
+		}
+		return items, nil
+	case "map":
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		values := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			n, err := native(s.values, iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			values[iter.Key().String()] = n
+		}
+		return values, nil
+	case "record":
+		// fields missing from the value take the defaults in the schema
+		record := make(map[string]interface{}, len(s.fields))
+		for _, f := range s.fields {
+			fv, ok := lookup(v, f.name)
+			if !ok {
+				continue
+			}
+			n, err := native(f.schema, fv)
+			if err != nil {
+				return nil, fmt.Errorf("avro: field %s: %v", f.name, err)
+			}
+			record[f.name] = n
+		}
+		switch v.Kind() {
+		case reflect.Struct, reflect.Map:
+			return record, nil
+		}
+	case "null":
+	}
+	return nil, mismatch(s, v)
+}
+
+// decode sets the value from the generic form goavro decoded for the schema
+func decode(s *schema, n interface{}, v reflect.Value) error {
+	// unwrap the branch of a union
+	if s.typ == "union" && n != nil {
+		m, ok := n.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("avro: invalid union %v", n)
+		}
+		for name, d := range m {
+			for _, b := range s.branches {
+				if b.name == name {
+					return decode(b, d, v)
+				}
+			}
+			return fmt.Errorf("avro: unknown union branch %s", name)
+		}
+	}
+
+	if n == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(s, n, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(n))
+			return nil
+		}
+	}
+
+	switch d := n.(type) {
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(d)
+			return nil
+		}
+	case int32:
+		return decodeNumber(float64(d), int64(d), v, s)
+	case int64:
+		return decodeNumber(float64(d), d, v, s)
+	case float32:
+		return decodeNumber(float64(d), int64(d), v, s)
+	case float64:
+		return decodeNumber(d, int64(d), v, s)
+	case string:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(d)
+			return nil
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes([]byte(d))
+			return nil
+		}
+	case []byte:
+		switch {
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(append([]byte(nil), d...))
+			return nil
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			reflect.Copy(v, reflect.ValueOf(d))
+			return nil
+		case v.Kind() == reflect.String:
+			v.SetString(string(d))
+			return nil
+		}
+	case time.Time:
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(d))
+			return nil
+		}
+	case []interface{}:
+		switch v.Kind() {
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), len(d), len(d)))
+		case reflect.Array:
+		default:
+			return mismatch(s, v)
+		}
+		for i := 0; i < len(d) && i < v.Len(); i++ {
+			if err := decode(s.items, d[i], v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		switch {
+		case s.typ == "record" && v.Kind() == reflect.Struct:
+			for _, f := range s.fields {
+				fv, ok := lookup(v, f.name)
+				if !ok {
+					continue
+				}
+				if err := decode(f.schema, d[f.name], fv); err != nil {
+					return fmt.Errorf("avro: field %s: %v", f.name, err)
+				}
+			}
+			return nil
+		case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			values := s.values
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(d)))
+			for k, e := range d {
+				if s.typ == "record" {
+					values = nil
+					for _, f := range s.fields {
+						if f.name == k {
+							values = f.schema
+						}
+					}
+					if values == nil {
+						continue
+					}
+				}
+				ev := reflect.New(v.Type().Elem()).Elem()
+				if err := decode(values, e, ev); err != nil {
+					return err
+				}
+				v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), ev)
+			}
+			return nil
+		}
+	}
+	return mismatch(s, v)
+}
+
+func decodeNumber(f float64, i int64, v reflect.Value, s *schema) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(f)
+	default:
+		return mismatch(s, v)
+	}
+	return nil
+}
+
+// lookup returns the struct field or map entry for the record field name. Struct
+// fields are matched by their json tag, then their name, ignoring case.
+func lookup(v reflect.Value, name string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		e := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		return e, e.IsValid()
+	case reflect.Struct:
+	default:
+		return reflect.Value{}, false
+	}
+
+	t := v.Type()
+	fallback := -1
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if len(sf.PkgPath) > 0 {
+			continue
+		}
+		tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+		switch {
+		case tag == "-":
+			continue
+		case tag == name:
+			return v.Field(i), true
+		case len(tag) == 0 && sf.Name == name:
+			return v.Field(i), true
+		case fallback < 0 && len(tag) == 0 && strings.EqualFold(sf.Name, name):
+			fallback = i
+		}
+	}
+	if fallback < 0 {
+		return reflect.Value{}, false
+	}
+	return v.Field(fallback), true
+}
+
+func mismatch(s *schema, v reflect.Value) error {
+	return fmt.Errorf("avro: cannot convert %s to %s", v.Type(), s.name)
+}
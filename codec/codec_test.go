@@ -5,10 +5,12 @@ import (
 	"testing"
 
 	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/avro"
 	"github.com/micro/go-micro/v3/codec/bytes"
 	"github.com/micro/go-micro/v3/codec/grpc"
 	"github.com/micro/go-micro/v3/codec/json"
 	"github.com/micro/go-micro/v3/codec/jsonrpc"
+	"github.com/micro/go-micro/v3/codec/msgpack"
 	"github.com/micro/go-micro/v3/codec/proto"
 	"github.com/micro/go-micro/v3/codec/protorpc"
 	"github.com/micro/go-micro/v3/codec/text"
@@ -30,10 +32,12 @@ func (rwc *testRWC) Close() error {
 
 func getCodecs(c io.ReadWriteCloser) map[string]codec.Codec {
 	return map[string]codec.Codec{
+		"avro":     avro.NewCodec(c),
 		"bytes":    bytes.NewCodec(c),
 		"grpc":     grpc.NewCodec(c),
 		"json":     json.NewCodec(c),
 		"jsonrpc":  jsonrpc.NewCodec(c),
+		"msgpack":  msgpack.NewCodec(c),
 		"proto":    proto.NewCodec(c),
 		"protorpc": protorpc.NewCodec(c),
 		"text":     text.NewCodec(c),
@@ -0,0 +1,131 @@
+package codec_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/avro"
+	"github.com/micro/go-micro/v3/codec/bytes"
+	"github.com/micro/go-micro/v3/codec/msgpack"
+)
+
+type testAddress struct {
+	City string `json:"city"`
+}
+
+type testUser struct {
+	Id      int64             `json:"id"`
+	Name    string            `json:"name"`
+	Email   *string           `json:"email"`
+	Score   float64           `json:"score"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Address *testAddress      `json:"address"`
+	Created time.Time         `json:"created"`
+}
+
+const testUserSchema = `{
+	"type": "record",
+	"name": "User",
+	"namespace": "test",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"},
+		{"name": "email", "type": ["null", "string"], "default": null},
+		{"name": "score", "type": "double"},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "labels", "type": {"type": "map", "values": "string"}},
+		{"name": "address", "type": ["null", {
+			"type": "record",
+			"name": "Address",
+			"fields": [{"name": "city", "type": "string"}]
+		}], "default": null},
+		{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+	]
+}`
+
+func TestMarshalers(t *testing.T) {
+	if err := avro.Register(new(testUser), testUserSchema); err != nil {
+		t.Fatal(err)
+	}
+	if err := avro.Register(new(broker.Message), avro.MessageSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	email := "john@example.com"
+	users := []*testUser{
+		{
+			Id:      1,
+			Name:    "john",
+			Email:   &email,
+			Score:   1.5,
+			Tags:    []string{"a", "b"},
+			Labels:  map[string]string{"foo": "bar"},
+			Address: &testAddress{City: "London"},
+			Created: time.Unix(1600000000, 0).UTC(),
+		},
+		{
+			Id:      2,
+			Name:    "jane",
+			Tags:    []string{},
+			Labels:  map[string]string{},
+			Created: time.Unix(1600000000, 0).UTC(),
+		},
+	}
+
+	msg := &broker.Message{
+		Header: map[string]string{"foo": "bar"},
+		Body:   []byte("hello"),
+		Topic:  "test",
+	}
+
+	for _, m := range []codec.Marshaler{avro.Marshaler{}, msgpack.Marshaler{}} {
+		t.Run(m.String(), func(t *testing.T) {
+			for _, u := range users {
+				b, err := m.Marshal(u)
+				if err != nil {
+					t.Fatalf("Unexpected error marshaling %v: %v", u.Name, err)
+				}
+				var got testUser
+				if err := m.Unmarshal(b, &got); err != nil {
+					t.Fatalf("Unexpected error unmarshaling %v: %v", u.Name, err)
+				}
+				if !got.Created.Equal(u.Created) {
+					t.Fatalf("Expected created %v, got %v", u.Created, got.Created)
+				}
+				got.Created = u.Created
+				if !reflect.DeepEqual(&got, u) {
+					t.Fatalf("Expected %+v, got %+v", u, &got)
+				}
+			}
+
+			// broker messages are encoded without their topic
+			b, err := m.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Unexpected error marshaling message: %v", err)
+			}
+			var got broker.Message
+			if err := m.Unmarshal(b, &got); err != nil {
+				t.Fatalf("Unexpected error unmarshaling message: %v", err)
+			}
+			if got.Header["foo"] != "bar" || string(got.Body) != "hello" || len(got.Topic) > 0 {
+				t.Fatalf("Unexpected message %+v", got)
+			}
+
+			// frames are passed through
+			b, err = m.Marshal(&bytes.Frame{Data: []byte("raw")})
+			if err != nil || string(b) != "raw" {
+				t.Fatalf("Expected raw frame, got %q %v", b, err)
+			}
+		})
+	}
+}
+
+func TestAvroNoSchema(t *testing.T) {
+	if _, err := (avro.Marshaler{}).Marshal(&testAddress{}); err != avro.ErrNoSchema {
+		t.Fatalf("Expected %v, got %v", avro.ErrNoSchema, err)
+	}
+}
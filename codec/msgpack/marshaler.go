@@ -0,0 +1,45 @@
+package msgpack
+
+import (
+	"bytes"
+
+	"github.com/micro/go-micro/v3/codec"
+	mbytes "github.com/micro/go-micro/v3/codec/bytes"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// Marshaler encodes values as MessagePack. Struct fields are named by their
+// json tags, if they have them, so types shared with the json codec encode
+// the same fields under the same names.
+type Marshaler struct{}
+
+func (Marshaler) Marshal(v interface{}) ([]byte, error) {
+	if f, ok := v.(*mbytes.Frame); ok {
+		return f.Data, nil
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf).UseJSONTag(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Marshaler) Unmarshal(data []byte, v interface{}) error {
+	if v == nil {
+		return codec.ErrInvalidMessage
+	}
+	if f, ok := v.(*mbytes.Frame); ok {
+		f.Data = data
+		return nil
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.UseJSONTag(true)
+	return dec.Decode(v)
+}
+
+func (Marshaler) String() string {
+	return "msgpack"
+}
@@ -189,3 +189,14 @@ func FromError(err error) *Error {
 
 	return Parse(err.Error())
 }
+
+// IsFailure reports whether the error is a failure of the service which
+// returned it: a timeout, a server error or an error which isn't a go-micro
+// error. Other errors, such as bad requests, are returned by healthy services.
+func IsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := FromError(err).Code
+	return code == 0 || code == 408 || code >= 500
+}
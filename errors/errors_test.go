@@ -35,6 +35,26 @@ func TestEqual(t *testing.T) {
 
 }
 
+func TestIsFailure(t *testing.T) {
+	failures := []error{
+		InternalServerError("myid", "msg"),
+		Timeout("myid", "msg"),
+		ServiceUnavailable("myid", "msg"),
+		er.New("connection refused"),
+	}
+	for _, err := range failures {
+		if !IsFailure(err) {
+			t.Fatalf("%v must be a failure", err)
+		}
+	}
+
+	for _, err := range []error{nil, BadRequest("myid", "msg"), NotFound("myid", "msg")} {
+		if IsFailure(err) {
+			t.Fatalf("%v must not be a failure", err)
+		}
+	}
+}
+
 func TestErrors(t *testing.T) {
 	testData := []*Error{
 		{
@@ -2,9 +2,20 @@
 package events
 
 import (
-	"encoding/json"
 	"errors"
 	"time"
+
+	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/bytes"
+	"github.com/micro/go-micro/v3/codec/json"
+	"github.com/micro/go-micro/v3/codec/proto"
+)
+
+const (
+	// CodecKey is the metadata key the name of the codec the payload was encoded with is recorded under
+	CodecKey = "Micro-Codec"
+	// ContentTypeKey is the metadata key the content type of the payload is recorded under
+	ContentTypeKey = "Content-Type"
 )
 
 var (
@@ -12,6 +23,22 @@ var (
 	ErrMissingTopic = errors.New("Missing topic")
 	// ErrEncodingMessage is returned from publish if there was an error encoding the message option
 	ErrEncodingMessage = errors.New("Error encoding message")
+	// ErrNackUnsupported is returned from nack by streams which can only redeliver
+	// an event once the ack timeout has passed
+	ErrNackUnsupported = errors.New("Nack is not supported")
+
+	// Codecs used to decode payloads, by the name recorded in their metadata
+	Codecs = map[string]codec.Marshaler{
+		"json":  json.Marshaler{},
+		"proto": proto.Marshaler{},
+		"bytes": bytes.Marshaler{},
+	}
+	// ContentTypes of the payloads encoded by each codec
+	ContentTypes = map[string]string{
+		"json":  "application/json",
+		"proto": "application/protobuf",
+		"bytes": "application/octet-stream",
+	}
 )
 
 // Stream is an event streaming interface
@@ -20,10 +47,15 @@ type Stream interface {
 	Subscribe(topic string, opts ...SubscribeOption) (<-chan Event, error)
 }
 
-// Store is an event store interface
+// Store is an event store interface. Each topic is a log of events, numbered in the order they
+// were written, and consumer groups commit the offset they've processed the log up to.
 type Store interface {
 	Read(topic string, opts ...ReadOption) ([]*Event, error)
 	Write(event *Event, opts ...WriteOption) error
+	// Commit the offset of the last event in the topic the consumer group has processed
+	Commit(topic, group string, offset uint64) error
+	// Committed returns the offset last committed by the consumer group, or zero if none has been
+	Committed(topic, group string) (uint64, error)
 }
 
 // Event is the object returned by the broker when you subscribe to a topic
@@ -38,9 +70,82 @@ type Event struct {
 	Metadata map[string]string
 	// Payload contains the encoded message
 	Payload []byte
+	// Offset of the event in the topic, set when it's written to a store
+	Offset uint64
+
+	ackFunc  func() error
+	nackFunc func() error
 }
 
-// Unmarshal the events message into an object
+// Unmarshal the events message into an object, using the codec recorded in
+// its metadata or JSON if there isn't one
 func (e *Event) Unmarshal(v interface{}) error {
-	return json.Unmarshal(e.Payload, v)
+	if c, ok := Codecs[e.Metadata[CodecKey]]; ok {
+		return c.Unmarshal(e.Payload, v)
+	}
+	return json.Marshaler{}.Unmarshal(e.Payload, v)
+}
+
+// Encode a message being published with the codec in the options, recording the codec
+// and content type in the metadata. Messages which are already bytes are used as is;
+// their codec is recorded if one is set. It's intended for implementations of Stream.
+func Encode(msg interface{}, o *PublishOptions) ([]byte, error) {
+	c := o.Codec
+	payload, ok := msg.([]byte)
+	if !ok {
+		if c == nil {
+			c = json.Marshaler{}
+		}
+		p, err := c.Marshal(msg)
+		if err != nil {
+			return nil, ErrEncodingMessage
+		}
+		payload = p
+	}
+	if c == nil {
+		return payload, nil
+	}
+
+	// copy the metadata so the caller's isn't modified
+	md := make(map[string]string, len(o.Metadata)+2)
+	for k, v := range o.Metadata {
+		md[k] = v
+	}
+	md[CodecKey] = c.String()
+	if ct, ok := ContentTypes[c.String()]; ok {
+		md[ContentTypeKey] = ct
+	}
+	o.Metadata = md
+
+	return payload, nil
+}
+
+// Ack acknowledges the event was processed, so it won't be redelivered.
+// It's a no-op unless the subscription was made without auto ack.
+func (e *Event) Ack() error {
+	if e.ackFunc == nil {
+		return nil
+	}
+	return e.ackFunc()
+}
+
+// Nack rejects the event, so it's redelivered without waiting for the ack
+// timeout. It's a no-op unless the subscription was made without auto ack.
+// Streams which can't redeliver the event sooner return ErrNackUnsupported,
+// and the event is redelivered once the ack timeout has passed.
+func (e *Event) Nack() error {
+	if e.nackFunc == nil {
+		return nil
+	}
+	return e.nackFunc()
+}
+
+// SetAckFunc sets the function called by Ack, for use by stream implementations
+func (e *Event) SetAckFunc(f func() error) {
+	e.ackFunc = f
+}
+
+// SetNackFunc sets the function called by Nack, for use by stream implementations
+func (e *Event) SetNackFunc(f func() error) {
+	e.nackFunc = f
 }
@@ -1,6 +1,11 @@
 package events
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v3/codec"
+)
 
 // PublishOptions contains all the options which can be provided when publishing an event
 type PublishOptions struct {
@@ -8,6 +13,9 @@ type PublishOptions struct {
 	Metadata map[string]string
 	// Timestamp to set for the event, if the timestamp is a zero value, the current time will be used
 	Timestamp time.Time
+	// Codec used to encode the message, JSON if nil. The codec is recorded in the metadata of the
+	// event so it can be decoded by Unmarshal.
+	Codec codec.Marshaler
 }
 
 // PublishOption sets attributes on PublishOptions
@@ -27,6 +35,13 @@ func WithTimestamp(t time.Time) PublishOption {
 	}
 }
 
+// WithCodec sets the Codec field on PublishOptions
+func WithCodec(c codec.Marshaler) PublishOption {
+	return func(o *PublishOptions) {
+		o.Codec = c
+	}
+}
+
 // SubscribeOptions contains all the options which can be provided when subscribing to a topic
 type SubscribeOptions struct {
 	// Queue is the name of the subscribers queue, if two subscribers have the same queue the message
@@ -35,6 +50,20 @@ type SubscribeOptions struct {
 	// StartAtTime is the time from which the messages should be consumed from. If not provided then
 	// the messages will be consumed starting from the moment the Subscription starts.
 	StartAtTime time.Time
+	// AutoAck acknowledges events as soon as they're received. If false the consumer must call
+	// Ack on each event, otherwise it's redelivered once AckWait has passed.
+	AutoAck bool
+	// AckWait is how long to wait for an event to be acknowledged before redelivering it
+	AckWait time.Duration
+	// RetryLimit is the number of times an event is redelivered before giving up on it. A negative
+	// limit retries indefinitely.
+	RetryLimit int
+	// DeadLetterTopic is the topic events are published to once the retry limit is reached. If
+	// blank they're dropped.
+	DeadLetterTopic string
+	// Context ends the subscription once it's done. Events which haven't been acknowledged are
+	// redelivered to the other members of the queue.
+	Context context.Context
 }
 
 // SubscribeOption sets attributes on SubscribeOptions
@@ -54,6 +83,35 @@ func WithStartAtTime(t time.Time) SubscribeOption {
 	}
 }
 
+// WithAutoAck sets the AutoAck and AckWait fields on SubscribeOptions
+func WithAutoAck(ack bool, ackWait time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.AutoAck = ack
+		o.AckWait = ackWait
+	}
+}
+
+// WithRetryLimit sets the RetryLimit field on SubscribeOptions
+func WithRetryLimit(retries int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.RetryLimit = retries
+	}
+}
+
+// WithDeadLetterTopic sets the DeadLetterTopic field on SubscribeOptions
+func WithDeadLetterTopic(topic string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetterTopic = topic
+	}
+}
+
+// WithContext sets the Context field on SubscribeOptions, ending the subscription once it's done
+func WithContext(ctx context.Context) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Context = ctx
+	}
+}
+
 // WriteOptions contains all the options which can be provided when writing an event to a store
 type WriteOptions struct {
 	// TTL is the duration the event should be recorded for, a zero value TTL indicates the event should
@@ -77,6 +135,11 @@ type ReadOptions struct {
 	Limit uint
 	// Offset the results by this number, useful for paginated queries
 	Offset uint
+	// After replays the events written after the one at this offset in the topic, for
+	// example the offset a consumer group last committed
+	After uint64
+	// Since replays the events with a timestamp at or after this time
+	Since time.Time
 }
 
 // ReadOption sets attributes on ReadOptions
@@ -85,13 +148,27 @@ type ReadOption func(o *ReadOptions)
 // ReadLimit sets the limit attribute on ReadOptions
 func ReadLimit(l uint) ReadOption {
 	return func(o *ReadOptions) {
-		o.Limit = 1
+		o.Limit = l
 	}
 }
 
 // ReadOffset sets the offset attribute on ReadOptions
 func ReadOffset(l uint) ReadOption {
 	return func(o *ReadOptions) {
-		o.Offset = 1
+		o.Offset = l
+	}
+}
+
+// ReadAfter sets the After attribute on ReadOptions
+func ReadAfter(offset uint64) ReadOption {
+	return func(o *ReadOptions) {
+		o.After = offset
+	}
+}
+
+// ReadSince sets the Since attribute on ReadOptions
+func ReadSince(t time.Time) ReadOption {
+	return func(o *ReadOptions) {
+		o.Since = t
 	}
 }
@@ -8,7 +8,9 @@ import (
 
 type Options struct {
 	Store store.Store
-	TTL   time.Duration
+	// TTL of the events written, which are kept forever if it's zero so
+	// they can always be replayed
+	TTL time.Duration
 }
 
 type Option func(o *Options)
@@ -20,7 +22,7 @@ func WithStore(s store.Store) Option {
 	}
 }
 
-// WithTTL sets the default TTL
+// WithTTL sets the default TTL of the events written
 func WithTTL(ttl time.Duration) Option {
 	return func(o *Options) {
 		o.TTL = ttl
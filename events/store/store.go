@@ -2,7 +2,11 @@ package store
 
 import (
 	"encoding/json"
-	"time"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/micro/go-micro/v3/events"
 	gostore "github.com/micro/go-micro/v3/store"
@@ -10,7 +14,19 @@ import (
 	"github.com/pkg/errors"
 )
 
-const joinKey = "/"
+const (
+	joinKey = "/"
+
+	// suffixes of the tables the offsets are kept in
+	sequenceTable = "sequences"
+	offsetTable   = "offsets"
+
+	// number of records read at a time when filtering events
+	pageSize = 250
+
+	// metadata key marking an event keyed by its ID as being migrated
+	migratingKey = "Micro-Migrating"
+)
 
 // NewStore returns an initialized events store
 func NewStore(opts ...Option) events.Store {
@@ -19,19 +35,38 @@ func NewStore(opts ...Option) events.Store {
 	for _, o := range opts {
 		o(&options)
 	}
-	if options.TTL.Seconds() == 0 {
-		options.TTL = time.Hour * 24
-	}
 	if options.Store == nil {
 		options.Store = memory.NewStore()
 	}
 
 	// return the store
-	return &evStore{options}
+	return &evStore{opts: options, migrated: make(map[string]bool)}
 }
 
 type evStore struct {
 	opts Options
+
+	// serialises the offsets assigned by this process, conditional
+	// writes guard against those assigned by others
+	sync.Mutex
+
+	// the topics whose events keyed by ID have been migrated
+	mtx      sync.Mutex
+	migrated map[string]bool
+}
+
+// eventKey is the key of the event at the offset in the topic. The offset is
+// padded so the events of a topic are stored in the order they were written.
+func eventKey(topic string, offset uint64) string {
+	return fmt.Sprintf("%s%s%020d", topic, joinKey, offset)
+}
+
+// table returns the name of a table kept alongside the events
+func (s *evStore) table(name string) string {
+	if t := s.opts.Store.Options().Table; len(t) > 0 {
+		return t + "_" + name
+	}
+	return name
 }
 
 // Read events for a topic
@@ -50,17 +85,70 @@ func (s *evStore) Read(topic string, opts ...events.ReadOption) ([]*events.Event
 		o(&options)
 	}
 
-	// execute the request
-	recs, err := s.opts.Store.Read(topic+joinKey,
-		gostore.ReadPrefix(),
-		gostore.ReadLimit(options.Limit),
-		gostore.ReadOffset(options.Offset),
-	)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error reading from store")
+	if err := s.migrate(topic); err != nil {
+		return nil, err
+	}
+
+	// continue after the offset if replaying
+	var cursor string
+	if options.After > 0 {
+		cursor = gostore.Cursor(eventKey(topic, options.After))
+	}
+
+	// without a filter the limit and offset are applied by the store
+	if options.Since.IsZero() {
+		recs, err := s.opts.Store.Read(topic+joinKey,
+			gostore.ReadPrefix(),
+			gostore.ReadCursor(cursor),
+			gostore.ReadLimit(options.Limit),
+			gostore.ReadOffset(options.Offset),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading from store")
+		}
+		return unmarshalEvents(recs)
+	}
+
+	// otherwise page through the events until the limit is reached
+	var result []*events.Event
+	skip := options.Offset
+	for {
+		recs, err := s.opts.Store.Read(topic+joinKey,
+			gostore.ReadPrefix(),
+			gostore.ReadCursor(cursor),
+			gostore.ReadLimit(pageSize),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading from store")
+		}
+		evs, err := unmarshalEvents(recs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range evs {
+			if e.Timestamp.Before(options.Since) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			result = append(result, e)
+			if options.Limit > 0 && uint(len(result)) == options.Limit {
+				return result, nil
+			}
+		}
+
+		if len(recs) < pageSize {
+			return result, nil
+		}
+		cursor = gostore.Cursor(recs[len(recs)-1].Key)
 	}
+}
 
-	// unmarshal the result
+// unmarshalEvents from the records they're stored in
+func unmarshalEvents(recs []*gostore.Record) ([]*events.Event, error) {
 	result := make([]*events.Event, len(recs))
 	for i, r := range recs {
 		var e events.Event
@@ -69,12 +157,16 @@ func (s *evStore) Read(topic string, opts ...events.ReadOption) ([]*events.Event
 		}
 		result[i] = &e
 	}
-
 	return result, nil
 }
 
-// Write an event to the store
+// Write an event to the store. The event is given the next offset in its topic.
 func (s *evStore) Write(event *events.Event, opts ...events.WriteOption) error {
+	// validate the topic
+	if len(event.Topic) == 0 {
+		return events.ErrMissingTopic
+	}
+
 	// parse the options
 	options := events.WriteOptions{
 		TTL: s.opts.TTL,
@@ -83,13 +175,24 @@ func (s *evStore) Write(event *events.Event, opts ...events.WriteOption) error {
 		o(&options)
 	}
 
+	if err := s.migrate(event.Topic); err != nil {
+		return err
+	}
+
+	// assign the offset
+	offset, err := s.next(event.Topic)
+	if err != nil {
+		return errors.Wrap(err, "Error assigning the event offset")
+	}
+	event.Offset = offset
+
 	// construct the store record
 	bytes, err := json.Marshal(event)
 	if err != nil {
 		return errors.Wrap(err, "Error mashaling event to JSON")
 	}
 	record := &gostore.Record{
-		Key:    event.Topic + joinKey + event.ID,
+		Key:    eventKey(event.Topic, offset),
 		Value:  bytes,
 		Expiry: options.TTL,
 	}
@@ -101,3 +204,178 @@ func (s *evStore) Write(event *events.Event, opts ...events.WriteOption) error {
 
 	return nil
 }
+
+// migrate the events of the topic written before events were keyed by their
+// offset, when they were keyed by their ID. They're given the next offsets, in
+// the order of their timestamps, the first time the topic is used.
+func (s *evStore) migrate(topic string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.migrated[topic] {
+		return nil
+	}
+
+	keys, err := s.opts.Store.List(gostore.ListPrefix(topic + joinKey))
+	if err != nil {
+		return errors.Wrap(err, "Error listing events to migrate")
+	}
+
+	var recs []*gostore.Record
+	for _, k := range keys {
+		if !idKey(strings.TrimPrefix(k, topic+joinKey)) {
+			continue
+		}
+		r, err := s.opts.Store.Read(k)
+		if err == gostore.ErrNotFound {
+			continue
+		} else if err != nil {
+			return errors.Wrap(err, "Error reading events to migrate")
+		}
+		recs = append(recs, r...)
+	}
+
+	evs, err := unmarshalEvents(recs)
+	if err != nil {
+		return err
+	}
+	order := make([]int, len(recs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return evs[order[i]].Timestamp.Before(evs[order[j]].Timestamp)
+	})
+
+	for _, i := range order {
+		r := recs[i]
+		// claim the event, in case another process is migrating the topic too
+		if _, ok := r.Metadata[migratingKey]; ok {
+			continue
+		}
+		claim := &gostore.Record{Key: r.Key, Value: r.Value, Expiry: r.Expiry, Metadata: map[string]interface{}{migratingKey: true}}
+		switch err := s.opts.Store.Write(claim, gostore.WriteIfVersion(r.Version)); err {
+		case nil, gostore.ErrConditionNotSupported:
+		case gostore.ErrVersionMismatch:
+			continue
+		default:
+			return errors.Wrap(err, "Error migrating event")
+		}
+
+		offset, err := s.next(topic)
+		if err != nil {
+			return errors.Wrap(err, "Error assigning the event offset")
+		}
+		evs[i].Offset = offset
+		bytes, err := json.Marshal(evs[i])
+		if err != nil {
+			return errors.Wrap(err, "Error mashaling event to JSON")
+		}
+		if err := s.opts.Store.Write(&gostore.Record{Key: eventKey(topic, offset), Value: bytes, Expiry: r.Expiry}); err != nil {
+			return errors.Wrap(err, "Error migrating event")
+		}
+		if err := s.opts.Store.Delete(r.Key); err != nil {
+			return errors.Wrap(err, "Error migrating event")
+		}
+	}
+
+	s.migrated[topic] = true
+	return nil
+}
+
+// idKey reports whether the suffix of the key of an event after its topic is
+// the ID of the event, rather than its offset
+func idKey(suffix string) bool {
+	if len(suffix) == 0 || strings.Contains(suffix, joinKey) {
+		return false
+	}
+	if len(suffix) != len(eventKey("", 0))-len(joinKey) {
+		return true
+	}
+	_, err := strconv.ParseUint(suffix, 10, 64)
+	return err != nil
+}
+
+// next increments the sequence of the topic, returning the offset for the next event
+func (s *evStore) next(topic string) (uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	table := gostore.WriteTo("", s.table(sequenceTable))
+
+	for {
+		var seq, version uint64
+		recs, err := s.opts.Store.Read(topic, gostore.ReadFrom("", s.table(sequenceTable)))
+		found := err == nil && len(recs) > 0
+		if found {
+			if seq, err = strconv.ParseUint(string(recs[0].Value), 10, 64); err != nil {
+				return 0, err
+			}
+			version = recs[0].Version
+		} else if err != nil && err != gostore.ErrNotFound {
+			return 0, err
+		}
+
+		// only write the sequence if nobody else has since it was read
+		record := &gostore.Record{Key: topic, Value: []byte(strconv.FormatUint(seq+1, 10))}
+		switch {
+		case version > 0:
+			err = s.opts.Store.Write(record, table, gostore.WriteIfVersion(version))
+		case !found:
+			err = s.opts.Store.Write(record, table, gostore.WriteIfNotExists())
+		default:
+			err = s.opts.Store.Write(record, table)
+		}
+
+		switch err {
+		case nil:
+			return seq + 1, nil
+		case gostore.ErrExists, gostore.ErrVersionMismatch:
+			// another process took the offset
+			continue
+		case gostore.ErrConditionNotSupported:
+			if err := s.opts.Store.Write(record, table); err != nil {
+				return 0, err
+			}
+			return seq + 1, nil
+		default:
+			return 0, err
+		}
+	}
+}
+
+// Commit the offset the consumer group has processed the topic up to
+func (s *evStore) Commit(topic, group string, offset uint64) error {
+	if len(topic) == 0 {
+		return events.ErrMissingTopic
+	}
+
+	record := &gostore.Record{
+		Key:   topic + joinKey + group,
+		Value: []byte(strconv.FormatUint(offset, 10)),
+	}
+	if err := s.opts.Store.Write(record, gostore.WriteTo("", s.table(offsetTable))); err != nil {
+		return errors.Wrap(err, "Error writing to the store")
+	}
+	return nil
+}
+
+// Committed returns the offset last committed by the consumer group
+func (s *evStore) Committed(topic, group string) (uint64, error) {
+	if len(topic) == 0 {
+		return 0, events.ErrMissingTopic
+	}
+
+	recs, err := s.opts.Store.Read(topic+joinKey+group, gostore.ReadFrom("", s.table(offsetTable)))
+	if err == gostore.ErrNotFound || (err == nil && len(recs) == 0) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.Wrap(err, "Error reading from store")
+	}
+
+	offset, err := strconv.ParseUint(string(recs[0].Value), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "Invalid offset returned from store")
+	}
+	return offset, nil
+}
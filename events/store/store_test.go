@@ -1,10 +1,14 @@
 package store
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/events"
+	gostore "github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,4 +49,118 @@ func TestStore(t *testing.T) {
 		assert.Nilf(t, err, "No error should be returned")
 		assert.Len(t, evs, 1, "The result should include no more than the read limit")
 	})
+
+	// offsets should be honoured
+	t.Run("ReadTopicOffset", func(t *testing.T) {
+		evs, err := store.Read("foo", events.ReadOffset(1))
+		assert.Nilf(t, err, "No error should be returned")
+		assert.Len(t, evs, 1, "The result should skip the offset")
+		assert.Equal(t, testData[1].ID, evs[0].ID, "The events should be returned in the order they were written")
+	})
+}
+
+func TestStoreReplay(t *testing.T) {
+	store := NewStore()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		ev := &events.Event{ID: uuid.New().String(), Topic: "foo", Timestamp: start.Add(time.Duration(i) * time.Minute)}
+		assert.Nilf(t, store.Write(ev), "Writing an event should not return an error")
+		assert.Equal(t, uint64(i+1), ev.Offset, "Events should be given the next offset in the topic")
+	}
+
+	// replay after an offset
+	t.Run("ReadAfter", func(t *testing.T) {
+		evs, err := store.Read("foo", events.ReadAfter(3))
+		assert.Nilf(t, err, "No error should be returned")
+		if assert.Len(t, evs, 2, "Only the events after the offset should be returned") {
+			assert.Equal(t, uint64(4), evs[0].Offset)
+			assert.Equal(t, uint64(5), evs[1].Offset)
+		}
+	})
+
+	// replay from a timestamp
+	t.Run("ReadSince", func(t *testing.T) {
+		evs, err := store.Read("foo", events.ReadSince(start.Add(time.Minute*2)), events.ReadLimit(2))
+		assert.Nilf(t, err, "No error should be returned")
+		if assert.Len(t, evs, 2, "The result should include no more than the read limit") {
+			assert.Equal(t, uint64(3), evs[0].Offset)
+			assert.Equal(t, uint64(4), evs[1].Offset)
+		}
+	})
+
+	// consumer groups track their own offsets
+	t.Run("Commit", func(t *testing.T) {
+		offset, err := store.Committed("foo", "group")
+		assert.Nilf(t, err, "No error should be returned")
+		assert.Equal(t, uint64(0), offset, "Nothing should be committed yet")
+
+		assert.Nilf(t, store.Commit("foo", "group", 4), "Committing should not return an error")
+
+		offset, err = store.Committed("foo", "group")
+		assert.Nilf(t, err, "No error should be returned")
+		assert.Equal(t, uint64(4), offset, "The committed offset should be returned")
+
+		offset, err = store.Committed("foo", "other")
+		assert.Nilf(t, err, "No error should be returned")
+		assert.Equal(t, uint64(0), offset, "Each group should have its own offset")
+	})
+}
+
+func TestStoreTTL(t *testing.T) {
+	st := memory.NewStore()
+	store := NewStore(WithStore(st))
+
+	for i := 0; i < 3; i++ {
+		assert.Nilf(t, store.Write(&events.Event{ID: uuid.New().String(), Topic: "foo"}), "Writing an event should not return an error")
+	}
+
+	// the events are kept so they can be replayed from the start
+	recs, err := st.Read(eventKey("foo", 1))
+	if assert.Nilf(t, err, "No error should be returned") && assert.Len(t, recs, 1) {
+		assert.Equal(t, time.Duration(0), recs[0].Expiry, "Events should not expire by default")
+	}
+	evs, err := store.Read("foo", events.ReadAfter(0))
+	assert.Nilf(t, err, "No error should be returned")
+	assert.Len(t, evs, 3, "All the events should be replayed")
+
+	// unless a TTL is set
+	store = NewStore(WithStore(st), WithTTL(time.Hour))
+	assert.Nilf(t, store.Write(&events.Event{ID: uuid.New().String(), Topic: "foo"}), "Writing an event should not return an error")
+	recs, err = st.Read(eventKey("foo", 4))
+	if assert.Nilf(t, err, "No error should be returned") && assert.Len(t, recs, 1) {
+		assert.True(t, recs[0].Expiry > 0, "Events should expire after the TTL")
+	}
+}
+
+func TestStoreMigrate(t *testing.T) {
+	st := memory.NewStore()
+
+	// events written when they were keyed by their ID
+	now := time.Now()
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ev := &events.Event{ID: uuid.New().String(), Topic: "foo", Timestamp: now.Add(time.Duration(i) * time.Second)}
+		bytes, err := json.Marshal(ev)
+		assert.Nilf(t, err, "No error should be returned")
+		assert.Nilf(t, st.Write(&gostore.Record{Key: "foo" + joinKey + ev.ID, Value: bytes}), "No error should be returned")
+		ids = append(ids, ev.ID)
+	}
+
+	// they're given offsets in the order they were written
+	store := NewStore(WithStore(st))
+	assert.Nilf(t, store.Write(&events.Event{ID: uuid.New().String(), Topic: "foo"}), "Writing an event should not return an error")
+
+	evs, err := store.Read("foo", events.ReadAfter(1))
+	assert.Nilf(t, err, "No error should be returned")
+	if assert.Len(t, evs, 3, "The events after the offset should be read") {
+		assert.Equal(t, ids[1], evs[0].ID)
+		assert.Equal(t, uint64(2), evs[0].Offset)
+		assert.Equal(t, ids[2], evs[1].ID)
+		assert.Equal(t, uint64(4), evs[2].Offset)
+	}
+
+	keys, err := st.List(gostore.ListPrefix("foo" + joinKey))
+	assert.Nilf(t, err, "No error should be returned")
+	assert.Len(t, keys, 4, "The events keyed by ID should be removed")
 }
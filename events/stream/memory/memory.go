@@ -1,15 +1,14 @@
 package memory
 
 import (
-	"encoding/json"
-	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/events"
+	evstore "github.com/micro/go-micro/v3/events/store"
 	"github.com/micro/go-micro/v3/logger"
-	"github.com/micro/go-micro/v3/store"
 	"github.com/micro/go-micro/v3/store/memory"
 	"github.com/pkg/errors"
 )
@@ -25,17 +24,36 @@ func NewStream(opts ...Option) (events.Stream, error) {
 		options.Store = memory.NewStore()
 	}
 
-	return &mem{store: options.Store}, nil
+	return &mem{store: evstore.NewStore(evstore.WithStore(options.Store))}, nil
 }
 
+var (
+	// DefaultAckWait is how long to wait for an event to be acknowledged before redelivering it
+	DefaultAckWait = time.Second * 30
+)
+
 type subscriber struct {
 	Queue   string
 	Topic   string
 	Channel chan events.Event
+	Options events.SubscribeOptions
+
+	// done is closed once the subscription ends, nil if it never does
+	done <-chan struct{}
+}
+
+// closed returns true once the subscription has ended
+func (s *subscriber) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
 }
 
 type mem struct {
-	store store.Store
+	store events.Store
 
 	subs []*subscriber
 	sync.RWMutex
@@ -56,15 +74,9 @@ func (m *mem) Publish(topic string, msg interface{}, opts ...events.PublishOptio
 	}
 
 	// encode the message if it's not already encoded
-	var payload []byte
-	if p, ok := msg.([]byte); ok {
-		payload = p
-	} else {
-		p, err := json.Marshal(msg)
-		if err != nil {
-			return events.ErrEncodingMessage
-		}
-		payload = p
+	payload, err := events.Encode(msg, &options)
+	if err != nil {
+		return err
 	}
 
 	// construct the event
@@ -76,15 +88,8 @@ func (m *mem) Publish(topic string, msg interface{}, opts ...events.PublishOptio
 		Payload:   payload,
 	}
 
-	// serialize the event to bytes
-	bytes, err := json.Marshal(event)
-	if err != nil {
-		return errors.Wrap(err, "Error encoding event")
-	}
-
 	// write to the store
-	key := fmt.Sprintf("%v/%v", event.Topic, event.ID)
-	if err := m.store.Write(&store.Record{Key: key, Value: bytes}); err != nil {
+	if err := m.store.Write(event); err != nil {
 		return errors.Wrap(err, "Error writing event to store")
 	}
 
@@ -102,17 +107,26 @@ func (m *mem) Subscribe(topic string, opts ...events.SubscribeOption) (<-chan ev
 
 	// parse the options
 	options := events.SubscribeOptions{
-		Queue: uuid.New().String(),
+		Queue:      uuid.New().String(),
+		AutoAck:    true,
+		RetryLimit: -1,
 	}
 	for _, o := range opts {
 		o(&options)
 	}
+	if options.AckWait <= 0 {
+		options.AckWait = DefaultAckWait
+	}
 
 	// setup the subscriber
 	sub := &subscriber{
 		Channel: make(chan events.Event),
 		Topic:   topic,
 		Queue:   options.Queue,
+		Options: options,
+	}
+	if options.Context != nil {
+		sub.done = options.Context.Done()
 	}
 
 	// register the subscriber
@@ -120,6 +134,14 @@ func (m *mem) Subscribe(topic string, opts ...events.SubscribeOption) (<-chan ev
 	m.subs = append(m.subs, sub)
 	m.Unlock()
 
+	// deregister the subscriber once the subscription ends
+	if sub.done != nil {
+		go func() {
+			<-sub.done
+			m.unsubscribe(sub)
+		}()
+	}
+
 	// lookup previous events if the start time option was passed
 	if options.StartAtTime.Unix() > 0 {
 		go m.lookupPreviousEvents(sub, options.StartAtTime)
@@ -129,29 +151,44 @@ func (m *mem) Subscribe(topic string, opts ...events.SubscribeOption) (<-chan ev
 	return sub.Channel, nil
 }
 
+// unsubscribe removes the subscriber so no more events are sent to it
+func (m *mem) unsubscribe(sub *subscriber) {
+	m.Lock()
+	defer m.Unlock()
+
+	subs := make([]*subscriber, 0, len(m.subs))
+	for _, s := range m.subs {
+		if s != sub {
+			subs = append(subs, s)
+		}
+	}
+	m.subs = subs
+}
+
 // lookupPreviousEvents finds events for a subscriber which occured before a given time and sends
 // them into the subscribers channel
 func (m *mem) lookupPreviousEvents(sub *subscriber, startTime time.Time) {
-	// lookup all events which match the topic (a blank topic will return all results)
-	recs, err := m.store.Read(sub.Topic+"/", store.ReadPrefix())
-	if err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
-		logger.Errorf("Error looking up previous events: %v", err)
-		return
-	} else if err != nil {
-		return
-	}
-
-	// loop through the records and send it to the channel if it matches
-	for _, r := range recs {
-		var ev events.Event
-		if err := json.Unmarshal(r.Value, &ev); err != nil {
-			continue
+	// replay the events of the topic since the start time, a page at a time
+	var after uint64
+	for {
+		evs, err := m.store.Read(sub.Topic, events.ReadSince(startTime), events.ReadAfter(after))
+		if err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error looking up previous events: %v", err)
+			return
+		} else if err != nil {
+			return
 		}
-		if ev.Timestamp.Unix() < startTime.Unix() {
-			continue
+		if len(evs) == 0 {
+			return
 		}
 
-		sub.Channel <- ev
+		for _, ev := range evs {
+			if sub.closed() {
+				return
+			}
+			m.sendEvent(ev, sub)
+		}
+		after = evs[len(evs)-1].Offset
 	}
 }
 
@@ -173,9 +210,107 @@ func (m *mem) handleEvent(ev *events.Event) {
 	}
 
 	// send the message to each channel async (since one channel might be blocked)
-	for _, sub := range subs {
-		go func(s *subscriber) {
-			s.Channel <- *ev
-		}(sub)
+	for _, sub := range filteredSubs {
+		go m.sendEvent(ev, sub)
+	}
+}
+
+// sendEvent delivers the event to the subscriber. Unless the subscriber auto acks, it's
+// redelivered to a member of the same queue until it's acknowledged or the retry limit is
+// reached, at which point it's published to the dead letter topic.
+func (m *mem) sendEvent(ev *events.Event, sub *subscriber) {
+	if sub.Options.AutoAck {
+		select {
+		case sub.Channel <- *ev:
+		case <-sub.done:
+		}
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		// the first call to ack or nack decides the outcome of the delivery
+		result := make(chan bool, 1)
+		evCopy := *ev
+		evCopy.SetAckFunc(func() error {
+			select {
+			case result <- true:
+			default:
+			}
+			return nil
+		})
+		evCopy.SetNackFunc(func() error {
+			select {
+			case result <- false:
+			default:
+			}
+			return nil
+		})
+
+		select {
+		case sub.Channel <- evCopy:
+		case <-sub.done:
+			// the subscription ended before the event was sent, so send it to another member of
+			// the queue if there is one
+			if sub = m.queueSubscriber(sub); sub.closed() {
+				return
+			}
+			attempt--
+			continue
+		}
+
+		var acked bool
+		select {
+		case acked = <-result:
+		case <-time.After(sub.Options.AckWait):
+		}
+		if acked {
+			return
+		}
+
+		if limit := sub.Options.RetryLimit; limit >= 0 && attempt >= limit {
+			m.deadLetter(ev, sub)
+			return
+		}
+
+		// redeliver to any member of the queue
+		if sub = m.queueSubscriber(sub); sub.closed() {
+			return
+		}
+	}
+}
+
+// queueSubscriber returns a random subscriber in the same queue as sub, skipping those whose
+// subscription has ended
+func (m *mem) queueSubscriber(sub *subscriber) *subscriber {
+	m.RLock()
+	defer m.RUnlock()
+
+	var members []*subscriber
+	for _, s := range m.subs {
+		if s.Queue == sub.Queue && s.Topic == sub.Topic && !s.closed() {
+			members = append(members, s)
+		}
+	}
+	if len(members) == 0 {
+		return sub
+	}
+	return members[rand.Intn(len(members))]
+}
+
+// deadLetter publishes an event which reached the retry limit to the dead letter topic
+func (m *mem) deadLetter(ev *events.Event, sub *subscriber) {
+	if len(sub.Options.DeadLetterTopic) == 0 {
+		if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+			logger.Warnf("Dropping event %v on %v after %v retries", ev.ID, ev.Topic, sub.Options.RetryLimit)
+		}
+		return
+	}
+
+	err := m.Publish(sub.Options.DeadLetterTopic, ev.Payload,
+		events.WithMetadata(ev.Metadata),
+		events.WithTimestamp(ev.Timestamp),
+	)
+	if err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+		logger.Errorf("Error publishing event %v to dead letter topic %v: %v", ev.ID, sub.Options.DeadLetterTopic, err)
 	}
 }
@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -44,7 +45,8 @@ func TestStream(t *testing.T) {
 			select {
 			case event, _ := <-evChan:
 				assert.NotNilf(t, event, "The message was nil")
-				assert.Equal(t, event.Metadata, metadata, "Metadata didn't match")
+				assert.Equal(t, event.Metadata["foo"], metadata["foo"], "Metadata didn't match")
+				assert.Equal(t, event.Metadata[events.CodecKey], "json", "Codec wasn't recorded")
 
 				var result testPayload
 				err = event.Unmarshal(&result)
@@ -86,7 +88,8 @@ func TestStream(t *testing.T) {
 			select {
 			case event, _ := <-evChan1:
 				assert.NotNilf(t, event, "The message was nil")
-				assert.Equal(t, event.Metadata, metadata, "Metadata didn't match")
+				assert.Equal(t, event.Metadata["foo"], metadata["foo"], "Metadata didn't match")
+				assert.Equal(t, event.Metadata[events.CodecKey], "json", "Codec wasn't recorded")
 
 				var result testPayload
 				err = event.Unmarshal(&result)
@@ -116,7 +119,8 @@ func TestStream(t *testing.T) {
 			select {
 			case event, _ := <-evChan2:
 				assert.NotNilf(t, event, "The message was nil")
-				assert.Equal(t, event.Metadata, metadata, "Metadata didn't match")
+				assert.Equal(t, event.Metadata["foo"], metadata["foo"], "Metadata didn't match")
+				assert.Equal(t, event.Metadata[events.CodecKey], "json", "Codec wasn't recorded")
 
 				var result testPayload
 				err = event.Unmarshal(&result)
@@ -133,3 +137,104 @@ func TestStream(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+func TestAckNack(t *testing.T) {
+	stream, err := NewStream()
+	assert.Nilf(t, err, "NewStream should not return an error")
+
+	topic := uuid.New().String()
+	deadLetter := uuid.New().String()
+
+	evChan, err := stream.Subscribe(topic,
+		events.WithAutoAck(false, time.Millisecond*50),
+		events.WithRetryLimit(1),
+		events.WithDeadLetterTopic(deadLetter),
+	)
+	assert.Nilf(t, err, "Subscribe should not return an error")
+	dlChan, err := stream.Subscribe(deadLetter)
+	assert.Nilf(t, err, "Subscribe should not return an error")
+
+	receive := func(c <-chan events.Event) *events.Event {
+		select {
+		case ev := <-c:
+			return &ev
+		case <-time.After(time.Millisecond * 250):
+			return nil
+		}
+	}
+
+	// an acknowledged event isn't redelivered
+	assert.Nil(t, stream.Publish(topic, &testPayload{Message: "acked"}))
+	ev := receive(evChan)
+	if assert.NotNil(t, ev, "Event was not recieved") {
+		assert.Nil(t, ev.Ack())
+	}
+	assert.Nil(t, receive(evChan), "An acknowledged event should not be redelivered")
+
+	// an event which isn't acknowledged is redelivered after the ack wait, then
+	// sent to the dead letter topic once the retry limit is reached
+	assert.Nil(t, stream.Publish(topic, &testPayload{Message: "rejected"}))
+	ev = receive(evChan)
+	assert.NotNil(t, ev, "Event was not recieved")
+
+	ev = receive(evChan)
+	if assert.NotNil(t, ev, "Event was not redelivered") {
+		assert.Nil(t, ev.Nack())
+	}
+
+	ev = receive(dlChan)
+	if assert.NotNil(t, ev, "Event was not sent to the dead letter topic") {
+		var result testPayload
+		assert.Nil(t, ev.Unmarshal(&result))
+		assert.Equal(t, "rejected", result.Message)
+	}
+	assert.Nil(t, receive(evChan), "The event should not be redelivered after the retry limit")
+}
+
+func TestSubscribeContext(t *testing.T) {
+	stream, err := NewStream()
+	assert.Nilf(t, err, "NewStream should not return an error")
+
+	topic := uuid.New().String()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// two members of a queue, one of which stops without taking the event off its channel
+	stopped, err := stream.Subscribe(topic,
+		events.WithQueue(topic),
+		events.WithAutoAck(false, time.Millisecond*50),
+		events.WithContext(ctx),
+	)
+	assert.Nilf(t, err, "Subscribe should not return an error")
+	assert.Nil(t, stream.Publish(topic, &testPayload{Message: "first"}))
+	time.Sleep(time.Millisecond * 10)
+
+	running, err := stream.Subscribe(topic,
+		events.WithQueue(topic),
+		events.WithAutoAck(false, time.Millisecond*50),
+	)
+	assert.Nilf(t, err, "Subscribe should not return an error")
+	cancel()
+
+	// the event is sent to the member still running, as are those published after
+	for _, msg := range []string{"first", "second"} {
+		if msg == "second" {
+			assert.Nil(t, stream.Publish(topic, &testPayload{Message: msg}))
+		}
+
+		select {
+		case ev := <-running:
+			var result testPayload
+			assert.Nil(t, ev.Unmarshal(&result))
+			assert.Equal(t, msg, result.Message)
+			assert.Nil(t, ev.Ack())
+		case <-time.After(time.Millisecond * 250):
+			t.Fatalf("Event %v was not sent to the member still running", msg)
+		}
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("No events should be sent once the subscription has ended")
+	case <-time.After(time.Millisecond * 100):
+	}
+}
@@ -73,15 +73,9 @@ func (s *stream) Publish(topic string, msg interface{}, opts ...events.PublishOp
 	}
 
 	// encode the message if it's not already encoded
-	var payload []byte
-	if p, ok := msg.([]byte); ok {
-		payload = p
-	} else {
-		p, err := json.Marshal(msg)
-		if err != nil {
-			return events.ErrEncodingMessage
-		}
-		payload = p
+	payload, err := events.Encode(msg, &options)
+	if err != nil {
+		return err
 	}
 
 	// construct the event
@@ -116,12 +110,20 @@ func (s *stream) Subscribe(topic string, opts ...events.SubscribeOption) (<-chan
 
 	// parse the options
 	options := events.SubscribeOptions{
-		Queue: uuid.New().String(),
+		Queue:      uuid.New().String(),
+		AutoAck:    true,
+		RetryLimit: -1,
 	}
 	for _, o := range opts {
 		o(&options)
 	}
 
+	// the subscription ends once the context is done, if one was passed
+	var done <-chan struct{}
+	if options.Context != nil {
+		done = options.Context.Done()
+	}
+
 	// setup the subscriber
 	c := make(chan events.Event)
 	handleMsg := func(m *stan.Msg) {
@@ -135,8 +137,34 @@ func (s *stream) Subscribe(topic string, opts ...events.SubscribeOption) (<-chan
 			return
 		}
 
+		if !options.AutoAck {
+			// give up on the message once the retry limit is reached
+			if options.RetryLimit >= 0 && int(m.RedeliveryCount) > options.RetryLimit {
+				s.deadLetter(&evt, options.DeadLetterTopic)
+				if err := m.Ack(); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("Error acknowledging message: %v", err)
+				}
+				return
+			}
+
+			// the consumer acknowledges the message, nats redelivers it after the ack wait
+			// if it doesn't, and it can't be redelivered any sooner
+			evt.SetAckFunc(m.Ack)
+			evt.SetNackFunc(func() error { return events.ErrNackUnsupported })
+			select {
+			case c <- evt:
+			case <-done:
+			}
+			return
+		}
+
 		// push onto the channel and wait for the consumer to take the event off before we acknowledge it.
-		c <- evt
+		select {
+		case c <- evt:
+		case <-done:
+			// nats redelivers the message since it's not acknowledged
+			return
+		}
 
 		if err := m.Ack(); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
 			logger.Errorf("Error acknowledging message: %v", err)
@@ -151,12 +179,41 @@ func (s *stream) Subscribe(topic string, opts ...events.SubscribeOption) (<-chan
 	if options.StartAtTime.Unix() > 0 {
 		stan.StartAtTime(options.StartAtTime)
 	}
+	if !options.AutoAck && options.AckWait > 0 {
+		subOpts = append(subOpts, stan.AckWait(options.AckWait))
+	}
 
 	// connect the subscriber
-	_, err := s.conn.QueueSubscribe(topic, options.Queue, handleMsg, subOpts...)
+	sub, err := s.conn.QueueSubscribe(topic, options.Queue, handleMsg, subOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error subscribing to topic")
 	}
 
+	// close the subscription once it ends, keeping the durable queue so the events which haven't
+	// been acknowledged are redelivered
+	if done != nil {
+		go func() {
+			<-done
+			if err := sub.Close(); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error closing subscription to %v: %v", topic, err)
+			}
+		}()
+	}
+
 	return c, nil
 }
+
+// deadLetter publishes a message which reached the retry limit to the dead letter topic
+func (s *stream) deadLetter(ev *events.Event, topic string) {
+	if len(topic) == 0 {
+		if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+			logger.Warnf("Dropping event %v on %v after reaching the retry limit", ev.ID, ev.Topic)
+		}
+		return
+	}
+
+	err := s.Publish(topic, ev.Payload, events.WithMetadata(ev.Metadata), events.WithTimestamp(ev.Timestamp))
+	if err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+		logger.Errorf("Error publishing event %v to dead letter topic %v: %v", ev.ID, topic, err)
+	}
+}
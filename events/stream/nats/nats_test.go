@@ -59,7 +59,8 @@ func TestStream(t *testing.T) {
 			select {
 			case event, _ := <-evChan:
 				assert.NotNilf(t, event, "The message was nil")
-				assert.Equal(t, event.Metadata, metadata, "Metadata didn't match")
+				assert.Equal(t, event.Metadata["foo"], metadata["foo"], "Metadata didn't match")
+				assert.Equal(t, event.Metadata[events.CodecKey], "json", "Codec wasn't recorded")
 
 				var result testPayload
 				err = event.Unmarshal(&result)
@@ -101,7 +102,8 @@ func TestStream(t *testing.T) {
 			select {
 			case event, _ := <-evChan1:
 				assert.NotNilf(t, event, "The message was nil")
-				assert.Equal(t, event.Metadata, metadata, "Metadata didn't match")
+				assert.Equal(t, event.Metadata["foo"], metadata["foo"], "Metadata didn't match")
+				assert.Equal(t, event.Metadata[events.CodecKey], "json", "Codec wasn't recorded")
 
 				var result testPayload
 				err = event.Unmarshal(&result)
@@ -131,7 +133,8 @@ func TestStream(t *testing.T) {
 			select {
 			case event, _ := <-evChan2:
 				assert.NotNilf(t, event, "The message was nil")
-				assert.Equal(t, event.Metadata, metadata, "Metadata didn't match")
+				assert.Equal(t, event.Metadata["foo"], metadata["foo"], "Metadata didn't match")
+				assert.Equal(t, event.Metadata[events.CodecKey], "json", "Codec wasn't recorded")
 
 				var result testPayload
 				err = event.Unmarshal(&result)
@@ -2,11 +2,25 @@
 package model
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/micro/go-micro/v3/codec"
 	"github.com/micro/go-micro/v3/store"
 	"github.com/micro/go-micro/v3/sync"
 )
 
+var (
+	// ErrMissingName is returned when a query doesn't specify the entity name
+	ErrMissingName = errors.New("missing entity name")
+	// ErrConflict is returned when an entity is updated from a stale version
+	ErrConflict = errors.New("version conflict")
+)
+
+// VersionKey is the record metadata key holding the version of an entity
+const VersionKey = "_version"
+
 // Model provides an interface for data modelling
 type Model interface {
 	// Initialise options
@@ -36,6 +50,13 @@ type Entity interface {
 	Attributes() map[string]interface{}
 }
 
+// Versioned is implemented by entities which track the version they were
+// read at. Updating a versioned entity fails with ErrConflict if it has
+// since been updated by another writer.
+type Versioned interface {
+	Version() uint64
+}
+
 type Options struct {
 	// Database to write to
 	Database string
@@ -45,14 +66,62 @@ type Options struct {
 	Sync sync.Sync
 	// for storage
 	Store store.Store
+	// Indexes maintained on entity attributes
+	Indexes []Index
+	// Context should contain all implementation specific options
+	Context context.Context
 }
 
 type Option func(o *Options)
 
-type ReadOptions struct{}
+// Index is a secondary index on an entity attribute
+type Index struct {
+	// Name of the entity to index
+	Name string
+	// Field is the attribute to index
+	Field string
+	// Unique rejects entities which duplicate the value of another
+	Unique bool
+}
+
+// DuplicateError is returned when a write would violate a unique index
+type DuplicateError struct {
+	// Name of the entity
+	Name string
+	// Field of the unique index
+	Field string
+	// Value which already exists
+	Value interface{}
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate value %v for unique index on %s.%s", e.Value, e.Name, e.Field)
+}
+
+type ReadOptions struct {
+	// Name of the entity to read
+	Name string
+	// Id of a single entity to read
+	Id string
+	// Filters which must all match
+	Filters []Filter
+	// Order of the results, by default ordered by id
+	Order []Order
+	// Limit the number of results
+	Limit uint
+	// Offset into the results, used with Limit for pagination
+	Offset uint
+}
 
 type ReadOption func(o *ReadOptions)
 
-type DeleteOptions struct{}
+type DeleteOptions struct {
+	// Name of the entity to delete
+	Name string
+	// Id of a single entity to delete
+	Id string
+	// Filters which must all match
+	Filters []Filter
+}
 
 type DeleteOption func(o *DeleteOptions)
@@ -4,6 +4,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/codec"
 	"github.com/micro/go-micro/v3/model"
+	"github.com/micro/go-micro/v3/store"
 )
 
 type mudEntity struct {
@@ -12,6 +13,9 @@ type mudEntity struct {
 	value      interface{}
 	codec      codec.Marshaler
 	attributes map[string]interface{}
+	version    uint64
+	// whether the value was loaded from the store, so is already encoded
+	encoded bool
 }
 
 func (m *mudEntity) Attributes() map[string]interface{} {
@@ -30,15 +34,23 @@ func (m *mudEntity) Value() interface{} {
 	return m.value
 }
 
+func (m *mudEntity) Version() uint64 {
+	return m.version
+}
+
 func (m *mudEntity) Read(v interface{}) error {
 	switch m.value.(type) {
 	case []byte:
 		b := m.value.([]byte)
 		return m.codec.Unmarshal(b, v)
 	default:
-		v = m.value
+		// round trip the value through the codec to copy it into v
+		b, err := m.codec.Marshal(m.value)
+		if err != nil {
+			return err
+		}
+		return m.codec.Unmarshal(b, v)
 	}
-	return nil
 }
 
 func newEntity(name string, value interface{}, codec codec.Marshaler) model.Entity {
@@ -50,3 +62,69 @@ func newEntity(name string, value interface{}, codec codec.Marshaler) model.Enti
 		attributes: make(map[string]interface{}),
 	}
 }
+
+// loadEntity creates an entity from a stored record
+func loadEntity(name string, r *store.Record, codec codec.Marshaler) *mudEntity {
+	var version uint64
+	attributes := make(map[string]interface{}, len(r.Metadata))
+	for k, v := range r.Metadata {
+		if k == model.VersionKey {
+			version = toVersion(v)
+			continue
+		}
+		attributes[k] = v
+	}
+
+	return &mudEntity{
+		id:         r.Key,
+		name:       name,
+		value:      r.Value,
+		codec:      codec,
+		attributes: attributes,
+		version:    version,
+		encoded:    true,
+	}
+}
+
+// withAttributes returns a copy of the entity with the attributes given
+func withAttributes(e model.Entity, attrs map[string]interface{}) *mudEntity {
+	return &mudEntity{
+		id:         e.Id(),
+		name:       e.Name(),
+		value:      e.Value(),
+		attributes: attrs,
+	}
+}
+
+// metadata returns the record metadata for the entity at the given version
+func metadata(e model.Entity, version uint64) map[string]interface{} {
+	md := make(map[string]interface{}, len(e.Attributes())+1)
+	for k, v := range e.Attributes() {
+		md[k] = v
+	}
+	md[model.VersionKey] = version
+	return md
+}
+
+// setVersion records the version an entity was written at
+func setVersion(e model.Entity, version uint64) {
+	if me, ok := e.(*mudEntity); ok {
+		me.version = version
+	}
+}
+
+// toVersion converts a stored version, which may have been
+// decoded from JSON as a float, back to an integer
+func toVersion(v interface{}) uint64 {
+	switch t := v.(type) {
+	case uint64:
+		return t
+	case int:
+		return uint64(t)
+	case int64:
+		return uint64(t)
+	case float64:
+		return uint64(t)
+	}
+	return 0
+}
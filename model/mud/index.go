@@ -0,0 +1,135 @@
+package mud
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/micro/go-micro/v3/model"
+	"github.com/micro/go-micro/v3/store"
+)
+
+// indexes returns the indexes declared for the entity name
+func (m *mudModel) indexes(name string) []model.Index {
+	var idx []model.Index
+	for _, i := range m.options.Indexes {
+		if i.Name == name {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// indexTable is the table index entries for the field are stored in
+func indexTable(name, field string) string {
+	return fmt.Sprintf("%s_%s_index", name, field)
+}
+
+// indexValue encodes an attribute value for use in an index key.
+// Numbers are normalised so values which compare equal share a key.
+func indexValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case float32:
+		s = strconv.FormatFloat(float64(t), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		s = fmt.Sprint(v)
+	}
+	// escape the value so it never contains the separator
+	return url.QueryEscape(s) + "/"
+}
+
+// lookup returns the ids of the entities whose indexed field is equal to value
+func (m *mudModel) lookup(idx model.Index, value interface{}) ([]string, error) {
+	prefix := indexValue(value)
+	keys, err := m.options.Store.List(
+		store.ListPrefix(prefix),
+		store.ListFrom(m.options.Database, indexTable(idx.Name, idx.Field)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ids = append(ids, strings.TrimPrefix(k, prefix))
+	}
+	return ids, nil
+}
+
+// checkUnique returns a DuplicateError if another entity has the same value
+// for any unique index
+func (m *mudModel) checkUnique(e model.Entity) error {
+	for _, idx := range m.indexes(e.Name()) {
+		if !idx.Unique {
+			continue
+		}
+		v, ok := e.Attributes()[idx.Field]
+		if !ok {
+			continue
+		}
+		ids, err := m.lookup(idx, v)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id != e.Id() {
+				return &model.DuplicateError{Name: idx.Name, Field: idx.Field, Value: v}
+			}
+		}
+	}
+	return nil
+}
+
+// writeIndexes adds index entries for the entity attributes
+func (m *mudModel) writeIndexes(tx store.Tx, e model.Entity) error {
+	for _, idx := range m.indexes(e.Name()) {
+		v, ok := e.Attributes()[idx.Field]
+		if !ok {
+			continue
+		}
+		err := tx.Write(&store.Record{
+			Key:   indexValue(v) + e.Id(),
+			Value: []byte(e.Id()),
+		}, store.WriteTo(m.options.Database, indexTable(idx.Name, idx.Field)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteIndexes removes index entries for the entity attributes
+func (m *mudModel) deleteIndexes(tx store.Tx, e model.Entity) error {
+	for _, idx := range m.indexes(e.Name()) {
+		v, ok := e.Attributes()[idx.Field]
+		if !ok {
+			continue
+		}
+		err := tx.Delete(
+			indexValue(v)+e.Id(),
+			store.DeleteFrom(m.options.Database, indexTable(idx.Name, idx.Field)),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexed returns the first equality filter on an indexed field
+func (m *mudModel) indexed(name string, filters []model.Filter) (model.Index, model.Filter, bool) {
+	for _, idx := range m.indexes(name) {
+		for _, f := range filters {
+			if f.Field == idx.Field && f.Op == model.Equal {
+				return idx, f, true
+			}
+		}
+	}
+	return model.Index{}, model.Filter{}, false
+}
@@ -2,6 +2,8 @@
 package mud
 
 import (
+	"sort"
+
 	"github.com/micro/go-micro/v3/codec/json"
 	"github.com/micro/go-micro/v3/model"
 	"github.com/micro/go-micro/v3/store"
@@ -26,24 +28,52 @@ func (m *mudModel) NewEntity(name string, value interface{}) model.Entity {
 }
 
 func (m *mudModel) Create(e model.Entity) error {
-	// lock on the name of entity
-	if err := m.options.Sync.Lock(e.Name()); err != nil {
+	// lock on the entity and its unique values
+	unlock, err := m.lock(e.Name() + "/" + e.Id())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	unlockUnique, err := m.lockUnique(e)
+	if err != nil {
+		return err
+	}
+	defer unlockUnique()
+
+	if err := m.checkUnique(e); err != nil {
+		return err
+	}
+
+	v, err := m.encode(e)
+	if err != nil {
 		return err
 	}
-	// TODO: deal with the error
-	defer m.options.Sync.Unlock(e.Name())
 
-	// TODO: potentially add encode to entity?
-	v, err := m.options.Codec.Marshal(e.Value())
+	// write the entity and its index entries together
+	tx, err := m.begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// TODO: include metadata and set database
-	return m.options.Store.Write(&store.Record{
-		Key:   e.Id(),
-		Value: v,
+	err = tx.Write(&store.Record{
+		Key:      e.Id(),
+		Value:    v,
+		Metadata: metadata(e, 1),
 	}, store.WriteTo(m.options.Database, e.Name()))
+	if err != nil {
+		return err
+	}
+	if err := m.writeIndexes(tx, e); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	setVersion(e, 1)
+	return nil
 }
 
 func (m *mudModel) Read(opts ...model.ReadOption) ([]model.Entity, error) {
@@ -51,31 +81,118 @@ func (m *mudModel) Read(opts ...model.ReadOption) ([]model.Entity, error) {
 	for _, o := range opts {
 		o(&options)
 	}
-	// TODO: implement the options that allow querying
-	return nil, nil
+
+	if len(options.Name) == 0 {
+		return nil, model.ErrMissingName
+	}
+
+	entities, err := m.query(options.Name, options.Id, options.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(options.Order) > 0 {
+		sort.SliceStable(entities, func(i, j int) bool {
+			return less(entities[i], entities[j], options.Order)
+		})
+	}
+
+	// apply the offset and limit
+	if options.Offset >= uint(len(entities)) {
+		return nil, nil
+	}
+	entities = entities[options.Offset:]
+	if options.Limit > 0 && options.Limit < uint(len(entities)) {
+		entities = entities[:options.Limit]
+	}
+
+	return entities, nil
 }
 
 func (m *mudModel) Update(e model.Entity) error {
-	// TODO: read out the record first, update the fields and store
+	// lock on the entity alone so updates of others aren't blocked
+	unlock, err := m.lock(e.Name() + "/" + e.Id())
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	// lock on the name of entity
-	if err := m.options.Sync.Lock(e.Name()); err != nil {
+	// read the existing entity to merge with and replace its index entries
+	old, err := m.query(e.Name(), e.Id(), nil)
+	if err != nil {
 		return err
 	}
-	// TODO: deal with the error
-	defer m.options.Sync.Unlock(e.Name())
 
-	// TODO: potentially add encode to entity?
-	v, err := m.options.Codec.Marshal(e.Value())
+	v, err := m.encode(e)
+	if err != nil {
+		return err
+	}
+
+	var version uint64
+	// the entity as it's written
+	var merged model.Entity = e
+
+	if len(old) > 0 {
+		prev := old[0].(*mudEntity)
+		if ve, ok := e.(model.Versioned); ok && ve.Version() > 0 && ve.Version() != prev.version {
+			return model.ErrConflict
+		}
+		version = prev.version
+
+		// merge the fields of the update into the stored value
+		v, err = m.merge(prev.value.([]byte), v)
+		if err != nil {
+			return err
+		}
+		// and the attributes, leaving those of the entity given as they are
+		attrs := make(map[string]interface{}, len(prev.attributes)+len(e.Attributes()))
+		for k, a := range prev.attributes {
+			attrs[k] = a
+		}
+		for k, a := range e.Attributes() {
+			attrs[k] = a
+		}
+		merged = withAttributes(e, attrs)
+	}
+
+	unlockUnique, err := m.lockUnique(merged)
 	if err != nil {
 		return err
 	}
+	defer unlockUnique()
 
-	// TODO: include metadata and set database
-	return m.options.Store.Write(&store.Record{
-		Key:   e.Id(),
-		Value: v,
+	if err := m.checkUnique(merged); err != nil {
+		return err
+	}
+
+	tx, err := m.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.Write(&store.Record{
+		Key:      e.Id(),
+		Value:    v,
+		Metadata: metadata(merged, version+1),
 	}, store.WriteTo(m.options.Database, e.Name()))
+	if err != nil {
+		return err
+	}
+	for _, o := range old {
+		if err := m.deleteIndexes(tx, o); err != nil {
+			return err
+		}
+	}
+	if err := m.writeIndexes(tx, merged); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	setVersion(e, version+1)
+	return nil
 }
 
 func (m *mudModel) Delete(opts ...model.DeleteOption) error {
@@ -83,14 +200,228 @@ func (m *mudModel) Delete(opts ...model.DeleteOption) error {
 	for _, o := range opts {
 		o(&options)
 	}
-	// TODO: implement the options that allow deleting
-	return nil
+
+	if len(options.Name) == 0 {
+		return model.ErrMissingName
+	}
+
+	// no need to read the record when deleting by id alone
+	// unless its index entries must be removed
+	if len(options.Id) > 0 && len(options.Filters) == 0 && len(m.indexes(options.Name)) == 0 {
+		return m.options.Store.Delete(options.Id, store.DeleteFrom(m.options.Database, options.Name))
+	}
+
+	entities, err := m.query(options.Name, options.Id, options.Filters)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, e := range entities {
+		if err := tx.Delete(e.Id(), store.DeleteFrom(m.options.Database, options.Name)); err != nil {
+			return err
+		}
+		if err := m.deleteIndexes(tx, e); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (m *mudModel) String() string {
 	return "mud"
 }
 
+// directTx writes straight to a store which doesn't support transactions
+type directTx struct {
+	store.Store
+}
+
+func (directTx) Commit() error {
+	return nil
+}
+
+func (directTx) Rollback() error {
+	return nil
+}
+
+// begin a transaction on the store so an entity and its index entries are
+// written together, falling back to writing directly if it's unsupported
+func (m *mudModel) begin() (store.Tx, error) {
+	tx, err := store.Begin(m.options.Store)
+	if err == store.ErrTxNotSupported {
+		return directTx{m.options.Store}, nil
+	}
+	return tx, err
+}
+
+// lock acquires the lock with the given id and returns a func to release it
+func (m *mudModel) lock(id string) (func(), error) {
+	if err := m.options.Sync.Lock(id); err != nil {
+		return nil, err
+	}
+	// TODO: deal with the error
+	return func() { m.options.Sync.Unlock(id) }, nil
+}
+
+// lockUnique locks the values of the entity's unique indexes so concurrent
+// writers can't both claim the same value. The locks are taken in order,
+// always after the entity lock, so writers can't deadlock.
+func (m *mudModel) lockUnique(e model.Entity) (func(), error) {
+	var ids []string
+	for _, idx := range m.indexes(e.Name()) {
+		if !idx.Unique {
+			continue
+		}
+		if v, ok := e.Attributes()[idx.Field]; ok {
+			ids = append(ids, indexTable(idx.Name, idx.Field)+"/"+indexValue(v))
+		}
+	}
+	sort.Strings(ids)
+
+	var unlocks []func()
+	unlock := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for _, id := range ids {
+		u, err := m.lock(id)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		unlocks = append(unlocks, u)
+	}
+
+	return unlock, nil
+}
+
+// encode the value of an entity. The value of an entity read from the store
+// is already encoded, so it's used as it is.
+func (m *mudModel) encode(e model.Entity) ([]byte, error) {
+	if me, ok := e.(*mudEntity); ok && me.encoded {
+		return me.value.([]byte), nil
+	}
+	return m.options.Codec.Marshal(e.Value())
+}
+
+// merge overlays the fields of an updated value on the stored value.
+// Values which don't decode to an object are replaced outright.
+func (m *mudModel) merge(stored, update []byte) ([]byte, error) {
+	var prev, next map[string]interface{}
+	if err := m.options.Codec.Unmarshal(stored, &prev); err != nil || prev == nil {
+		return update, nil
+	}
+	if err := m.options.Codec.Unmarshal(update, &next); err != nil || next == nil {
+		return update, nil
+	}
+	for k, v := range next {
+		prev[k] = v
+	}
+	return m.options.Codec.Marshal(prev)
+}
+
+// query returns the entities with the given name which match all the filters
+func (m *mudModel) query(name, id string, filters []model.Filter) ([]model.Entity, error) {
+	var records []*store.Record
+	var err error
+
+	if len(id) > 0 {
+		records, err = m.options.Store.Read(id, store.ReadFrom(m.options.Database, name))
+	} else if idx, f, ok := m.indexed(name, filters); ok {
+		records, err = m.readIndexed(idx, f.Value)
+	} else {
+		records, err = m.options.Store.Read("", store.ReadPrefix(), store.ReadFrom(m.options.Database, name))
+	}
+	if err == store.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entities []model.Entity
+
+	for _, r := range records {
+		e := loadEntity(name, r, m.options.Codec)
+		if !matches(e.attributes, filters) {
+			continue
+		}
+		entities = append(entities, e)
+	}
+
+	return entities, nil
+}
+
+// readIndexed reads the records whose indexed field is equal to value
+func (m *mudModel) readIndexed(idx model.Index, value interface{}) ([]*store.Record, error) {
+	ids, err := m.lookup(idx, value)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*store.Record, 0, len(ids))
+	for _, id := range ids {
+		recs, err := m.options.Store.Read(id, store.ReadFrom(m.options.Database, idx.Name))
+		if err == store.ErrNotFound {
+			// a stale index entry
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	return records, nil
+}
+
+// matches returns true if the attributes satisfy all the filters
+func matches(attrs map[string]interface{}, filters []model.Filter) bool {
+	for _, f := range filters {
+		if !f.Match(attrs) {
+			return false
+		}
+	}
+	return true
+}
+
+// less orders two entities by the given fields. Entities missing
+// an attribute are ordered before those which have it.
+func less(a, b model.Entity, order []model.Order) bool {
+	for _, o := range order {
+		va, oka := a.Attributes()[o.Field]
+		vb, okb := b.Attributes()[o.Field]
+
+		var c int
+		switch {
+		case !oka && !okb:
+			continue
+		case !oka:
+			c = -1
+		case !okb:
+			c = 1
+		default:
+			c, _ = model.Compare(va, vb)
+		}
+
+		if c == 0 {
+			continue
+		}
+		if o.Descending {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
 func NewModel(opts ...model.Option) model.Model {
 	options := model.Options{
 		Codec: new(json.Marshaler),
@@ -98,6 +429,10 @@ func NewModel(opts ...model.Option) model.Model {
 		Store: memory.NewStore(),
 	}
 
+	for _, o := range opts {
+		o(&options)
+	}
+
 	return &mudModel{
 		options: options,
 	}
@@ -0,0 +1,326 @@
+package mud
+
+import (
+	"testing"
+
+	"github.com/micro/go-micro/v3/model"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func createUsers(t *testing.T, m model.Model, users ...user) []model.Entity {
+	var entities []model.Entity
+	for _, u := range users {
+		e := m.NewEntity("user", u)
+		e.Attributes()["name"] = u.Name
+		e.Attributes()["age"] = u.Age
+		if err := m.Create(e); err != nil {
+			t.Fatalf("Unexpected error creating entity: %v", err)
+		}
+		entities = append(entities, e)
+	}
+	return entities
+}
+
+func names(entities []model.Entity) []string {
+	var n []string
+	for _, e := range entities {
+		n = append(n, e.Attributes()["name"].(string))
+	}
+	return n
+}
+
+func TestRead(t *testing.T) {
+	m := NewModel()
+	created := createUsers(t, m,
+		user{Name: "alice", Age: 30},
+		user{Name: "bob", Age: 25},
+		user{Name: "carol", Age: 35},
+		user{Name: "dave", Age: 25},
+	)
+
+	if _, err := m.Read(); err != model.ErrMissingName {
+		t.Fatalf("Expected ErrMissingName, got %v", err)
+	}
+
+	tt := []struct {
+		name   string
+		opts   []model.ReadOption
+		expect []string
+	}{
+		{
+			name:   "equal",
+			opts:   []model.ReadOption{model.ReadEquals("age", 25), model.ReadOrder("name", false)},
+			expect: []string{"bob", "dave"},
+		},
+		{
+			name:   "range",
+			opts:   []model.ReadOption{model.ReadWhere("age", model.GreaterThan, 25), model.ReadWhere("age", model.LessThanOrEqual, 35), model.ReadOrder("age", false)},
+			expect: []string{"alice", "carol"},
+		},
+		{
+			name:   "order",
+			opts:   []model.ReadOption{model.ReadOrder("age", true), model.ReadOrder("name", false)},
+			expect: []string{"carol", "alice", "bob", "dave"},
+		},
+		{
+			name:   "limit and offset",
+			opts:   []model.ReadOption{model.ReadOrder("name", false), model.ReadOffset(1), model.ReadLimit(2)},
+			expect: []string{"bob", "carol"},
+		},
+		{
+			name:   "offset past the end",
+			opts:   []model.ReadOption{model.ReadOffset(10)},
+			expect: nil,
+		},
+		{
+			name:   "id",
+			opts:   []model.ReadOption{model.ReadId(created[2].Id())},
+			expect: []string{"carol"},
+		},
+		{
+			name:   "missing id",
+			opts:   []model.ReadOption{model.ReadId("missing")},
+			expect: nil,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			entities, err := m.Read(append(tc.opts, model.ReadFrom("user"))...)
+			if err != nil {
+				t.Fatalf("Unexpected error reading entities: %v", err)
+			}
+			got := names(entities)
+			if len(got) != len(tc.expect) {
+				t.Fatalf("Expected %v, got %v", tc.expect, got)
+			}
+			for i := range got {
+				if got[i] != tc.expect[i] {
+					t.Fatalf("Expected %v, got %v", tc.expect, got)
+				}
+			}
+		})
+	}
+
+	// check the value can be decoded
+	entities, err := m.Read(model.ReadFrom("user"), model.ReadEquals("name", "alice"))
+	if err != nil || len(entities) != 1 {
+		t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+	}
+	var u user
+	if err := entities[0].(*mudEntity).Read(&u); err != nil {
+		t.Fatalf("Unexpected error decoding entity: %v", err)
+	}
+	if u.Name != "alice" || u.Age != 30 {
+		t.Fatalf("Unexpected value %+v", u)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	m := NewModel()
+	created := createUsers(t, m,
+		user{Name: "alice", Age: 30},
+		user{Name: "bob", Age: 25},
+		user{Name: "carol", Age: 35},
+	)
+
+	if err := m.Delete(model.DeleteFrom("user"), model.DeleteWhere("age", model.LessThan, 31)); err != nil {
+		t.Fatalf("Unexpected error deleting entities: %v", err)
+	}
+
+	entities, err := m.Read(model.ReadFrom("user"))
+	if err != nil {
+		t.Fatalf("Unexpected error reading entities: %v", err)
+	}
+	if got := names(entities); len(got) != 1 || got[0] != "carol" {
+		t.Fatalf("Expected [carol], got %v", got)
+	}
+
+	if err := m.Delete(model.DeleteFrom("user"), model.DeleteId(created[2].Id())); err != nil {
+		t.Fatalf("Unexpected error deleting entity: %v", err)
+	}
+
+	entities, err = m.Read(model.ReadFrom("user"))
+	if err != nil {
+		t.Fatalf("Unexpected error reading entities: %v", err)
+	}
+	if len(entities) != 0 {
+		t.Fatalf("Expected no entities, got %v", names(entities))
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	s := memory.NewStore()
+	m := NewModel(
+		model.Store(s),
+		model.Indexes(
+			model.Index{Name: "user", Field: "name", Unique: true},
+			model.Index{Name: "user", Field: "age"},
+		),
+	)
+	created := createUsers(t, m,
+		user{Name: "alice", Age: 30},
+		user{Name: "bob", Age: 25},
+		user{Name: "carol", Age: 25},
+	)
+
+	// a duplicate value for a unique index is rejected
+	e := m.NewEntity("user", user{Name: "alice"})
+	e.Attributes()["name"] = "alice"
+	err := m.Create(e)
+	if _, ok := err.(*model.DuplicateError); !ok {
+		t.Fatalf("Expected a DuplicateError, got %v", err)
+	}
+
+	entities, err := m.Read(model.ReadFrom("user"), model.ReadEquals("age", 25.0), model.ReadOrder("name", false))
+	if err != nil {
+		t.Fatalf("Unexpected error reading entities: %v", err)
+	}
+	if got := names(entities); len(got) != 2 || got[0] != "bob" || got[1] != "carol" {
+		t.Fatalf("Expected [bob carol], got %v", got)
+	}
+
+	// updating an attribute moves its index entry
+	created[1].Attributes()["age"] = 40
+	if err := m.Update(created[1]); err != nil {
+		t.Fatalf("Unexpected error updating entity: %v", err)
+	}
+	entities, err = m.Read(model.ReadFrom("user"), model.ReadEquals("age", 25))
+	if err != nil {
+		t.Fatalf("Unexpected error reading entities: %v", err)
+	}
+	if got := names(entities); len(got) != 1 || got[0] != "carol" {
+		t.Fatalf("Expected [carol], got %v", got)
+	}
+
+	// an update can't take the unique value of another entity
+	created[1].Attributes()["name"] = "carol"
+	if _, ok := m.Update(created[1]).(*model.DuplicateError); !ok {
+		t.Fatalf("Expected a DuplicateError updating entity")
+	}
+
+	// deleting removes the index entries
+	if err := m.Delete(model.DeleteFrom("user"), model.DeleteId(created[0].Id())); err != nil {
+		t.Fatalf("Unexpected error deleting entity: %v", err)
+	}
+	keys, err := s.List(store.ListFrom("", "user_name_index"))
+	if err != nil {
+		t.Fatalf("Unexpected error listing index: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 index entries, got %v", keys)
+	}
+	e = m.NewEntity("user", user{Name: "alice"})
+	e.Attributes()["name"] = "alice"
+	if err := m.Create(e); err != nil {
+		t.Fatalf("Unexpected error recreating entity: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	m := NewModel()
+
+	e := m.NewEntity("user", map[string]interface{}{"name": "alice", "age": 30})
+	e.Attributes()["name"] = "alice"
+	if err := m.Create(e); err != nil {
+		t.Fatalf("Unexpected error creating entity: %v", err)
+	}
+
+	read := func() model.Entity {
+		entities, err := m.Read(model.ReadFrom("user"), model.ReadId(e.Id()))
+		if err != nil || len(entities) != 1 {
+			t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+		}
+		return entities[0]
+	}
+
+	first, second := read(), read()
+	if v := first.(model.Versioned).Version(); v != 1 {
+		t.Fatalf("Expected version 1, got %v", v)
+	}
+
+	// only the fields in the update are changed
+	update := &mudEntity{
+		id:         first.Id(),
+		name:       first.Name(),
+		value:      map[string]interface{}{"age": 31},
+		codec:      first.(*mudEntity).codec,
+		attributes: map[string]interface{}{"age": 31},
+		version:    first.(model.Versioned).Version(),
+	}
+	if err := m.Update(update); err != nil {
+		t.Fatalf("Unexpected error updating entity: %v", err)
+	}
+	if update.Version() != 2 {
+		t.Fatalf("Expected version 2, got %v", update.Version())
+	}
+
+	var v map[string]interface{}
+	if err := read().(*mudEntity).Read(&v); err != nil {
+		t.Fatalf("Unexpected error decoding entity: %v", err)
+	}
+	if v["name"] != "alice" || v["age"] != 31.0 {
+		t.Fatalf("Expected the update to be merged, got %v", v)
+	}
+	if attrs := read().Attributes(); attrs["name"] != "alice" || attrs["age"] != 31 {
+		t.Fatalf("Expected the attributes to be merged, got %v", attrs)
+	}
+	if _, ok := update.Attributes()["name"]; ok || len(update.Attributes()) != 1 {
+		t.Fatalf("Expected the attributes of the update to be left as they are, got %v", update.Attributes())
+	}
+
+	// the second copy is now stale
+	second.Attributes()["age"] = 32
+	if err := m.Update(second); err != model.ErrConflict {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestUpdateRead(t *testing.T) {
+	m := NewModel()
+	created := createUsers(t, m, user{Name: "alice", Age: 30})
+
+	read := func() model.Entity {
+		entities, err := m.Read(model.ReadFrom("user"), model.ReadId(created[0].Id()))
+		if err != nil || len(entities) != 1 {
+			t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+		}
+		return entities[0]
+	}
+
+	// update the entity as it was read from the store
+	e := read()
+	e.Attributes()["age"] = 31
+	if err := m.Update(e); err != nil {
+		t.Fatalf("Unexpected error updating entity: %v", err)
+	}
+
+	var u user
+	if err := read().(*mudEntity).Read(&u); err != nil {
+		t.Fatalf("Unexpected error decoding entity: %v", err)
+	}
+	if u.Name != "alice" || u.Age != 30 {
+		t.Fatalf("Expected the stored value to be kept, got %+v", u)
+	}
+	if age := read().Attributes()["age"]; age != 31 {
+		t.Fatalf("Expected the attributes to be updated, got %v", age)
+	}
+	// a new entity whose value is bytes is still encoded
+	raw := m.NewEntity("raw", []byte("raw"))
+	if err := m.Create(raw); err != nil {
+		t.Fatalf("Unexpected error creating entity: %v", err)
+	}
+	entities, err := m.Read(model.ReadFrom("raw"), model.ReadId(raw.Id()))
+	if err != nil || len(entities) != 1 {
+		t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+	}
+	if v := string(entities[0].Value().([]byte)); v != `"cmF3"` {
+		t.Fatalf("Expected the value to be encoded, got %v", v)
+	}
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/sync"
+)
+
+// Database to write to
+func Database(db string) Option {
+	return func(o *Options) {
+		o.Database = db
+	}
+}
+
+// Codec used to serialise entity values
+func Codec(c codec.Marshaler) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
+
+// Sync used for locking
+func Sync(s sync.Sync) Option {
+	return func(o *Options) {
+		o.Sync = s
+	}
+}
+
+// Store used for storage
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Indexes maintained on entity attributes. Reads which filter on
+// the equality of an indexed attribute avoid a full scan.
+func Indexes(idx ...Index) Option {
+	return func(o *Options) {
+		o.Indexes = append(o.Indexes, idx...)
+	}
+}
+
+// WithContext sets the model context, for any extra configuration
+func WithContext(c context.Context) Option {
+	return func(o *Options) {
+		o.Context = c
+	}
+}
+
+// ReadFrom the entities with the given name
+func ReadFrom(name string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Name = name
+	}
+}
+
+// ReadId reads the single entity with the given id
+func ReadId(id string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Id = id
+	}
+}
+
+// ReadWhere returns entities whose attribute field compares to value using op
+func ReadWhere(field string, op Operator, value interface{}) ReadOption {
+	return func(o *ReadOptions) {
+		o.Filters = append(o.Filters, Filter{Field: field, Op: op, Value: value})
+	}
+}
+
+// ReadEquals returns entities whose attribute field is equal to value
+func ReadEquals(field string, value interface{}) ReadOption {
+	return ReadWhere(field, Equal, value)
+}
+
+// ReadOrder sorts the results by the attribute field. It can be
+// passed more than once to break ties on subsequent fields.
+func ReadOrder(field string, descending bool) ReadOption {
+	return func(o *ReadOptions) {
+		o.Order = append(o.Order, Order{Field: field, Descending: descending})
+	}
+}
+
+// ReadLimit limits the number of results to l
+func ReadLimit(l uint) ReadOption {
+	return func(o *ReadOptions) {
+		o.Limit = l
+	}
+}
+
+// ReadOffset skips the first o results. Use in conjunction with Limit for pagination
+func ReadOffset(off uint) ReadOption {
+	return func(o *ReadOptions) {
+		o.Offset = off
+	}
+}
+
+// DeleteFrom the entities with the given name
+func DeleteFrom(name string) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.Name = name
+	}
+}
+
+// DeleteId deletes the single entity with the given id
+func DeleteId(id string) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.Id = id
+	}
+}
+
+// DeleteWhere deletes entities whose attribute field compares to value using op
+func DeleteWhere(field string, op Operator, value interface{}) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.Filters = append(o.Filters, Filter{Field: field, Op: op, Value: value})
+	}
+}
+
+// DeleteEquals deletes entities whose attribute field is equal to value
+func DeleteEquals(field string, value interface{}) DeleteOption {
+	return DeleteWhere(field, Equal, value)
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Operator is the comparison applied by a Filter
+type Operator int
+
+const (
+	Equal Operator = iota
+	NotEqual
+	LessThan
+	LessThanOrEqual
+	GreaterThan
+	GreaterThanOrEqual
+)
+
+func (o Operator) String() string {
+	switch o {
+	case Equal:
+		return "="
+	case NotEqual:
+		return "!="
+	case LessThan:
+		return "<"
+	case LessThanOrEqual:
+		return "<="
+	case GreaterThan:
+		return ">"
+	case GreaterThanOrEqual:
+		return ">="
+	default:
+		return "unknown"
+	}
+}
+
+// Filter matches entities whose attribute Field compares to Value using Op
+type Filter struct {
+	Field string
+	Op    Operator
+	Value interface{}
+}
+
+// Match returns true if the attributes satisfy the filter. A missing
+// attribute or a value of an incomparable type never matches.
+func (f Filter) Match(attrs map[string]interface{}) bool {
+	v, ok := attrs[f.Field]
+	if !ok {
+		return false
+	}
+
+	c, ok := Compare(v, f.Value)
+	if !ok {
+		return false
+	}
+
+	switch f.Op {
+	case Equal:
+		return c == 0
+	case NotEqual:
+		return c != 0
+	case LessThan:
+		return c < 0
+	case LessThanOrEqual:
+		return c <= 0
+	case GreaterThan:
+		return c > 0
+	case GreaterThanOrEqual:
+		return c >= 0
+	}
+
+	return false
+}
+
+// Order sorts entities by the attribute Field
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Compare returns -1, 0 or 1 if a is less than, equal to or greater than b.
+// Numbers of any type are compared by value so attributes survive a round
+// trip through a store which encodes them as JSON. The boolean is false if
+// the values can't be compared.
+func Compare(a, b interface{}) (int, bool) {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	switch va := a.(type) {
+	case string:
+		vb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(va, vb), true
+	case bool:
+		vb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case va == vb:
+			return 0, true
+		case !va:
+			return -1, true
+		}
+		return 1, true
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+		return 0, false
+	}
+
+	// fall back to equality of the formatted values
+	if fmt.Sprint(a) == fmt.Sprint(b) {
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
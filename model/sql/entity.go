@@ -12,6 +12,9 @@ type sqlEntity struct {
 	value      interface{}
 	codec      codec.Marshaler
 	attributes map[string]interface{}
+	version    uint64
+	// whether the value was read from the database, so is already encoded
+	encoded bool
 }
 
 func (m *sqlEntity) Attributes() map[string]interface{} {
@@ -30,6 +33,32 @@ func (m *sqlEntity) Value() interface{} {
 	return m.value
 }
 
+func (m *sqlEntity) Version() uint64 {
+	return m.version
+}
+
+func (m *sqlEntity) Read(v interface{}) error {
+	switch m.value.(type) {
+	case []byte:
+		b := m.value.([]byte)
+		return m.codec.Unmarshal(b, v)
+	default:
+		// round trip the value through the codec to copy it into v
+		b, err := m.codec.Marshal(m.value)
+		if err != nil {
+			return err
+		}
+		return m.codec.Unmarshal(b, v)
+	}
+}
+
+// setVersion records the version an entity was written at
+func setVersion(e model.Entity, version uint64) {
+	if se, ok := e.(*sqlEntity); ok {
+		se.version = version
+	}
+}
+
 func newEntity(name string, value interface{}, codec codec.Marshaler) model.Entity {
 	return &sqlEntity{
 		id:         uuid.New().String(),
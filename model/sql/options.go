@@ -0,0 +1,38 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/micro/go-micro/v3/model"
+)
+
+type dbKey struct{}
+type driverKey struct{}
+
+type driver struct {
+	name   string
+	source string
+}
+
+// DB sets an open database handle for the model to use
+func DB(db *sql.DB) model.Option {
+	return setModelOption(dbKey{}, db)
+}
+
+// Driver opens the database using the registered driver name and data
+// source, e.g. Driver("sqlite", "file:micro.db"). The driver package
+// must be imported by the caller.
+func Driver(name, source string) model.Option {
+	return setModelOption(driverKey{}, driver{name: name, source: source})
+}
+
+// setModelOption returns a function to setup a context with given value
+func setModelOption(k, v interface{}) model.Option {
+	return func(o *model.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
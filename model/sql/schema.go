@@ -0,0 +1,255 @@
+package sql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/v3/model"
+)
+
+// columns reserved for the entity id, version and encoded value
+const (
+	idColumn      = "_id"
+	versionColumn = "_version"
+	valueColumn   = "_value"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// quote an identifier for use in a statement
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// columnType returns the column type for a value, or an empty
+// string if the value can't be stored in a column of its own
+func columnType(t reflect.Type) string {
+	if t == timeType {
+		return "TIMESTAMP"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Float32, reflect.Float64:
+		return "DOUBLE PRECISION"
+	case reflect.String:
+		return "TEXT"
+	}
+	return ""
+}
+
+// row returns the column values for an entity. Scalar fields of a struct
+// or map value become columns, named by their json tag if they have one,
+// and the entity attributes are added on top. Other fields are only
+// stored in the encoded value.
+func row(e model.Entity) map[string]interface{} {
+	r := make(map[string]interface{})
+	fields(reflect.ValueOf(e.Value()), r)
+	for k, v := range e.Attributes() {
+		if v == nil || columnType(reflect.TypeOf(v)) == "" {
+			continue
+		}
+		r[k] = v
+	}
+	// never overwrite the reserved columns
+	delete(r, idColumn)
+	delete(r, versionColumn)
+	delete(r, valueColumn)
+	return r
+}
+
+func fields(v reflect.Value, r map[string]interface{}) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, k := range v.MapKeys() {
+			f := v.MapIndex(k)
+			for f.Kind() == reflect.Interface && !f.IsNil() {
+				f = f.Elem()
+			}
+			if f.IsValid() && columnType(f.Type()) != "" {
+				r[k.String()] = f.Interface()
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if len(sf.PkgPath) > 0 && !sf.Anonymous {
+				continue
+			}
+			name := sf.Name
+			if tag := sf.Tag.Get("json"); len(tag) > 0 {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; len(n) > 0 {
+					name = n
+				}
+			} else if sf.Anonymous {
+				// promote the fields of embedded structs
+				fields(v.Field(i), r)
+				continue
+			}
+			f := v.Field(i)
+			for f.Kind() == reflect.Ptr && !f.IsNil() {
+				f = f.Elem()
+			}
+			if f.Kind() != reflect.Ptr && f.CanInterface() && columnType(f.Type()) != "" {
+				r[name] = f.Interface()
+			}
+		}
+	}
+}
+
+// table returns the name of the table for the entity name
+func (m *sqlModel) table(name string) string {
+	if len(m.options.Database) > 0 {
+		return m.options.Database + "_" + name
+	}
+	return name
+}
+
+// columns returns the columns of the entity table, creating it if it doesn't exist
+func (m *sqlModel) columns(name string) (map[string]bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	cols, err := m.columnsLocked(name)
+	if err != nil {
+		return nil, err
+	}
+	return copyColumns(cols), nil
+}
+
+func (m *sqlModel) columnsLocked(name string) (map[string]bool, error) {
+	if cols, ok := m.tables[name]; ok {
+		return cols, nil
+	}
+
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	table := quote(m.table(name))
+
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s TEXT NOT NULL PRIMARY KEY, %s BIGINT NOT NULL, %s BYTEA)`,
+		table, quote(idColumn), quote(versionColumn), quote(valueColumn)))
+	if err != nil {
+		return nil, err
+	}
+
+	// read the existing columns from an empty result set
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s LIMIT 0", table))
+	if err != nil {
+		return nil, err
+	}
+	names, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	cols := make(map[string]bool, len(names))
+	for _, n := range names {
+		cols[n] = true
+	}
+
+	for _, idx := range m.options.Indexes {
+		if idx.Name == name && cols[idx.Field] {
+			if err := m.createIndex(idx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m.tables[name] = cols
+	return cols, nil
+}
+
+// migrate adds any columns of the row missing from the entity table
+func (m *sqlModel) migrate(name string, r map[string]interface{}) (map[string]bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	cols, err := m.columnsLocked(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for k := range r {
+		if !cols[k] {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return copyColumns(cols), nil
+	}
+	sort.Strings(missing)
+
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range missing {
+		typ := columnType(reflect.TypeOf(r[k]))
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quote(m.table(name)), quote(k), typ))
+		if err != nil {
+			return nil, err
+		}
+		cols[k] = true
+
+		for _, idx := range m.options.Indexes {
+			if idx.Name == name && idx.Field == k {
+				if err := m.createIndex(idx); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return copyColumns(cols), nil
+}
+
+func copyColumns(cols map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(cols))
+	for k, v := range cols {
+		c[k] = v
+	}
+	return c
+}
+
+func (m *sqlModel) createIndex(idx model.Index) error {
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+
+	unique := ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+
+	table := m.table(idx.Name)
+	_, err = db.Exec(fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)",
+		unique, quote(table+"_"+idx.Field+"_index"), quote(table), quote(idx.Field)))
+	return err
+}
@@ -1,23 +1,78 @@
-// Package sql is the micro data model implementation
+// Package sql is a micro data model implementation which maps entities
+// to tables in a database/sql database
 package sql
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/micro/go-micro/v3/codec/json"
+	"github.com/micro/go-micro/v3/logger"
 	"github.com/micro/go-micro/v3/model"
-	"github.com/micro/go-micro/v3/store"
-	"github.com/micro/go-micro/v3/store/memory"
-	memsync "github.com/micro/go-micro/v3/sync/memory"
 )
 
 type sqlModel struct {
+	sync.Mutex
 	options model.Options
+	db      *sql.DB
+
+	// known columns of each entity table
+	tables map[string]map[string]bool
+}
+
+func (m *sqlModel) configure() error {
+	m.Lock()
+	defer m.Unlock()
+
+	// the schema is read again from the new database
+	m.tables = make(map[string]map[string]bool)
+
+	if m.options.Context == nil {
+		return nil
+	}
+
+	if db, ok := m.options.Context.Value(dbKey{}).(*sql.DB); ok {
+		m.db = db
+		return nil
+	}
+
+	d, ok := m.options.Context.Value(driverKey{}).(driver)
+	if !ok {
+		return nil
+	}
+
+	db, err := sql.Open(d.name, d.source)
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		return err
+	}
+	if m.db != nil {
+		m.db.Close()
+	}
+	m.db = db
+
+	return nil
+}
+
+func (m *sqlModel) getDB() (*sql.DB, error) {
+	if m.db == nil {
+		return nil, errors.New("database connection not initialised")
+	}
+	return m.db, nil
 }
 
 func (m *sqlModel) Init(opts ...model.Option) error {
 	for _, o := range opts {
 		o(&m.options)
 	}
-	return nil
+	return m.configure()
 }
 
 func (m *sqlModel) NewEntity(name string, value interface{}) model.Entity {
@@ -26,24 +81,36 @@ func (m *sqlModel) NewEntity(name string, value interface{}) model.Entity {
 }
 
 func (m *sqlModel) Create(e model.Entity) error {
-	// lock on the name of entity
-	if err := m.options.Sync.Lock(e.Name()); err != nil {
+	r := row(e)
+	if _, err := m.migrate(e.Name(), r); err != nil {
+		return err
+	}
+
+	v, err := m.encode(e)
+	if err != nil {
+		return err
+	}
+
+	db, err := m.getDB()
+	if err != nil {
 		return err
 	}
-	// TODO: deal with the error
-	defer m.options.Sync.Unlock(e.Name())
 
-	// TODO: potentially add encode to entity?
-	v, err := m.options.Codec.Marshal(e.Value())
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	if err := m.insert(tx, e, r, v); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
-	// TODO: include metadata and set database
-	return m.options.Store.Write(&store.Record{
-		Key:   e.Id(),
-		Value: v,
-	}, store.WriteTo(m.options.Database, e.Name()))
+	setVersion(e, 1)
+	return nil
 }
 
 func (m *sqlModel) Read(opts ...model.ReadOption) ([]model.Entity, error) {
@@ -51,31 +118,148 @@ func (m *sqlModel) Read(opts ...model.ReadOption) ([]model.Entity, error) {
 	for _, o := range opts {
 		o(&options)
 	}
-	// TODO: implement the options that allow querying
-	return nil, nil
+
+	if len(options.Name) == 0 {
+		return nil, model.ErrMissingName
+	}
+
+	cols, err := m.columns(options.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	cond, args, ok := where(cols, options.Id, options.Filters)
+	if !ok {
+		return nil, nil
+	}
+
+	var order []string
+	for _, o := range options.Order {
+		if !cols[o.Field] {
+			continue
+		}
+		if o.Descending {
+			order = append(order, quote(o.Field)+" DESC")
+		} else {
+			order = append(order, quote(o.Field)+" ASC")
+		}
+	}
+	order = append(order, quote(idColumn)+" ASC")
+
+	q := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s", quote(m.table(options.Name)), cond, strings.Join(order, ", "))
+
+	if options.Limit > 0 || options.Offset > 0 {
+		limit := uint64(math.MaxInt64)
+		if options.Limit > 0 {
+			limit = uint64(options.Limit)
+		}
+		q += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args, limit, uint64(options.Offset))
+	}
+
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return m.scan(options.Name, rows)
 }
 
 func (m *sqlModel) Update(e model.Entity) error {
-	// TODO: read out the record first, update the fields and store
+	r := row(e)
+	if _, err := m.migrate(e.Name(), r); err != nil {
+		return err
+	}
+
+	v, err := m.encode(e)
+	if err != nil {
+		return err
+	}
 
-	// lock on the name of entity
-	if err := m.options.Sync.Lock(e.Name()); err != nil {
+	db, err := m.getDB()
+	if err != nil {
 		return err
 	}
-	// TODO: deal with the error
-	defer m.options.Sync.Unlock(e.Name())
 
-	// TODO: potentially add encode to entity?
-	v, err := m.options.Codec.Marshal(e.Value())
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	table := quote(m.table(e.Name()))
+
+	// read the stored version and value to merge with
+	var version uint64
+	var stored []byte
+	err = tx.QueryRow(
+		fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s = $1", quote(versionColumn), quote(valueColumn), table, quote(idColumn)),
+		e.Id(),
+	).Scan(&version, &stored)
+
+	switch err {
+	case sql.ErrNoRows:
+		if err := m.insert(tx, e, r, v); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		setVersion(e, 1)
+		return nil
+	case nil:
+	default:
+		return err
+	}
+
+	if ve, ok := e.(model.Versioned); ok && ve.Version() > 0 && ve.Version() != version {
+		return model.ErrConflict
+	}
+
+	// merge the fields of the update into the stored value
+	if v, err = m.merge(stored, v); err != nil {
+		return err
+	}
+
+	if err := m.checkUnique(tx, e.Name(), e.Id(), r); err != nil {
+		return err
+	}
+
+	names := append([]string{versionColumn, valueColumn}, sortedKeys(r)...)
+	args := []interface{}{version + 1, v}
+	set := make([]string, 0, len(names))
+	for i, n := range names {
+		set = append(set, fmt.Sprintf("%s = $%d", quote(n), i+1))
+		if i > 1 {
+			args = append(args, r[n])
+		}
+	}
+	args = append(args, e.Id(), version)
 
-	// TODO: include metadata and set database
-	return m.options.Store.Write(&store.Record{
-		Key:   e.Id(),
-		Value: v,
-	}, store.WriteTo(m.options.Database, e.Name()))
+	res, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d AND %s = $%d",
+		table, strings.Join(set, ", "), quote(idColumn), len(names)+1, quote(versionColumn), len(names)+2), args...)
+	if err != nil {
+		return err
+	}
+	// the version is checked again in case another writer got there first
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return model.ErrConflict
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	setVersion(e, version+1)
+	return nil
 }
 
 func (m *sqlModel) Delete(opts ...model.DeleteOption) error {
@@ -83,22 +267,245 @@ func (m *sqlModel) Delete(opts ...model.DeleteOption) error {
 	for _, o := range opts {
 		o(&options)
 	}
-	// TODO: implement the options that allow deleting
-	return nil
+
+	if len(options.Name) == 0 {
+		return model.ErrMissingName
+	}
+
+	cols, err := m.columns(options.Name)
+	if err != nil {
+		return err
+	}
+
+	cond, args, ok := where(cols, options.Id, options.Filters)
+	if !ok {
+		return nil
+	}
+
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("DELETE FROM %s%s", quote(m.table(options.Name)), cond), args...)
+	return err
 }
 
 func (m *sqlModel) String() string {
 	return "sql"
 }
 
+// insert a new row for the entity
+func (m *sqlModel) insert(tx *sql.Tx, e model.Entity, r map[string]interface{}, v []byte) error {
+	if err := m.checkUnique(tx, e.Name(), e.Id(), r); err != nil {
+		return err
+	}
+
+	names := append([]string{idColumn, versionColumn, valueColumn}, sortedKeys(r)...)
+	args := []interface{}{e.Id(), uint64(1), v}
+	cols := make([]string, 0, len(names))
+	values := make([]string, 0, len(names))
+	for i, n := range names {
+		cols = append(cols, quote(n))
+		values = append(values, fmt.Sprintf("$%d", i+1))
+		if i > 2 {
+			args = append(args, r[n])
+		}
+	}
+
+	_, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quote(m.table(e.Name())), strings.Join(cols, ", "), strings.Join(values, ", ")), args...)
+	return err
+}
+
+// checkUnique returns a DuplicateError if another row has the same value
+// for any unique index. The unique index on the table is the backstop
+// for writers which race.
+func (m *sqlModel) checkUnique(tx *sql.Tx, name, id string, r map[string]interface{}) error {
+	for _, idx := range m.options.Indexes {
+		if idx.Name != name || !idx.Unique {
+			continue
+		}
+		v, ok := r[idx.Field]
+		if !ok {
+			continue
+		}
+
+		var other string
+		err := tx.QueryRow(fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1 AND %s <> $2 LIMIT 1",
+			quote(idColumn), quote(m.table(name)), quote(idx.Field), quote(idColumn)), v, id).Scan(&other)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return err
+		}
+		return &model.DuplicateError{Name: name, Field: idx.Field, Value: v}
+	}
+	return nil
+}
+
+// encode the value of an entity. The value of an entity read from the
+// database is already encoded, so it's used as it is.
+func (m *sqlModel) encode(e model.Entity) ([]byte, error) {
+	if se, ok := e.(*sqlEntity); ok && se.encoded {
+		b, _ := se.value.([]byte)
+		return b, nil
+	}
+	return m.options.Codec.Marshal(e.Value())
+}
+
+// merge overlays the fields of an updated value on the stored value.
+// Values which don't decode to an object are replaced outright.
+func (m *sqlModel) merge(stored, update []byte) ([]byte, error) {
+	var prev, next map[string]interface{}
+	if err := m.options.Codec.Unmarshal(stored, &prev); err != nil || prev == nil {
+		return update, nil
+	}
+	if err := m.options.Codec.Unmarshal(update, &next); err != nil || next == nil {
+		return update, nil
+	}
+	for k, v := range next {
+		prev[k] = v
+	}
+	return m.options.Codec.Marshal(prev)
+}
+
+// scan the rows into entities
+func (m *sqlModel) scan(name string, rows *sql.Rows) ([]model.Entity, error) {
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []model.Entity
+
+	for rows.Next() {
+		values := make([]interface{}, len(names))
+		ptrs := make([]interface{}, len(names))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		e := &sqlEntity{
+			name:       name,
+			codec:      m.options.Codec,
+			attributes: make(map[string]interface{}),
+			encoded:    true,
+		}
+
+		for i, n := range names {
+			v := values[i]
+			switch n {
+			case idColumn:
+				e.id = toString(v)
+			case versionColumn:
+				e.version = toVersion(v)
+			case valueColumn:
+				e.value, _ = v.([]byte)
+			default:
+				// a null column is a missing attribute
+				if v == nil {
+					continue
+				}
+				if b, ok := v.([]byte); ok {
+					v = string(b)
+				}
+				e.attributes[n] = v
+			}
+		}
+
+		entities = append(entities, e)
+	}
+
+	return entities, rows.Err()
+}
+
+// where returns the WHERE clause matching the id and filters, with
+// placeholders numbered from 1. The boolean is false if the clause can
+// never match because a filter is on a column the table doesn't have.
+func where(cols map[string]bool, id string, filters []model.Filter) (string, []interface{}, bool) {
+	var conds []string
+	var args []interface{}
+
+	if len(id) > 0 {
+		args = append(args, id)
+		conds = append(conds, fmt.Sprintf("%s = $%d", quote(idColumn), len(args)))
+	}
+
+	for _, f := range filters {
+		if !cols[f.Field] {
+			return "", nil, false
+		}
+
+		op := f.Op.String()
+		if f.Op == model.NotEqual {
+			op = "<>"
+		}
+
+		args = append(args, f.Value)
+		conds = append(conds, fmt.Sprintf("%s %s $%d", quote(f.Field), op, len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", nil, true
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args, true
+}
+
+func sortedKeys(r map[string]interface{}) []string {
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	s, _ := v.(string)
+	return s
+}
+
+func toVersion(v interface{}) uint64 {
+	switch t := v.(type) {
+	case int64:
+		return uint64(t)
+	case float64:
+		return uint64(t)
+	case []byte:
+		var n uint64
+		fmt.Sscan(string(t), &n)
+		return n
+	}
+	return 0
+}
+
 func NewModel(opts ...model.Option) model.Model {
 	options := model.Options{
 		Codec: new(json.Marshaler),
-		Sync:  memsync.NewSync(),
-		Store: memory.NewStore(),
 	}
 
-	return &sqlModel{
+	for _, o := range opts {
+		o(&options)
+	}
+
+	m := &sqlModel{
 		options: options,
 	}
+
+	// best-effort configure the model
+	if err := m.configure(); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Error("Error configuring model ", err)
+		}
+	}
+
+	return m
 }
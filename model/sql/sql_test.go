@@ -0,0 +1,241 @@
+package sql
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+	"github.com/micro/go-micro/v3/model"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func newTestModel(t *testing.T, opts ...model.Option) (model.Model, func()) {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append(opts, Driver("sqlite", filepath.Join(dir, "test.db")))
+	m := NewModel(opts...)
+	if err := m.Init(); err != nil {
+		t.Fatalf("Unexpected error initialising model: %v", err)
+	}
+	return m, func() { os.RemoveAll(dir) }
+}
+
+func names(entities []model.Entity) []string {
+	var n []string
+	for _, e := range entities {
+		n = append(n, e.Attributes()["name"].(string))
+	}
+	return n
+}
+
+func TestModel(t *testing.T) {
+	m, cleanup := newTestModel(t, model.Indexes(model.Index{Name: "user", Field: "name", Unique: true}))
+	defer cleanup()
+
+	var created []model.Entity
+	for _, u := range []user{{"alice", 30}, {"bob", 25}, {"carol", 35}, {"dave", 25}} {
+		e := m.NewEntity("user", u)
+		if err := m.Create(e); err != nil {
+			t.Fatalf("Unexpected error creating entity: %v", err)
+		}
+		created = append(created, e)
+	}
+
+	// a duplicate value for a unique index is rejected
+	err := m.Create(m.NewEntity("user", user{Name: "alice"}))
+	if _, ok := err.(*model.DuplicateError); !ok {
+		t.Fatalf("Expected a DuplicateError, got %v", err)
+	}
+
+	tt := []struct {
+		name   string
+		opts   []model.ReadOption
+		expect []string
+	}{
+		{
+			name:   "equal",
+			opts:   []model.ReadOption{model.ReadEquals("age", 25), model.ReadOrder("name", false)},
+			expect: []string{"bob", "dave"},
+		},
+		{
+			name:   "range",
+			opts:   []model.ReadOption{model.ReadWhere("age", model.GreaterThan, 25), model.ReadOrder("age", true)},
+			expect: []string{"carol", "alice"},
+		},
+		{
+			name:   "limit and offset",
+			opts:   []model.ReadOption{model.ReadOrder("name", false), model.ReadOffset(1), model.ReadLimit(2)},
+			expect: []string{"bob", "carol"},
+		},
+		{
+			name:   "offset",
+			opts:   []model.ReadOption{model.ReadOrder("name", false), model.ReadOffset(3)},
+			expect: []string{"dave"},
+		},
+		{
+			name:   "id",
+			opts:   []model.ReadOption{model.ReadId(created[2].Id())},
+			expect: []string{"carol"},
+		},
+		{
+			name:   "missing column",
+			opts:   []model.ReadOption{model.ReadEquals("missing", 1)},
+			expect: nil,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			entities, err := m.Read(append(tc.opts, model.ReadFrom("user"))...)
+			if err != nil {
+				t.Fatalf("Unexpected error reading entities: %v", err)
+			}
+			got := names(entities)
+			if len(got) != len(tc.expect) {
+				t.Fatalf("Expected %v, got %v", tc.expect, got)
+			}
+			for i := range got {
+				if got[i] != tc.expect[i] {
+					t.Fatalf("Expected %v, got %v", tc.expect, got)
+				}
+			}
+		})
+	}
+
+	// the value decodes back into the struct
+	entities, err := m.Read(model.ReadFrom("user"), model.ReadId(created[0].Id()))
+	if err != nil || len(entities) != 1 {
+		t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+	}
+	var u user
+	if err := entities[0].(*sqlEntity).Read(&u); err != nil {
+		t.Fatalf("Unexpected error decoding entity: %v", err)
+	}
+	if u.Name != "alice" || u.Age != 30 {
+		t.Fatalf("Unexpected value %+v", u)
+	}
+
+	if err := m.Delete(model.DeleteFrom("user"), model.DeleteWhere("age", model.LessThan, 30)); err != nil {
+		t.Fatalf("Unexpected error deleting entities: %v", err)
+	}
+	entities, err = m.Read(model.ReadFrom("user"), model.ReadOrder("name", false))
+	if err != nil {
+		t.Fatalf("Unexpected error reading entities: %v", err)
+	}
+	if got := names(entities); len(got) != 2 || got[0] != "alice" || got[1] != "carol" {
+		t.Fatalf("Expected [alice carol], got %v", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	m, cleanup := newTestModel(t)
+	defer cleanup()
+
+	e := m.NewEntity("user", user{Name: "alice", Age: 30})
+	if err := m.Create(e); err != nil {
+		t.Fatalf("Unexpected error creating entity: %v", err)
+	}
+
+	read := func() *sqlEntity {
+		entities, err := m.Read(model.ReadFrom("user"), model.ReadId(e.Id()))
+		if err != nil || len(entities) != 1 {
+			t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+		}
+		return entities[0].(*sqlEntity)
+	}
+
+	first, second := read(), read()
+
+	// the schema is migrated to add the new field
+	type profile struct {
+		Age   int    `json:"age"`
+		Email string `json:"email"`
+	}
+	update := &sqlEntity{
+		id:         first.Id(),
+		name:       "user",
+		value:      profile{Age: 31, Email: "alice@example.com"},
+		codec:      first.codec,
+		attributes: map[string]interface{}{},
+		version:    first.Version(),
+	}
+	if err := m.Update(update); err != nil {
+		t.Fatalf("Unexpected error updating entity: %v", err)
+	}
+	if update.Version() != 2 {
+		t.Fatalf("Expected version 2, got %v", update.Version())
+	}
+
+	got := read()
+	if attrs := got.Attributes(); attrs["name"] != "alice" || attrs["age"] != int64(31) || attrs["email"] != "alice@example.com" {
+		t.Fatalf("Expected the columns to be merged, got %v", attrs)
+	}
+	var v map[string]interface{}
+	if err := got.Read(&v); err != nil {
+		t.Fatalf("Unexpected error decoding entity: %v", err)
+	}
+	if v["name"] != "alice" || v["age"] != 31.0 {
+		t.Fatalf("Expected the value to be merged, got %v", v)
+	}
+
+	// the second copy is now stale
+	if err := m.Update(second); err != model.ErrConflict {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestUpdateRead(t *testing.T) {
+	m, cleanup := newTestModel(t)
+	defer cleanup()
+
+	e := m.NewEntity("user", user{Name: "alice", Age: 30})
+	if err := m.Create(e); err != nil {
+		t.Fatalf("Unexpected error creating entity: %v", err)
+	}
+
+	read := func() *sqlEntity {
+		entities, err := m.Read(model.ReadFrom("user"), model.ReadId(e.Id()))
+		if err != nil || len(entities) != 1 {
+			t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+		}
+		return entities[0].(*sqlEntity)
+	}
+
+	// update the entity as it was read from the database
+	got := read()
+	got.Attributes()["age"] = 31
+	if err := m.Update(got); err != nil {
+		t.Fatalf("Unexpected error updating entity: %v", err)
+	}
+
+	var u user
+	if err := read().Read(&u); err != nil {
+		t.Fatalf("Unexpected error decoding entity: %v", err)
+	}
+	if u.Name != "alice" || u.Age != 30 {
+		t.Fatalf("Expected the stored value to be kept, got %+v", u)
+	}
+	if age := read().Attributes()["age"]; age != int64(31) {
+		t.Fatalf("Expected the columns to be updated, got %v", age)
+	}
+	// a new entity whose value is bytes is still encoded
+	raw := m.NewEntity("raw", []byte("raw"))
+	if err := m.Create(raw); err != nil {
+		t.Fatalf("Unexpected error creating entity: %v", err)
+	}
+	entities, err := m.Read(model.ReadFrom("raw"), model.ReadId(raw.Id()))
+	if err != nil || len(entities) != 1 {
+		t.Fatalf("Expected one entity, got %v %v", len(entities), err)
+	}
+	if v := string(entities[0].Value().([]byte)); v != `"cmF3"` {
+		t.Fatalf("Expected the value to be encoded, got %v", v)
+	}
+}
@@ -0,0 +1,195 @@
+// Package hash is a consistent hashing selector. Requests with the same
+// affinity key go to the same route, and when routes are added or removed
+// only the keys owned by those routes move.
+package hash
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-micro/v3/selector"
+)
+
+var (
+	// DefaultReplicas is the number of points each route has on the ring
+	DefaultReplicas = 100
+
+	// the number of rings kept, one for each set of routes selected from
+	maxRings = 64
+)
+
+type hashSelector struct {
+	replicas int
+
+	sync.Mutex
+	rings map[string]*ring
+}
+
+// ring of points, sorted by hash, each owned by a route
+type ring struct {
+	hashes []uint64
+	routes []string
+	// number of distinct routes on the ring
+	size int
+}
+
+// NewSelector returns a consistent hashing selector. Routes are selected by
+// the affinity key in the select options, or at random if there isn't one.
+func NewSelector(opts ...selector.Option) selector.Selector {
+	var options selector.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	s := &hashSelector{
+		replicas: DefaultReplicas,
+		rings:    make(map[string]*ring),
+	}
+
+	if options.Context != nil {
+		if n, ok := options.Context.Value(replicasKey{}).(int); ok && n > 0 {
+			s.replicas = n
+		}
+	}
+
+	return s
+}
+
+func (s *hashSelector) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	if len(routes) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	options := selector.NewSelectOptions(opts...)
+
+	// without a key any route will do
+	if len(options.Affinity) == 0 {
+		return func() string {
+			return routes[rand.Intn(len(routes))]
+		}, nil
+	}
+
+	r := s.ring(routes)
+	i := r.search(sum(options.Affinity))
+	seen := make(map[string]bool)
+
+	// the owner of the key is returned first, then the routes after it on the
+	// ring so retries go to a different route
+	return func() string {
+		if len(seen) == r.size {
+			seen = make(map[string]bool)
+		}
+		for {
+			route := r.routes[i%len(r.routes)]
+			i++
+			if !seen[route] {
+				seen[route] = true
+				return route
+			}
+		}
+	}, nil
+}
+
+// ring returns the ring for the routes, building it if needed
+func (s *hashSelector) ring(routes []string) *ring {
+	sorted := make([]string, len(routes))
+	copy(sorted, routes)
+	sort.Strings(sorted)
+
+	// the routes looked up may contain duplicates
+	unique := sorted[:1]
+	for _, route := range sorted[1:] {
+		if route != unique[len(unique)-1] {
+			unique = append(unique, route)
+		}
+	}
+	sorted = unique
+
+	key := strings.Join(sorted, ",")
+
+	s.Lock()
+	defer s.Unlock()
+
+	if r, ok := s.rings[key]; ok {
+		return r
+	}
+
+	r := newRing(sorted, s.replicas)
+	if len(s.rings) >= maxRings {
+		s.rings = make(map[string]*ring)
+	}
+	s.rings[key] = r
+	return r
+}
+
+func newRing(routes []string, replicas int) *ring {
+	points := make(map[uint64]string, len(routes)*replicas)
+	for _, route := range routes {
+		for i := 0; i < replicas; i++ {
+			h := sum(route + "#" + strconv.Itoa(i))
+			// break the rare collision the same way every time
+			if cur, ok := points[h]; ok && cur < route {
+				continue
+			}
+			points[h] = route
+		}
+	}
+
+	r := &ring{
+		hashes: make([]uint64, 0, len(points)),
+		routes: make([]string, 0, len(points)),
+	}
+	for h := range points {
+		r.hashes = append(r.hashes, h)
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	seen := make(map[string]bool, len(routes))
+	for _, h := range r.hashes {
+		r.routes = append(r.routes, points[h])
+		seen[points[h]] = true
+	}
+	r.size = len(seen)
+	return r
+}
+
+// search returns the index of the first point at or after the hash
+func (r *ring) search(h uint64) int {
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		return 0
+	}
+	return i
+}
+
+// sum hashes the string, mixing the bits so similar strings are spread
+// across the ring
+func sum(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (s *hashSelector) Record(addr string, err error) error {
+	return nil
+}
+
+func (s *hashSelector) Reset() error {
+	s.Lock()
+	s.rings = make(map[string]*ring)
+	s.Unlock()
+	return nil
+}
+
+func (s *hashSelector) String() string {
+	return "hash"
+}
@@ -0,0 +1,113 @@
+package hash
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/micro/go-micro/v3/selector"
+)
+
+func TestHash(t *testing.T) {
+	selector.Tests(t, NewSelector())
+}
+
+func routes(n int) []string {
+	var r []string
+	for i := 0; i < n; i++ {
+		r = append(r, fmt.Sprintf("10.0.0.%d:8080", i))
+	}
+	return r
+}
+
+func owners(t *testing.T, s selector.Selector, routes []string, keys int) map[string]string {
+	owners := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		next, err := s.Select(routes, selector.Affinity(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		owners[key] = next()
+	}
+	return owners
+}
+
+func TestAffinity(t *testing.T) {
+	s := NewSelector()
+	r := routes(10)
+	before := owners(t, s, r, 1000)
+
+	// the order of the routes doesn't matter
+	reversed := make([]string, len(r))
+	for i, route := range r {
+		reversed[len(r)-1-i] = route
+	}
+	for key, owner := range owners(t, s, reversed, 1000) {
+		if before[key] != owner {
+			t.Fatalf("Expected %v to be owned by %v, got %v", key, before[key], owner)
+		}
+	}
+
+	// keys are spread across the routes
+	counts := make(map[string]int)
+	for _, owner := range before {
+		counts[owner]++
+	}
+	for _, route := range r {
+		if counts[route] < 25 {
+			t.Fatalf("Expected keys to be spread across the routes, got %v", counts)
+		}
+	}
+
+	// when a route is removed only its keys move
+	removed := r[3]
+	after := owners(t, s, append(append([]string{}, r[:3]...), r[4:]...), 1000)
+	for key, owner := range after {
+		if before[key] != removed && before[key] != owner {
+			t.Fatalf("Expected %v to stay with %v, moved to %v", key, before[key], owner)
+		}
+	}
+
+	// when a route is added keys only move to it
+	added := "10.0.0.100:8080"
+	after = owners(t, s, append(r, added), 1000)
+	for key, owner := range after {
+		if before[key] != owner && owner != added {
+			t.Fatalf("Expected %v to stay with %v or move to %v, got %v", key, before[key], added, owner)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	s := NewSelector(Replicas(10))
+	r := routes(5)
+
+	next, err := s.Select(r, selector.Affinity("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// retries go to each of the other routes before any are repeated
+	seen := make(map[string]bool)
+	for i := 0; i < len(r); i++ {
+		route := next()
+		if seen[route] {
+			t.Fatalf("Expected %v not to be repeated", route)
+		}
+		seen[route] = true
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	s := NewSelector()
+
+	next, err := s.Select([]string{"10.0.0.1:8080", "10.0.0.1:8080"}, selector.Affinity("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if route := next(); route != "10.0.0.1:8080" {
+			t.Fatalf("Expected the only route, got %v", route)
+		}
+	}
+}
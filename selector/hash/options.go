@@ -0,0 +1,20 @@
+package hash
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v3/selector"
+)
+
+type replicasKey struct{}
+
+// Replicas sets the number of points each route has on the ring. More points
+// spread the keys more evenly across the routes.
+func Replicas(n int) selector.Option {
+	return func(o *selector.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, replicasKey{}, n)
+	}
+}
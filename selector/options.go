@@ -1,13 +1,23 @@
 package selector
 
+import "context"
+
 // Options used to configure a selector
-type Options struct{}
+type Options struct {
+	// Other options for implementations of the interface
+	// can be stored in a context
+	Context context.Context
+}
 
 // Option updates the options
 type Option func(*Options)
 
 // SelectOptions used to configure selection
-type SelectOptions struct{}
+type SelectOptions struct {
+	// Affinity is a key, such as a user or tenant id, which selectors that
+	// support it use to pick the same route for every request with the key
+	Affinity string
+}
 
 // SelectOption updates the select options
 type SelectOption func(*SelectOptions)
@@ -21,3 +31,10 @@ func NewSelectOptions(opts ...SelectOption) SelectOptions {
 
 	return options
 }
+
+// Affinity sets the key used to pick the same route for related requests
+func Affinity(key string) SelectOption {
+	return func(o *SelectOptions) {
+		o.Affinity = key
+	}
+}
@@ -0,0 +1,97 @@
+package outlier
+
+import (
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metrics"
+)
+
+// Options for the outlier selector
+type Options struct {
+	// Consecutive failures which eject a route, zero to disable
+	Consecutive int
+	// FailureRate of the calls in an Interval which ejects a route, once
+	// there have been at least MinCalls. Zero to disable.
+	FailureRate float64
+	MinCalls    int
+	Interval    time.Duration
+	// BaseEjection is how long a route is first ejected for. The time doubles
+	// each time it's ejected again, up to MaxEjection.
+	BaseEjection time.Duration
+	MaxEjection  time.Duration
+	// MaxEjectedPercent of the routes selected from which can be ejected
+	MaxEjectedPercent int
+	// Failure reports whether the error of a call counts against the route
+	Failure func(error) bool
+	// Reporter the ejections and readmissions are reported to
+	Reporter metrics.Reporter
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Consecutive:       5,
+		FailureRate:       0.5,
+		MinCalls:          10,
+		Interval:          time.Second * 10,
+		BaseEjection:      time.Second * 30,
+		MaxEjection:       time.Minute * 5,
+		MaxEjectedPercent: 50,
+		Failure:           errors.IsFailure,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// Consecutive sets the number of consecutive failures which eject a route
+func Consecutive(n int) Option {
+	return func(o *Options) {
+		o.Consecutive = n
+	}
+}
+
+// FailureRate sets the rate of failures in each interval which ejects a route,
+// once there have been at least the minimum calls
+func FailureRate(rate float64, min int, interval time.Duration) Option {
+	return func(o *Options) {
+		o.FailureRate = rate
+		o.MinCalls = min
+		o.Interval = interval
+	}
+}
+
+// Ejection sets how long a route is first ejected for, and the maximum it's
+// ejected for as it keeps failing
+func Ejection(base, max time.Duration) Option {
+	return func(o *Options) {
+		o.BaseEjection = base
+		o.MaxEjection = max
+	}
+}
+
+// MaxEjectedPercent sets the percentage of the routes selected from which can
+// be ejected at once
+func MaxEjectedPercent(p int) Option {
+	return func(o *Options) {
+		o.MaxEjectedPercent = p
+	}
+}
+
+// Failure sets the func which decides whether an error counts against a route
+func Failure(fn func(error) bool) Option {
+	return func(o *Options) {
+		o.Failure = fn
+	}
+}
+
+// Reporter sets the reporter the ejections and readmissions are reported to
+func Reporter(r metrics.Reporter) Option {
+	return func(o *Options) {
+		o.Reporter = r
+	}
+}
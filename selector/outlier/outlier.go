@@ -0,0 +1,285 @@
+// Package outlier wraps a selector to eject routes which keep failing. A route
+// is ejected after consecutive failures or a high failure rate, and kept out
+// for a period which grows each time it's ejected. Once the period is over
+// a single call is sent to the route, and it's readmitted if that succeeds.
+package outlier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/metrics"
+	"github.com/micro/go-micro/v3/selector"
+)
+
+var (
+	// EjectionsMetric counts the routes ejected, tagged with their address
+	EjectionsMetric = "client.outlier.ejections"
+	// ReadmissionsMetric counts the ejected routes readmitted
+	ReadmissionsMetric = "client.outlier.readmissions"
+)
+
+type outlier struct {
+	selector selector.Selector
+	opts     Options
+
+	sync.Mutex
+	routes map[string]*route
+	// time the routes which have gone quiet were last removed
+	pruned time.Time
+}
+
+// route is the health of an address
+type route struct {
+	// failures in a row
+	consecutive int
+	// calls and failures in the current interval
+	calls, failures int
+	interval        time.Time
+	// times ejected, and when the route was last readmitted
+	ejections  int
+	readmitted time.Time
+	// ejected until the time, then probed. While the probe is in flight the
+	// route stays ejected, for the base ejection if its result never arrives.
+	until   time.Time
+	probing bool
+	// time of the last result or probe
+	updated time.Time
+}
+
+// NewSelector wraps the selector, ejecting routes which keep failing from those
+// it selects from. The results of calls are passed on to the selector.
+func NewSelector(s selector.Selector, opts ...Option) selector.Selector {
+	return &outlier{
+		selector: s,
+		opts:     newOptions(opts...),
+		routes:   make(map[string]*route),
+	}
+}
+
+func (o *outlier) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	if len(routes) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	o.Lock()
+	now := time.Now()
+	o.prune(now)
+
+	var healthy, ejected []string
+	var probe string
+	for _, addr := range routes {
+		r, ok := o.routes[addr]
+		switch {
+		case !ok || r.until.IsZero():
+			healthy = append(healthy, addr)
+		case now.Before(r.until) || len(probe) > 0:
+			ejected = append(ejected, addr)
+		default:
+			// the ejection is over so probe the route, with one call
+			r.until = now.Add(o.opts.BaseEjection)
+			r.probing = true
+			r.updated = now
+			probe = addr
+			healthy = append(healthy, addr)
+		}
+	}
+
+	// never eject more than the max percentage of the routes, readmitting
+	// the routes which are closest to being probed
+	if max := len(routes) * o.opts.MaxEjectedPercent / 100; len(ejected) > max {
+		sort.Slice(ejected, func(i, j int) bool {
+			return o.routes[ejected[i]].until.Before(o.routes[ejected[j]].until)
+		})
+		healthy = append(healthy, ejected[:len(ejected)-max]...)
+	}
+	o.Unlock()
+
+	if len(healthy) == 0 {
+		healthy = routes
+	}
+
+	next, err := o.selector.Select(healthy, opts...)
+	if err != nil || len(probe) == 0 {
+		return next, err
+	}
+
+	// once the probe has been sent, select from the other routes
+	var others []string
+	for _, addr := range healthy {
+		if addr != probe {
+			others = append(others, addr)
+		}
+	}
+	if len(others) == 0 {
+		return next, nil
+	}
+	rest, err := o.selector.Select(others, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var mtx sync.Mutex
+	var probed bool
+	return func() string {
+		addr := next()
+		if addr != probe {
+			return addr
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if probed {
+			return rest()
+		}
+		probed = true
+		return addr
+	}, nil
+}
+
+// record the result of a call to the route
+func (o *outlier) record(addr string, err error) {
+	failed := err != nil && o.opts.Failure(err)
+	now := time.Now()
+
+	o.Lock()
+	defer o.Unlock()
+
+	o.prune(now)
+
+	r, ok := o.routes[addr]
+	if !ok {
+		r = &route{interval: now}
+		o.routes[addr] = r
+	}
+	r.updated = now
+
+	// the result of the probe decides whether the route is readmitted
+	if r.probing {
+		r.probing = false
+		if failed {
+			o.eject(addr, r, now)
+			return
+		}
+		r.until = time.Time{}
+		r.readmitted = now
+		o.report(ReadmissionsMetric, addr)
+	}
+
+	// results of calls made before the route was ejected
+	if !r.until.IsZero() {
+		return
+	}
+
+	if now.Sub(r.interval) > o.opts.Interval {
+		r.interval = now
+		r.calls = 0
+		r.failures = 0
+	}
+
+	r.calls++
+	if failed {
+		r.consecutive++
+		r.failures++
+	} else {
+		r.consecutive = 0
+	}
+
+	switch {
+	case o.opts.Consecutive > 0 && r.consecutive >= o.opts.Consecutive:
+	case o.opts.FailureRate > 0 && r.calls >= o.opts.MinCalls &&
+		float64(r.failures)/float64(r.calls) >= o.opts.FailureRate:
+	default:
+		return
+	}
+
+	o.eject(addr, r, now)
+}
+
+// eject the route, for twice as long as the last time
+func (o *outlier) eject(addr string, r *route, now time.Time) {
+	// forget past ejections once the route has been healthy for a while
+	if !r.readmitted.IsZero() && now.Sub(r.readmitted) > o.opts.MaxEjection {
+		r.ejections = 0
+	}
+	r.ejections++
+
+	d := o.opts.BaseEjection
+	for i := 1; i < r.ejections && d < o.opts.MaxEjection; i++ {
+		d *= 2
+	}
+	if d > o.opts.MaxEjection {
+		d = o.opts.MaxEjection
+	}
+
+	r.until = now.Add(d)
+	r.consecutive = 0
+	r.calls = 0
+	r.failures = 0
+	r.interval = now
+
+	o.report(EjectionsMetric, addr)
+}
+
+// prune removes the routes which haven't had a result for longer than the
+// interval and the max ejection, so those of routes which have gone away don't
+// build up. By then a route's ejection is over and its past ejections would be
+// forgotten. It runs at most once per interval.
+func (o *outlier) prune(now time.Time) {
+	if now.Sub(o.pruned) < o.opts.Interval {
+		return
+	}
+	o.pruned = now
+
+	expiry := o.opts.Interval
+	if o.opts.MaxEjection > expiry {
+		expiry = o.opts.MaxEjection
+	}
+	for addr, r := range o.routes {
+		if now.After(r.until) && now.Sub(r.updated) > expiry {
+			delete(o.routes, addr)
+		}
+	}
+}
+
+func (o *outlier) report(metric, addr string) {
+	if o.opts.Reporter != nil {
+		o.opts.Reporter.Count(metric, 1, metrics.Tags{"address": addr})
+	}
+}
+
+func (o *outlier) Start(addr string) {
+	if ob, ok := o.selector.(selector.Observer); ok {
+		ob.Start(addr)
+	}
+}
+
+func (o *outlier) Done(addr string, d time.Duration, err error) {
+	if ob, ok := o.selector.(selector.Observer); ok {
+		ob.Done(addr, d, err)
+	} else if err != context.Canceled {
+		o.selector.Record(addr, err)
+	}
+	// an abandoned call says nothing of the route
+	if err != context.Canceled {
+		o.record(addr, err)
+	}
+}
+
+func (o *outlier) Record(addr string, err error) error {
+	o.record(addr, err)
+	return o.selector.Record(addr, err)
+}
+
+func (o *outlier) Reset() error {
+	o.Lock()
+	o.routes = make(map[string]*route)
+	o.Unlock()
+	return o.selector.Reset()
+}
+
+func (o *outlier) String() string {
+	return "outlier"
+}
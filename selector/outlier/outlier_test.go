@@ -0,0 +1,224 @@
+package outlier
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metrics"
+	"github.com/micro/go-micro/v3/selector"
+	"github.com/micro/go-micro/v3/selector/roundrobin"
+)
+
+const (
+	r1 = "127.0.0.1:8000"
+	r2 = "127.0.0.1:8001"
+)
+
+var errFailed = errors.InternalServerError("go.micro.client", "connection error")
+
+// testReporter counts the metrics reported for each address
+type testReporter struct {
+	metrics.Reporter
+
+	sync.Mutex
+	counts map[string]int64
+}
+
+func (r *testReporter) Count(id string, value int64, tags metrics.Tags) error {
+	r.Lock()
+	defer r.Unlock()
+	r.counts[id+"/"+tags["address"]] += value
+	return nil
+}
+
+func (r *testReporter) count(id, addr string) int64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.counts[id+"/"+addr]
+}
+
+// selected returns the routes which can be selected
+func selected(t *testing.T, s selector.Selector) map[string]bool {
+	t.Helper()
+
+	next, err := s.Select([]string{r1, r2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		routes[next()] = true
+	}
+	return routes
+}
+
+func TestOutlier(t *testing.T) {
+	selector.Tests(t, NewSelector(roundrobin.NewSelector()))
+}
+
+func TestConsecutive(t *testing.T) {
+	reporter := &testReporter{counts: map[string]int64{}}
+	s := NewSelector(roundrobin.NewSelector(), Consecutive(3), Reporter(reporter))
+
+	// errors returned by healthy routes don't count
+	for i := 0; i < 10; i++ {
+		s.Record(r1, errors.BadRequest("test.service", "bad request"))
+	}
+	if !selected(t, s)[r1] {
+		t.Fatal("Expected the route not to be ejected for bad requests")
+	}
+
+	// a success resets the count
+	s.Record(r1, errFailed)
+	s.Record(r1, errFailed)
+	s.Record(r1, nil)
+	s.Record(r1, errFailed)
+	s.Record(r1, errFailed)
+	if !selected(t, s)[r1] {
+		t.Fatal("Expected the route not to be ejected")
+	}
+
+	// calls abandoned by the client don't count
+	for i := 0; i < 3; i++ {
+		selector.Track(s, r1)(context.Canceled)
+	}
+	if !selected(t, s)[r1] {
+		t.Fatal("Expected the route not to be ejected for abandoned calls")
+	}
+
+	// calls tracked by the client count too
+	selector.Track(s, r1)(errFailed)
+	if routes := selected(t, s); routes[r1] || !routes[r2] {
+		t.Fatalf("Expected the route to be ejected, got %v", routes)
+	}
+	if c := reporter.count(EjectionsMetric, r1); c != 1 {
+		t.Fatalf("Expected one ejection to be reported, got %v", c)
+	}
+}
+
+func TestFailureRate(t *testing.T) {
+	s := NewSelector(roundrobin.NewSelector(), Consecutive(0), FailureRate(0.5, 4, time.Minute))
+
+	s.Record(r1, errFailed)
+	s.Record(r1, nil)
+	s.Record(r1, errFailed)
+	if !selected(t, s)[r1] {
+		t.Fatal("Expected the route not to be ejected before the minimum calls")
+	}
+
+	s.Record(r1, nil)
+	if selected(t, s)[r1] {
+		t.Fatal("Expected the route to be ejected")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	reporter := &testReporter{counts: map[string]int64{}}
+	s := NewSelector(
+		roundrobin.NewSelector(),
+		Consecutive(1),
+		Ejection(time.Millisecond*50, time.Second),
+		Reporter(reporter),
+	)
+
+	s.Record(r1, errFailed)
+	if selected(t, s)[r1] {
+		t.Fatal("Expected the route to be ejected")
+	}
+
+	// the route is probed once the ejection is over, and readmitted
+	time.Sleep(time.Millisecond * 60)
+	if !selected(t, s)[r1] {
+		t.Fatal("Expected the route to be probed")
+	}
+	s.Record(r1, nil)
+	if c := reporter.count(ReadmissionsMetric, r1); c != 1 {
+		t.Fatalf("Expected one readmission to be reported, got %v", c)
+	}
+
+	// the next ejection is twice as long
+	s.Record(r1, errFailed)
+	time.Sleep(time.Millisecond * 60)
+	if selected(t, s)[r1] {
+		t.Fatal("Expected the route to still be ejected")
+	}
+	time.Sleep(time.Millisecond * 50)
+	if !selected(t, s)[r1] {
+		t.Fatal("Expected the route to be probed")
+	}
+
+	// a failed probe ejects the route again
+	s.Record(r1, errFailed)
+	if selected(t, s)[r1] {
+		t.Fatal("Expected the route to be ejected after the failed probe")
+	}
+	if c := reporter.count(EjectionsMetric, r1); c != 3 {
+		t.Fatalf("Expected three ejections to be reported, got %v", c)
+	}
+}
+
+func TestSingleProbe(t *testing.T) {
+	s := NewSelector(roundrobin.NewSelector(), Consecutive(1), Ejection(time.Millisecond*50, time.Second))
+
+	s.Record(r1, errFailed)
+	time.Sleep(time.Millisecond * 60)
+
+	// only one call is sent to the route until the result of the probe is in
+	next, err := s.Select([]string{r1, r2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var probes int
+	for i := 0; i < 10; i++ {
+		if next() == r1 {
+			probes++
+		}
+	}
+	if probes != 1 {
+		t.Fatalf("Expected the route to be probed once, got %v", probes)
+	}
+	if selected(t, s)[r1] {
+		t.Fatal("Expected the route to stay ejected while it's probed")
+	}
+
+	s.Record(r1, nil)
+	if !selected(t, s)[r1] {
+		t.Fatal("Expected the route to be readmitted after the probe")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := NewSelector(roundrobin.NewSelector(), Consecutive(1),
+		FailureRate(0.5, 10, time.Millisecond*10),
+		Ejection(time.Millisecond*10, time.Millisecond*20),
+	)
+	o := s.(*outlier)
+
+	s.Record(r1, errFailed)
+	s.Record(r2, nil)
+
+	// the routes are removed once they've gone quiet
+	time.Sleep(time.Millisecond * 30)
+	s.Record(r2, nil)
+	o.Lock()
+	_, ok := o.routes[r1]
+	o.Unlock()
+	if ok {
+		t.Fatal("Expected the route which went quiet to be removed")
+	}
+}
+
+func TestMaxEjected(t *testing.T) {
+	s := NewSelector(roundrobin.NewSelector(), Consecutive(1))
+
+	s.Record(r1, errFailed)
+	s.Record(r2, errFailed)
+
+	// only half the routes can be ejected, the first ejected is readmitted
+	if routes := selected(t, s); !routes[r1] || routes[r2] {
+		t.Fatalf("Expected only the first route ejected to be selected, got %v", routes)
+	}
+}
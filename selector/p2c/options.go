@@ -0,0 +1,32 @@
+package p2c
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v3/selector"
+)
+
+type decayKey struct{}
+type penaltyKey struct{}
+
+// Decay sets how quickly the stats of a route decay. Calls made the duration
+// ago have around a third of the weight of a call made now.
+func Decay(d time.Duration) selector.Option {
+	return setOption(decayKey{}, d)
+}
+
+// Penalty sets the latency recorded for a call which fails faster, so routes
+// returning errors aren't mistaken for fast ones
+func Penalty(d time.Duration) selector.Option {
+	return setOption(penaltyKey{}, d)
+}
+
+func setOption(k, v interface{}) selector.Option {
+	return func(o *selector.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
@@ -0,0 +1,250 @@
+// Package p2c is a load aware selector. It picks the better of two random
+// routes, judged by their latency, the calls in flight to them and their
+// error rate, as recorded by the client.
+package p2c
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/selector"
+)
+
+var (
+	// DefaultDecay is how quickly the stats of a route decay
+	DefaultDecay = time.Second * 10
+	// DefaultPenalty is the latency recorded for a call which failed
+	DefaultPenalty = time.Second
+)
+
+const (
+	// minimum weight given to each call, so stats change quickly when calls are frequent
+	alpha = 0.1
+	// number of decay periods after which the stats of a route without calls
+	// in flight are removed, by then their weight is negligible
+	expiry = 10
+)
+
+type p2c struct {
+	decay   time.Duration
+	penalty time.Duration
+
+	sync.Mutex
+	stats map[string]*stats
+	// average latency of all routes, used for those without any
+	latency float64
+	// time the expired stats were last removed
+	pruned time.Time
+}
+
+// stats of the calls to a route
+type stats struct {
+	// exponentially weighted moving average of latency in nanoseconds
+	latency float64
+	// exponentially weighted moving average of the error rate, from 0 to 1
+	errors float64
+	// calls in flight
+	inflight int
+	// whether any calls have been recorded
+	recorded bool
+	// time the stats were last updated
+	updated time.Time
+}
+
+// NewSelector returns a power of two choices selector
+func NewSelector(opts ...selector.Option) selector.Selector {
+	var options selector.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	p := &p2c{
+		decay:   DefaultDecay,
+		penalty: DefaultPenalty,
+		stats:   make(map[string]*stats),
+	}
+
+	if options.Context != nil {
+		if d, ok := options.Context.Value(decayKey{}).(time.Duration); ok && d > 0 {
+			p.decay = d
+		}
+		if d, ok := options.Context.Value(penaltyKey{}).(time.Duration); ok {
+			p.penalty = d
+		}
+	}
+
+	return p
+}
+
+func (p *p2c) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	if len(routes) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	return func() string {
+		if len(routes) == 1 {
+			return routes[0]
+		}
+
+		// pick two different routes at random
+		i := rand.Intn(len(routes))
+		j := rand.Intn(len(routes) - 1)
+		if j >= i {
+			j++
+		}
+
+		p.Lock()
+		defer p.Unlock()
+
+		now := time.Now()
+		if p.cost(routes[j], now) < p.cost(routes[i], now) {
+			return routes[j]
+		}
+		return routes[i]
+	}, nil
+}
+
+// cost of calling the route, lower is better. Routes without a latency are
+// assumed to be average.
+func (p *p2c) cost(route string, now time.Time) float64 {
+	s, ok := p.stats[route]
+	if !ok {
+		return p.latency + 1
+	}
+
+	latency := s.latency
+	if latency == 0 {
+		latency = p.latency
+	}
+
+	// errors are forgotten over time so routes which failed are tried again
+	errs := s.errors * p.weight(now.Sub(s.updated))
+	success := math.Max(1-errs, 0.01)
+
+	return (latency + 1) * float64(s.inflight+1) / success
+}
+
+// weight given to the stats recorded the duration ago
+func (p *p2c) weight(d time.Duration) float64 {
+	if d <= 0 {
+		return 1
+	}
+	return math.Exp(-float64(d) / float64(p.decay))
+}
+
+// update the stats of the route, with the latency if non zero
+func (p *p2c) update(route string, latency time.Duration, err error) *stats {
+	now := time.Now()
+	p.prune(now)
+
+	s, ok := p.stats[route]
+	if !ok {
+		s = new(stats)
+		p.stats[route] = s
+	}
+
+	var e float64
+	if err != nil {
+		e = 1
+	}
+
+	w := math.Min(p.weight(now.Sub(s.updated)), 1-alpha)
+	if !s.recorded {
+		w = 0
+	}
+	s.recorded = true
+	s.updated = now
+	s.errors = s.errors*w + e*(1-w)
+
+	if latency > 0 {
+		if p.latency == 0 {
+			p.latency = float64(latency)
+		} else {
+			p.latency = p.latency*(1-alpha) + float64(latency)*alpha
+		}
+
+		if s.latency == 0 {
+			s.latency = float64(latency)
+		} else {
+			s.latency = s.latency*w + float64(latency)*(1-w)
+		}
+	}
+
+	return s
+}
+
+// prune removes the stats of routes which haven't been called for the expiry,
+// so those of routes which have gone away don't build up. It runs at most
+// once per decay period.
+func (p *p2c) prune(now time.Time) {
+	if now.Sub(p.pruned) < p.decay {
+		return
+	}
+	p.pruned = now
+
+	for route, s := range p.stats {
+		if s.inflight == 0 && now.Sub(s.updated) > p.decay*expiry {
+			delete(p.stats, route)
+		}
+	}
+}
+
+func (p *p2c) Start(route string) {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	p.prune(now)
+
+	s, ok := p.stats[route]
+	if !ok {
+		s = &stats{updated: now}
+		p.stats[route] = s
+	}
+	s.inflight++
+}
+
+func (p *p2c) Done(route string, d time.Duration, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	// an abandoned call is no longer in flight, but isn't recorded
+	if err == context.Canceled {
+		if s, ok := p.stats[route]; ok && s.inflight > 0 {
+			s.inflight--
+		}
+		return
+	}
+
+	// failed calls cost at least the penalty
+	if err != nil && d < p.penalty {
+		d = p.penalty
+	}
+
+	s := p.update(route, d, err)
+	if s.inflight > 0 {
+		s.inflight--
+	}
+}
+
+func (p *p2c) Record(route string, err error) error {
+	p.Lock()
+	p.update(route, 0, err)
+	p.Unlock()
+	return nil
+}
+
+func (p *p2c) Reset() error {
+	p.Lock()
+	p.stats = make(map[string]*stats)
+	p.latency = 0
+	p.Unlock()
+	return nil
+}
+
+func (p *p2c) String() string {
+	return "p2c"
+}
@@ -0,0 +1,128 @@
+package p2c
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/selector"
+)
+
+const (
+	r1 = "127.0.0.1:8000"
+	r2 = "127.0.0.1:8001"
+)
+
+func TestP2C(t *testing.T) {
+	selector.Tests(t, NewSelector())
+}
+
+// expect the selector to always pick the route, since with two routes both
+// are compared on every selection
+func expect(t *testing.T, s selector.Selector, route string) {
+	t.Helper()
+
+	next, err := s.Select([]string{r1, r2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if got := next(); got != route {
+			t.Fatalf("Expected %v to be selected, got %v", route, got)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	s := NewSelector().(*p2c)
+
+	s.Start(r1)
+	s.Done(r1, time.Millisecond*100, nil)
+	s.Start(r2)
+	s.Done(r2, time.Millisecond, nil)
+	expect(t, s, r2)
+
+	// the latency of the faster route increases
+	for i := 0; i < 50; i++ {
+		s.Start(r2)
+		s.Done(r2, time.Second, nil)
+	}
+	expect(t, s, r1)
+
+	// routes without stats are assumed to be average
+	s.Reset()
+	s.Done("127.0.0.1:8002", time.Millisecond, nil)
+	s.Done(r2, time.Millisecond*100, nil)
+	expect(t, s, r1)
+}
+
+func TestInflight(t *testing.T) {
+	s := NewSelector()
+
+	o := s.(selector.Observer)
+	o.Done(r1, time.Millisecond*10, nil)
+	o.Done(r2, time.Millisecond*10, nil)
+
+	// calls in flight to the first route, tracked by the client
+	done := selector.Track(s, r1)
+	expect(t, s, r2)
+
+	done(nil)
+	selector.Track(s, r2)
+	expect(t, s, r1)
+
+	// an abandoned call is no longer in flight, and its duration isn't recorded
+	selector.Track(s, r2)(context.Canceled)
+	if st := s.(*p2c).stats[r2]; st.inflight != 1 || st.latency != float64(time.Millisecond*10) {
+		t.Fatalf("Expected the abandoned call not to be recorded, got %+v", st)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := NewSelector(Decay(time.Millisecond * 50))
+
+	s.Record(r1, nil)
+	s.Record(r2, nil)
+	for i := 0; i < 5; i++ {
+		s.Record(r1, errors.New("error"))
+	}
+	expect(t, s, r2)
+
+	// the errors decay, so the route is tried again
+	time.Sleep(time.Millisecond * 500)
+	s.Record(r2, errors.New("error"))
+	expect(t, s, r1)
+}
+
+func TestPenalty(t *testing.T) {
+	s := NewSelector(Penalty(time.Second)).(*p2c)
+
+	// a route which fails fast isn't preferred to a slower working one
+	s.Start(r1)
+	s.Done(r1, time.Microsecond, errors.New("error"))
+	s.Start(r2)
+	s.Done(r2, time.Millisecond*10, nil)
+	expect(t, s, r2)
+}
+
+func TestExpiry(t *testing.T) {
+	s := NewSelector(Decay(time.Millisecond)).(*p2c)
+
+	s.Record(r1, nil)
+	s.Start(r2)
+	time.Sleep(time.Millisecond * 20)
+
+	// the stats of routes which are no longer called are removed, those
+	// with calls in flight are kept
+	s.Record("127.0.0.1:8002", nil)
+	if _, ok := s.stats[r1]; ok {
+		t.Fatal("Expected the stats of the route to be removed")
+	}
+	if _, ok := s.stats[r2]; !ok {
+		t.Fatal("Expected the stats of the route with calls in flight to be kept")
+	}
+	if len(s.stats) != 2 {
+		t.Fatalf("Expected the stats of 2 routes, got %d", len(s.stats))
+	}
+}
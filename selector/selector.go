@@ -2,7 +2,9 @@
 package selector
 
 import (
+	"context"
 	"errors"
+	"time"
 )
 
 var (
@@ -24,3 +26,35 @@ type Selector interface {
 
 // Next returns the next node
 type Next func() string
+
+// Observer is implemented by selectors which balance load using the calls in
+// flight to each route and how long they take
+type Observer interface {
+	// Start is called when a call to the route begins
+	Start(string)
+	// Done is called with the duration and error of the call once it ends. A
+	// call abandoned by the client, such as the slower of hedged calls, is done
+	// with context.Canceled, which says nothing of the route.
+	Done(string, time.Duration, error)
+}
+
+// Track a call to the route, returning the func to call with its result. If
+// the selector is an Observer it's told the duration of the call, otherwise
+// the error is recorded, unless the call was abandoned.
+func Track(s Selector, route string) func(error) {
+	o, ok := s.(Observer)
+	if !ok {
+		return func(err error) {
+			if err != context.Canceled {
+				s.Record(route, err)
+			}
+		}
+	}
+
+	start := time.Now()
+	o.Start(route)
+
+	return func(err error) {
+		o.Done(route, time.Since(start), err)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/avro"
 	"github.com/micro/go-micro/v3/codec/bytes"
+	"github.com/micro/go-micro/v3/codec/msgpack"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/metadata"
@@ -20,6 +22,12 @@ type bytesCodec struct{}
 type protoCodec struct{}
 type wrapCodec struct{ encoding.Codec }
 
+// marshalerCodec adapts a go-micro marshaler to a grpc codec
+type marshalerCodec struct{ codec.Marshaler }
+
+var msgpackCodec = marshalerCodec{msgpack.Marshaler{}}
+var avroCodec = marshalerCodec{avro.Marshaler{}}
+
 var jsonpbMarshaler = &jsonpb.Marshaler{
 	EnumsAsInts:  false,
 	EmitDefaults: false,
@@ -36,9 +44,18 @@ var (
 		"application/grpc+json":    jsonCodec{},
 		"application/grpc+proto":   protoCodec{},
 		"application/grpc+bytes":   bytesCodec{},
+		"application/msgpack":      msgpackCodec,
+		"application/x-msgpack":    msgpackCodec,
+		"application/grpc+msgpack": msgpackCodec,
+		"application/avro":         avroCodec,
+		"application/grpc+avro":    avroCodec,
 	}
 )
 
+func (m marshalerCodec) Name() string {
+	return m.Marshaler.String()
+}
+
 func (w wrapCodec) String() string {
 	return w.Codec.Name()
 }
@@ -70,6 +70,8 @@ func init() {
 	encoding.RegisterCodec(wrapCodec{jsonCodec{}})
 	encoding.RegisterCodec(wrapCodec{protoCodec{}})
 	encoding.RegisterCodec(wrapCodec{bytesCodec{}})
+	encoding.RegisterCodec(wrapCodec{msgpackCodec})
+	encoding.RegisterCodec(wrapCodec{avroCodec})
 }
 
 func newGRPCServer(opts ...server.Option) server.Server {
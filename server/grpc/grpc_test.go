@@ -7,6 +7,7 @@ import (
 
 	bmemory "github.com/micro/go-micro/v3/broker/memory"
 	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/codec/avro"
 	gcli "github.com/micro/go-micro/v3/client/grpc"
 	"github.com/micro/go-micro/v3/errors"
 	pberr "github.com/micro/go-micro/v3/errors/proto"
@@ -204,6 +205,51 @@ func TestGRPCServer(t *testing.T) {
 	}
 }
 
+func TestGRPCServerCodecs(t *testing.T) {
+	if err := avro.Register(new(pb.Request), `{"type": "record", "name": "Request", "fields": [
+		{"name": "uuid", "type": "string"}, {"name": "name", "type": "string"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := avro.Register(new(pb.Response), `{"type": "record", "name": "Response", "fields": [
+		{"name": "msg", "type": "string"}]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	r := rmemory.NewRegistry()
+	b := bmemory.NewBroker()
+	tr := tgrpc.NewTransport()
+
+	s := gsrv.NewServer(
+		server.Broker(b),
+		server.Name("foo"),
+		server.Registry(r),
+		server.Transport(tr),
+	)
+	pb.RegisterTestHandler(s, &testServer{})
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer s.Stop()
+
+	c := gcli.NewClient(
+		client.Router(rtreg.NewRouter(router.Registry(r))),
+		client.Broker(b),
+		client.Transport(tr),
+	)
+
+	for _, ct := range []string{"application/msgpack", "application/avro"} {
+		req := c.NewRequest("foo", "Test.Call", &pb.Request{Name: "John"}, client.WithContentType(ct))
+		rsp := pb.Response{}
+		if err := c.Call(context.TODO(), req, &rsp, client.WithAddress(s.Options().Address)); err != nil {
+			t.Fatalf("error calling server with %v: %v", ct, err)
+		}
+		if rsp.Msg != "Hello John" {
+			t.Fatalf("Got unexpected response with %v: %v", ct, rsp.Msg)
+		}
+	}
+}
+
 // TestGRPCServerWithPanicWrapper test grpc server with panic wrapper
 // gRPC server should not crash when wrapper crashed
 func TestGRPCServerWithPanicWrapper(t *testing.T) {
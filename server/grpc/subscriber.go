@@ -13,6 +13,7 @@ import (
 	"github.com/micro/go-micro/v3/metadata"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/util/compress"
 )
 
 const (
@@ -198,6 +199,20 @@ func (g *grpcServer) createSubHandler(sb *subscriber, opts server.Options) broke
 		for k, v := range msg.Header {
 			hdr[k] = v
 		}
+
+		// decompress the body into a copy, the message may be shared by subscribers
+		if len(hdr[compress.EncodingKey]) > 0 {
+			body, err := compress.Decode(hdr, msg.Body)
+			if err != nil {
+				return err
+			}
+			header := make(map[string]string, len(hdr))
+			for k, v := range hdr {
+				header[k] = v
+			}
+			msg = &broker.Message{Header: header, Body: body, Topic: msg.Topic}
+		}
+
 		delete(hdr, "Content-Type")
 		ctx := metadata.NewContext(context.Background(), hdr)
 
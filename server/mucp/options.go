@@ -6,14 +6,17 @@ import (
 	thttp "github.com/micro/go-micro/v3/network/transport/http"
 	"github.com/micro/go-micro/v3/registry/mdns"
 	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/util/compress"
 )
 
 func newOptions(opt ...server.Option) server.Options {
 	opts := server.Options{
-		Codecs:           make(map[string]codec.NewCodec),
-		Metadata:         map[string]string{},
-		RegisterInterval: server.DefaultRegisterInterval,
-		RegisterTTL:      server.DefaultRegisterTTL,
+		Codecs:            make(map[string]codec.NewCodec),
+		Metadata:          map[string]string{},
+		RegisterInterval:  server.DefaultRegisterInterval,
+		RegisterTTL:       server.DefaultRegisterTTL,
+		Compression:       compress.DefaultEncodings,
+		CompressThreshold: compress.DefaultThreshold,
 	}
 
 	for _, o := range opt {
@@ -5,13 +5,16 @@ import (
 	"sync"
 
 	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/codec/avro"
 	raw "github.com/micro/go-micro/v3/codec/bytes"
 	"github.com/micro/go-micro/v3/codec/grpc"
 	"github.com/micro/go-micro/v3/codec/json"
 	"github.com/micro/go-micro/v3/codec/jsonrpc"
+	"github.com/micro/go-micro/v3/codec/msgpack"
 	"github.com/micro/go-micro/v3/codec/proto"
 	"github.com/micro/go-micro/v3/codec/protorpc"
 	"github.com/micro/go-micro/v3/network/transport"
+	"github.com/micro/go-micro/v3/util/compress"
 	"github.com/oxtoacart/bpool"
 	"github.com/pkg/errors"
 )
@@ -27,6 +30,19 @@ type rpcCodec struct {
 	// check if we're the first
 	sync.RWMutex
 	first chan bool
+
+	// compression of the messages sent
+	compression compression
+}
+
+// compression of the message bodies sent to a client
+type compression struct {
+	// encoding to compress with, if any
+	encoding string
+	// minimum size of body compressed
+	threshold int
+	// encodings the server accepts
+	accept string
 }
 
 type readWriteCloser struct {
@@ -47,6 +63,9 @@ var (
 		"application/protobuf":     proto.NewCodec,
 		"application/proto-rpc":    protorpc.NewCodec,
 		"application/octet-stream": raw.NewCodec,
+		"application/msgpack":      msgpack.NewCodec,
+		"application/x-msgpack":    msgpack.NewCodec,
+		"application/avro":         avro.NewCodec,
 	}
 
 	// TODO: remove legacy codec list
@@ -164,19 +183,20 @@ func setupProtocol(msg *transport.Message) codec.NewCodec {
 	return nil
 }
 
-func newRpcCodec(req *transport.Message, socket transport.Socket, c codec.NewCodec) codec.Codec {
+func newRpcCodec(req *transport.Message, socket transport.Socket, c codec.NewCodec, comp compression) codec.Codec {
 	rwc := &readWriteCloser{
 		rbuf: bufferPool.Get(),
 		wbuf: bufferPool.Get(),
 	}
 
 	r := &rpcCodec{
-		buf:      rwc,
-		codec:    c(rwc),
-		req:      req,
-		socket:   socket,
-		protocol: "mucp",
-		first:    make(chan bool),
+		buf:         rwc,
+		codec:       c(rwc),
+		req:         req,
+		socket:      socket,
+		protocol:    "mucp",
+		first:       make(chan bool),
+		compression: comp,
 	}
 
 	// if grpc pre-load the buffer
@@ -330,6 +350,17 @@ func (c *rpcCodec) Write(r *codec.Message, b interface{}) error {
 		m.Header["Content-Type"] = c.req.Header["Content-Type"]
 	}
 
+	// advertise the encodings we accept
+	if len(c.compression.accept) > 0 {
+		m.Header[compress.AcceptKey] = c.compression.accept
+	}
+
+	// compress the body if it's large enough
+	body, err := compress.Encode(m.Header, body, c.compression.encoding, c.compression.threshold)
+	if err != nil {
+		return err
+	}
+
 	// send on the socket
 	return c.socket.Send(&transport.Message{
 		Header: m.Header,
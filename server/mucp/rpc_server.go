@@ -22,6 +22,7 @@ import (
 	"github.com/micro/go-micro/v3/server"
 	"github.com/micro/go-micro/v3/util/addr"
 	"github.com/micro/go-micro/v3/util/backoff"
+	"github.com/micro/go-micro/v3/util/compress"
 	mnet "github.com/micro/go-micro/v3/util/net"
 	"github.com/micro/go-micro/v3/util/socket"
 )
@@ -94,6 +95,19 @@ func (s *rpcServer) HandleEvent(msg *broker.Message) error {
 		ct = DefaultContentType
 	}
 
+	// decompress the body into a copy, the message may be shared by subscribers
+	if len(msg.Header[compress.EncodingKey]) > 0 {
+		hdr := make(map[string]string, len(msg.Header))
+		for k, v := range msg.Header {
+			hdr[k] = v
+		}
+		body, err := compress.Decode(hdr, msg.Body)
+		if err != nil {
+			return err
+		}
+		msg = &broker.Message{Header: hdr, Body: body, Topic: msg.Topic}
+	}
+
 	// get codec
 	cf, err := s.newCodec(ct)
 	if err != nil {
@@ -183,6 +197,19 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 			return
 		}
 
+		// decompress the body, removing the encoding from the header
+		body, err := compress.Decode(msg.Header, msg.Body)
+		if err != nil {
+			msg.Header["Micro-Error"] = err.Error()
+			if err := sock.Send(&transport.Message{
+				Header: msg.Header,
+			}); err != nil {
+				break
+			}
+			continue
+		}
+		msg.Body = body
+
 		// check the message header for
 		// Micro-Service is a request
 		// Micro-Topic is a message
@@ -319,7 +346,7 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 		}
 
 		// create a new rpc codec based on the pseudo socket and codec
-		rcodec := newRpcCodec(&msg, psock, cf)
+		rcodec := newRpcCodec(&msg, psock, cf, s.compression(msg.Header))
 		// check the protocol as well
 		protocol := rcodec.String()
 
@@ -450,6 +477,25 @@ func (s *rpcServer) newCodec(contentType string) (codec.NewCodec, error) {
 	return nil, fmt.Errorf("Unsupported Content-Type: %s", contentType)
 }
 
+// compression negotiates the compression of responses with the encodings the
+// client accepts
+func (s *rpcServer) compression(hdr map[string]string) compression {
+	s.RLock()
+	encodings := s.opts.Compression
+	threshold := s.opts.CompressThreshold
+	s.RUnlock()
+
+	if len(encodings) == 0 {
+		return compression{}
+	}
+
+	return compression{
+		encoding:  compress.Negotiate(hdr[compress.AcceptKey], encodings),
+		threshold: threshold,
+		accept:    compress.Accept(encodings),
+	}
+}
+
 func (s *rpcServer) Options() server.Options {
 	s.RLock()
 	opts := s.opts
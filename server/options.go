@@ -15,6 +15,7 @@ import (
 	thttp "github.com/micro/go-micro/v3/network/transport/http"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/registry/mdns"
+	"github.com/micro/go-micro/v3/util/compress"
 )
 
 type Options struct {
@@ -44,6 +45,11 @@ type Options struct {
 	// The router for requests
 	Router Router
 
+	// Compression encodings negotiated with clients, in order of preference
+	Compression []string
+	// CompressThreshold is the minimum size of response body compressed
+	CompressThreshold int
+
 	// TLSConfig specifies tls.Config for secure serving
 	TLSConfig *tls.Config
 
@@ -54,10 +60,12 @@ type Options struct {
 
 func newOptions(opt ...Option) Options {
 	opts := Options{
-		Codecs:           make(map[string]codec.NewCodec),
-		Metadata:         map[string]string{},
-		RegisterInterval: DefaultRegisterInterval,
-		RegisterTTL:      DefaultRegisterTTL,
+		Codecs:            make(map[string]codec.NewCodec),
+		Metadata:          map[string]string{},
+		RegisterInterval:  DefaultRegisterInterval,
+		RegisterTTL:       DefaultRegisterTTL,
+		Compression:       compress.DefaultEncodings,
+		CompressThreshold: compress.DefaultThreshold,
 	}
 
 	for _, o := range opt {
@@ -199,6 +207,23 @@ func Metadata(md map[string]string) Option {
 	}
 }
 
+// Compression sets the encodings used to compress response bodies, in order of
+// preference. Responses are compressed with the first the client accepts.
+// Passing none turns compression off, though compressed requests are still
+// decompressed.
+func Compression(encodings ...string) Option {
+	return func(o *Options) {
+		o.Compression = encodings
+	}
+}
+
+// CompressThreshold sets the minimum size of response body compressed
+func CompressThreshold(n int) Option {
+	return func(o *Options) {
+		o.CompressThreshold = n
+	}
+}
+
 // RegisterCheck run func before registry service
 func RegisterCheck(fn func(context.Context) error) Option {
 	return func(o *Options) {
@@ -17,13 +17,18 @@ type cache struct {
 // NewStore returns a new cache store
 func NewStore(store store.Store, opts ...store.Option) store.Store {
 	cf := &cache{
-		m: memory.NewStore(opts...),
+		m: memory.NewStore(memoryOptions(opts)...),
 		b: store,
 	}
 	return cf
 
 }
 
+// memoryOptions keeps the versions of the backing store in the memory store
+func memoryOptions(opts []store.Option) []store.Option {
+	return append(append([]store.Option{}, opts...), memory.KeepVersion())
+}
+
 func (c *cache) init(opts ...store.Option) error {
 	for _, o := range opts {
 		o(&c.options)
@@ -36,7 +41,7 @@ func (c *cache) Init(opts ...store.Option) error {
 	if err := c.init(opts...); err != nil {
 		return err
 	}
-	if err := c.m.Init(opts...); err != nil {
+	if err := c.m.Init(memoryOptions(opts)...); err != nil {
 		return err
 	}
 	return c.b.Init(opts...)
@@ -68,13 +73,24 @@ func (c *cache) Read(key string, opts ...store.ReadOption) ([]*store.Record, err
 }
 
 // Write() writes a record to the store, and returns an error if the record was not written.
-// If the write succeeds in writing to memory but fails to write through to file, you'll receive an error
-// but the value may still reside in memory so appropriate action should be taken.
+// The record is written to the backing store first, which checks any conditions of the write,
+// and then read back in to memory so the cached version matches the backing store.
 func (c *cache) Write(r *store.Record, opts ...store.WriteOption) error {
-	if err := c.m.Write(r, opts...); err != nil {
+	if err := c.b.Write(r, opts...); err != nil {
 		return err
 	}
-	return c.b.Write(r, opts...)
+
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	recs, err := c.b.Read(r.Key, store.ReadFrom(options.Database, options.Table))
+	if err != nil || len(recs) == 0 {
+		// drop any stale copy so the next read goes to the backing store
+		return c.m.Delete(r.Key, store.DeleteFrom(options.Database, options.Table))
+	}
+	return c.m.Write(recs[0], store.WriteTo(options.Database, options.Table))
 }
 
 // Delete removes the record with the corresponding key from the store.
@@ -114,6 +130,12 @@ func (c *cache) List(opts ...store.ListOption) ([]string, error) {
 	return keys, err
 }
 
+// Watch the backing store, which is where every write and delete is made.
+// ErrWatchNotSupported is returned if it can't be watched.
+func (c *cache) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return store.Watch(c.b, prefix, opts...)
+}
+
 // Close the store and the underlying store
 func (c *cache) Close() error {
 	if err := c.m.Close(); err != nil {
@@ -204,6 +204,10 @@ func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
 		o(&options)
 	}
 
+	if options.Ranged() {
+		return nil, store.ErrRangeNotSupported
+	}
+
 	// create the db if not exists
 	if err := s.createDB(options.Database, options.Table); err != nil {
 		return nil, err
@@ -324,6 +328,10 @@ func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 		o(&options)
 	}
 
+	if options.Ranged() {
+		return nil, store.ErrRangeNotSupported
+	}
+
 	// create the db if not exists
 	if err := s.createDB(options.Database, options.Table); err != nil {
 		return nil, err
@@ -411,6 +419,11 @@ func (s *sqlStore) Write(r *store.Record, opts ...store.WriteOption) error {
 		o(&options)
 	}
 
+	// records aren't versioned so conditions can't be checked
+	if options.Conditional() {
+		return store.ErrConditionNotSupported
+	}
+
 	// create the db if not exists
 	if err := s.createDB(options.Database, options.Table); err != nil {
 		return err
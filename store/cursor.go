@@ -0,0 +1,140 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	// ErrInvalidCursor is returned when a cursor can't be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrRangeNotSupported is returned by stores which can't read or list a range of keys
+	ErrRangeNotSupported = errors.New("key ranges not supported")
+)
+
+// Order of the keys returned by a read or list
+type Order int
+
+const (
+	// OrderAsc returns keys in ascending order
+	OrderAsc Order = iota
+	// OrderDesc returns keys in descending order
+	OrderDesc
+)
+
+// String returns human readable order
+func (o Order) String() string {
+	switch o {
+	case OrderAsc:
+		return "asc"
+	case OrderDesc:
+		return "desc"
+	default:
+		return "unknown"
+	}
+}
+
+// Cursor returns an opaque cursor which continues a read or list after
+// the key, which should be the last one returned by the previous page.
+func Cursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// KeyRange is the range of keys scanned by a read or list. It's intended
+// for implementations, so the prefix, range and cursor options are treated
+// the same way by every store.
+type KeyRange struct {
+	// Start is the lowest key in the range
+	Start string
+	// End is the key the range stops before, or empty if it's unbounded
+	End string
+	// Order the keys are scanned in
+	Order Order
+}
+
+// Contains returns true if the key is in the range
+func (r KeyRange) Contains(key string) bool {
+	return key >= r.Start && (len(r.End) == 0 || key < r.End)
+}
+
+// Empty returns true if no key can be in the range
+func (r KeyRange) Empty() bool {
+	return len(r.End) > 0 && r.Start >= r.End
+}
+
+// Scan returns true if the read is of every key matching the options
+// rather than only the key given
+func (r ReadOptions) Scan() bool {
+	return r.Prefix || r.Suffix || r.Ranged()
+}
+
+// Ranged returns true if a range, order or cursor is set
+func (r ReadOptions) Ranged() bool {
+	return len(r.Start) > 0 || len(r.End) > 0 || r.Order != OrderAsc || len(r.Cursor) > 0
+}
+
+// Range returns the keys scanned by a read of key
+func (r ReadOptions) Range(key string) (KeyRange, error) {
+	var prefix string
+	if r.Prefix {
+		prefix = key
+	}
+	return newKeyRange(prefix, r.Start, r.End, r.Cursor, r.Order)
+}
+
+// Ranged returns true if a range, order or cursor is set
+func (l ListOptions) Ranged() bool {
+	return len(l.Start) > 0 || len(l.End) > 0 || l.Order != OrderAsc || len(l.Cursor) > 0
+}
+
+// Range returns the keys scanned by the list
+func (l ListOptions) Range() (KeyRange, error) {
+	return newKeyRange(l.Prefix, l.Start, l.End, l.Cursor, l.Order)
+}
+
+func newKeyRange(prefix, start, end, cursor string, order Order) (KeyRange, error) {
+	r := KeyRange{Start: start, End: end, Order: order}
+
+	// narrow the range to the prefix
+	if prefix > r.Start {
+		r.Start = prefix
+	}
+	if pe := prefixEnd(prefix); len(pe) > 0 && (len(r.End) == 0 || pe < r.End) {
+		r.End = pe
+	}
+
+	if len(cursor) == 0 {
+		return r, nil
+	}
+
+	// continue after the last key returned
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return r, ErrInvalidCursor
+	}
+	last := string(b)
+
+	if order == OrderDesc {
+		if len(r.End) == 0 || last < r.End {
+			r.End = last
+		}
+	} else if next := last + "\x00"; next > r.Start {
+		// the lowest key after last
+		r.Start = next
+	}
+
+	return r, nil
+}
+
+// prefixEnd returns the lowest key greater than every key with the
+// prefix, or an empty string if there isn't one
+func prefixEnd(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
@@ -3,9 +3,11 @@ package file
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/micro/go-micro/v3/store"
@@ -23,6 +25,14 @@ var (
 
 	// bucket used for data storage
 	dataBucket = "data"
+	// bucket used for the revision of the table
+	metaBucket = "meta"
+	// key of the revision in the meta bucket
+	revisionKey = "revision"
+
+	// feed of the changes made by every file store in the process. Other
+	// processes writing to the same files aren't watched.
+	feed store.Feed
 )
 
 // NewStore returns a file store
@@ -48,20 +58,61 @@ type record struct {
 	Value     []byte
 	Metadata  map[string]interface{}
 	ExpiresAt time.Time
+	Version   uint64
 }
 
 func key(database, table string) string {
 	return database + ":" + table
 }
 
-func (m *fileStore) delete(db *bolt.DB, key string) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(dataBucket))
-		if b == nil {
-			return nil
-		}
-		return b.Delete([]byte(key))
+func (m *fileStore) delete(db *bolt.DB, key string) (*store.Event, error) {
+	var ev *store.Event
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+		ev, err = deleteRecord(tx, key)
+		return err
 	})
+	return ev, err
+}
+
+// deleteRecord deletes the key, returning the event for the
+// change or nil if the key didn't exist
+func deleteRecord(tx *bolt.Tx, key string) (*store.Event, error) {
+	b := tx.Bucket([]byte(dataBucket))
+	if b == nil {
+		return nil, nil
+	}
+	existing, err := getRecord(tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Delete([]byte(key)); err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	rev, err := nextRevision(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &store.Event{Type: store.Delete, Record: existing, Revision: rev}, nil
+}
+
+// nextRevision increments the revision of the table
+func nextRevision(tx *bolt.Tx) (uint64, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return 0, err
+	}
+	var rev uint64
+	if v := b.Get([]byte(revisionKey)); len(v) == 8 {
+		rev = binary.BigEndian.Uint64(v)
+	}
+	rev++
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, rev)
+	return rev, b.Put([]byte(revisionKey), v)
 }
 
 func (m *fileStore) init(opts ...store.Option) error {
@@ -88,13 +139,19 @@ func (m *fileStore) init(opts ...store.Option) error {
 	return nil
 }
 
-func (f *fileStore) getDB(database, table string) (*bolt.DB, error) {
+// resolve the database and table defaults of the store
+func (f *fileStore) resolve(database, table string) (string, string) {
 	if len(database) == 0 {
 		database = f.options.Database
 	}
 	if len(table) == 0 {
 		table = f.options.Table
 	}
+	return database, table
+}
+
+func (f *fileStore) getDB(database, table string) (*bolt.DB, error) {
+	database, table = f.resolve(database, table)
 
 	// create a directory /tmp/micro
 	dir := filepath.Join(DefaultDir, database)
@@ -110,10 +167,16 @@ func (f *fileStore) getDB(database, table string) (*bolt.DB, error) {
 	return bolt.Open(dbPath, 0700, &bolt.Options{Timeout: 5 * time.Second})
 }
 
-func (m *fileStore) list(db *bolt.DB, limit, offset uint, prefix, suffix string) []string {
+// list the keys in the range. The bucket is scanned from the start of the range,
+// so paging with a cursor is linear, but an offset is re-walked on every call.
+func (m *fileStore) list(db *bolt.DB, limit, offset uint, r store.KeyRange, suffix string) []string {
 
 	var keys []string
 
+	if r.Empty() {
+		return keys
+	}
+
 	db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
 		// nothing to read
@@ -122,22 +185,33 @@ func (m *fileStore) list(db *bolt.DB, limit, offset uint, prefix, suffix string)
 		}
 		c := b.Cursor()
 		var k, v []byte
+		var next func() ([]byte, []byte)
 		var cont func(k []byte) bool
 
-		if prefix != "" {
-			// for prefix we can speed up the search, not for suffix though :(
-			k, v = c.Seek([]byte(prefix))
+		if r.Order == store.OrderDesc {
+			// start from the last key before the end of the range
+			if len(r.End) > 0 {
+				if k, v = c.Seek([]byte(r.End)); k == nil {
+					k, v = c.Last()
+				} else {
+					k, v = c.Prev()
+				}
+			} else {
+				k, v = c.Last()
+			}
+			next = c.Prev
 			cont = func(k []byte) bool {
-				return bytes.HasPrefix(k, []byte(prefix))
+				return string(k) >= r.Start
 			}
 		} else {
-			k, v = c.First()
+			k, v = c.Seek([]byte(r.Start))
+			next = c.Next
 			cont = func(k []byte) bool {
-				return true
+				return len(r.End) == 0 || string(k) < r.End
 			}
 		}
 
-		for ; k != nil && cont(k); k, v = c.Next() {
+		for ; k != nil && cont(k); k, v = next() {
 			storedRecord := &record{}
 
 			if err := json.Unmarshal(v, storedRecord); err != nil {
@@ -170,33 +244,31 @@ func (m *fileStore) list(db *bolt.DB, limit, offset uint, prefix, suffix string)
 }
 
 func (m *fileStore) get(db *bolt.DB, k string) (*store.Record, error) {
-	var value []byte
-
-	db.View(func(tx *bolt.Tx) error {
-		// @todo this is still very experimental...
-		b := tx.Bucket([]byte(dataBucket))
-		if b == nil {
-			return nil
-		}
+	var r *store.Record
 
-		value = b.Get([]byte(k))
-		return nil
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		r, err = getRecord(tx, k)
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	if value == nil {
+	if r == nil {
 		return nil, store.ErrNotFound
 	}
 
-	storedRecord := &record{}
-
-	if err := json.Unmarshal(value, storedRecord); err != nil {
-		return nil, err
-	}
+	return r, nil
+}
 
+// toRecord converts a stored record, returning nil if it has expired
+func toRecord(storedRecord *record) *store.Record {
 	newRecord := &store.Record{}
 	newRecord.Key = storedRecord.Key
 	newRecord.Value = storedRecord.Value
 	newRecord.Metadata = make(map[string]interface{})
+	newRecord.Version = storedRecord.Version
 
 	for k, v := range storedRecord.Metadata {
 		newRecord.Metadata[k] = v
@@ -204,21 +276,61 @@ func (m *fileStore) get(db *bolt.DB, k string) (*store.Record, error) {
 
 	if !storedRecord.ExpiresAt.IsZero() {
 		if storedRecord.ExpiresAt.Before(time.Now()) {
-			return nil, store.ErrNotFound
+			return nil
 		}
 		newRecord.Expiry = time.Until(storedRecord.ExpiresAt)
 	}
 
-	return newRecord, nil
+	return newRecord
+}
+
+// getRecord reads a record within a transaction, returning nil if it doesn't exist
+func getRecord(tx *bolt.Tx, key string) (*store.Record, error) {
+	b := tx.Bucket([]byte(dataBucket))
+	if b == nil {
+		return nil, nil
+	}
+	value := b.Get([]byte(key))
+	if value == nil {
+		return nil, nil
+	}
+	storedRecord := &record{}
+	if err := json.Unmarshal(value, storedRecord); err != nil {
+		return nil, err
+	}
+	return toRecord(storedRecord), nil
+}
+
+func (m *fileStore) set(db *bolt.DB, r *store.Record, opts store.WriteOptions) (*store.Event, error) {
+	var ev *store.Event
+	err := db.Update(func(tx *bolt.Tx) error {
+		existing, err := getRecord(tx, r.Key)
+		if err != nil {
+			return err
+		}
+		if err := opts.Check(existing); err != nil {
+			return err
+		}
+		ev, err = putRecord(tx, r, existing)
+		return err
+	})
+	return ev, err
 }
 
-func (m *fileStore) set(db *bolt.DB, r *store.Record) error {
+// putRecord writes the record at the version after the existing
+// record, returning the event for the change
+func putRecord(tx *bolt.Tx, r *store.Record, existing *store.Record) (*store.Event, error) {
 	// copy the incoming record and then
 	// convert the expiry in to a hard timestamp
 	item := &record{}
 	item.Key = r.Key
 	item.Value = r.Value
 	item.Metadata = make(map[string]interface{})
+	item.Version = 1
+
+	if existing != nil {
+		item.Version = existing.Version + 1
+	}
 
 	if r.Expiry != 0 {
 		item.ExpiresAt = time.Now().Add(r.Expiry)
@@ -231,17 +343,37 @@ func (m *fileStore) set(db *bolt.DB, r *store.Record) error {
 	// marshal the data
 	data, _ := json.Marshal(item)
 
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(dataBucket))
-		if b == nil {
-			var err error
-			b, err = tx.CreateBucketIfNotExists([]byte(dataBucket))
-			if err != nil {
-				return err
-			}
+	b := tx.Bucket([]byte(dataBucket))
+	if b == nil {
+		var err error
+		b, err = tx.CreateBucketIfNotExists([]byte(dataBucket))
+		if err != nil {
+			return nil, err
 		}
-		return b.Put([]byte(r.Key), data)
-	})
+	}
+	if err := b.Put([]byte(r.Key), data); err != nil {
+		return nil, err
+	}
+
+	rev, err := nextRevision(tx)
+	if err != nil {
+		return nil, err
+	}
+	ev := &store.Event{Type: store.Create, Record: toRecord(item), Revision: rev}
+	if existing != nil {
+		ev.Type = store.Update
+	}
+	return ev, nil
+}
+
+// notify the watchers of the change to the table. It's called before the
+// file is closed so events are sent in the order they were committed.
+func (m *fileStore) notify(database, table string, ev *store.Event) {
+	if ev == nil {
+		return
+	}
+	ev.Database, ev.Table = m.resolve(database, table)
+	feed.Send(ev)
 }
 
 func (f *fileStore) Close() error {
@@ -264,7 +396,12 @@ func (m *fileStore) Delete(key string, opts ...store.DeleteOption) error {
 	}
 	defer db.Close()
 
-	return m.delete(db, key)
+	ev, err := m.delete(db, key)
+	if err != nil {
+		return err
+	}
+	m.notify(deleteOptions.Database, deleteOptions.Table, ev)
+	return nil
 }
 
 func (m *fileStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
@@ -281,18 +418,18 @@ func (m *fileStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 
 	var keys []string
 
-	// Handle Prefix / suffix
-	if readOpts.Prefix || readOpts.Suffix {
-		prefix := ""
-		if readOpts.Prefix {
-			prefix = key
+	// Handle Prefix / suffix / range
+	if readOpts.Scan() {
+		r, err := readOpts.Range(key)
+		if err != nil {
+			return nil, err
 		}
 		suffix := ""
 		if readOpts.Suffix {
 			suffix = key
 		}
 		// list the keys
-		keys = m.list(db, readOpts.Limit, readOpts.Offset, prefix, suffix)
+		keys = m.list(db, readOpts.Limit, readOpts.Offset, r, suffix)
 	} else {
 		keys = []string{key}
 	}
@@ -334,10 +471,15 @@ func (m *fileStore) Write(r *store.Record, opts ...store.WriteOption) error {
 			newRecord.Metadata[k] = v
 		}
 
-		return m.set(db, &newRecord)
+		r = &newRecord
 	}
 
-	return m.set(db, r)
+	ev, err := m.set(db, r, writeOpts)
+	if err != nil {
+		return err
+	}
+	m.notify(writeOpts.Database, writeOpts.Table, ev)
+	return nil
 }
 
 func (m *fileStore) Options() store.Options {
@@ -351,13 +493,18 @@ func (m *fileStore) List(opts ...store.ListOption) ([]string, error) {
 		o(&listOptions)
 	}
 
+	r, err := listOptions.Range()
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := m.getDB(listOptions.Database, listOptions.Table)
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	allKeys := m.list(db, listOptions.Limit, listOptions.Offset, listOptions.Prefix, listOptions.Suffix)
+	allKeys := m.list(db, listOptions.Limit, listOptions.Offset, r, listOptions.Suffix)
 
 	return allKeys, nil
 }
@@ -365,3 +512,116 @@ func (m *fileStore) List(opts ...store.ListOption) ([]string, error) {
 func (m *fileStore) String() string {
 	return "file"
 }
+
+// Begin a transaction. Each table is a separate bolt file, so the ops on
+// each table are applied in a bolt transaction of their own. Every file is
+// written before any is committed, so a failed write leaves all of them
+// untouched, but a crash part way through committing the files can leave
+// a transaction spanning tables partially applied.
+func (m *fileStore) Begin() (store.Tx, error) {
+	return store.NewTx(m, m.commit), nil
+}
+
+func (m *fileStore) commit(ops []store.Op) error {
+	// group the ops by the file they apply to
+	files := make(map[string][]store.Op)
+	for _, op := range ops {
+		k := key(op.Database, op.Table)
+		files[k] = append(files[k], op)
+	}
+
+	// open the files in a consistent order so concurrent
+	// commits don't wait on each other's locks
+	names := make([]string, 0, len(files))
+	for k := range files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var dbs []*bolt.DB
+	var txs []*bolt.Tx
+	var evs []*store.Event
+	defer func() {
+		// rolling back a committed transaction is a no-op
+		for _, tx := range txs {
+			tx.Rollback()
+		}
+		for _, db := range dbs {
+			db.Close()
+		}
+	}()
+
+	for _, name := range names {
+		fops := files[name]
+
+		db, err := m.getDB(fops[0].Database, fops[0].Table)
+		if err != nil {
+			return err
+		}
+		dbs = append(dbs, db)
+
+		tx, err := db.Begin(true)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, tx)
+
+		// check every condition before anything is applied
+		for _, op := range fops {
+			if op.Delete || !op.Options.Conditional() {
+				continue
+			}
+			existing, err := getRecord(tx, op.Record.Key)
+			if err != nil {
+				return err
+			}
+			if err := op.Options.Check(existing); err != nil {
+				return err
+			}
+		}
+
+		for _, op := range fops {
+			var ev *store.Event
+			if op.Delete {
+				ev, err = deleteRecord(tx, op.Record.Key)
+			} else {
+				var existing *store.Record
+				if existing, err = getRecord(tx, op.Record.Key); err == nil {
+					ev, err = putRecord(tx, op.Record, existing)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if ev != nil {
+				ev.Database, ev.Table = op.Database, op.Table
+				evs = append(evs, ev)
+			}
+		}
+	}
+
+	for _, tx := range txs {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	for _, ev := range evs {
+		m.notify(ev.Database, ev.Table, ev)
+	}
+
+	return nil
+}
+
+// Watch the keys with the prefix. Changes made by the file stores of
+// this process are sent to the watchers, numbered by the revision of
+// the table, which is kept in its file.
+func (m *fileStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	var watchOpts store.WatchOptions
+	for _, o := range opts {
+		o(&watchOpts)
+	}
+
+	database, table := m.resolve(watchOpts.Database, watchOpts.Table)
+	return feed.Watch(database, table, prefix), nil
+}
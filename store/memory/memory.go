@@ -21,6 +21,7 @@ func NewStore(opts ...store.Option) store.Store {
 			Table:    "micro",
 		},
 		stores: map[string]*cache.Cache{}, // cache.New(cache.NoExpiration, 5*time.Minute),
+		tables: map[string]*table{},
 	}
 	for _, o := range opts {
 		o(&s.options)
@@ -33,6 +34,19 @@ type memoryStore struct {
 	options store.Options
 
 	stores map[string]*cache.Cache
+	tables map[string]*table
+
+	// the watchers of the store
+	feed store.Feed
+}
+
+// table holds the lock of a table, which is held exclusively by writes,
+// deletes and commits so versions are incremented atomically and a
+// transaction is seen all at once, and the revision of the table, which
+// is incremented under the lock with every change to it
+type table struct {
+	sync.RWMutex
+	revision uint64
 }
 
 type storeRecord struct {
@@ -40,16 +54,38 @@ type storeRecord struct {
 	value     []byte
 	metadata  map[string]interface{}
 	expiresAt time.Time
+	version   uint64
 }
 
-func (m *memoryStore) prefix(database, table string) string {
+// resolve the database and table defaults of the store
+func (m *memoryStore) resolve(database, table string) (string, string) {
 	if len(database) == 0 {
 		database = m.options.Database
 	}
 	if len(table) == 0 {
 		table = m.options.Table
 	}
-	return filepath.Join(database, table)
+	return database, table
+}
+
+func (m *memoryStore) prefix(database, table string) string {
+	return filepath.Join(m.resolve(database, table))
+}
+
+// table returns the lock and revision of the table with the prefix
+func (m *memoryStore) table(prefix string) *table {
+	m.RLock()
+	t := m.tables[prefix]
+	m.RUnlock()
+	if t == nil {
+		m.Lock()
+		if m.tables[prefix] == nil {
+			m.tables[prefix] = &table{}
+		}
+		t = m.tables[prefix]
+		m.Unlock()
+	}
+	return t
 }
 
 func (m *memoryStore) getStore(prefix string) *cache.Cache {
@@ -84,6 +120,7 @@ func (m *memoryStore) get(prefix, key string) (*store.Record, error) {
 	newRecord.Key = strings.TrimPrefix(storedRecord.key, prefix+"/")
 	newRecord.Value = make([]byte, len(storedRecord.value))
 	newRecord.Metadata = make(map[string]interface{})
+	newRecord.Version = storedRecord.version
 
 	// copy the value into the new record
 	copy(newRecord.Value, storedRecord.value)
@@ -101,7 +138,20 @@ func (m *memoryStore) get(prefix, key string) (*store.Record, error) {
 	return newRecord, nil
 }
 
-func (m *memoryStore) set(prefix string, r *store.Record) {
+// set the record if the conditions of the write are met. It must be
+// called with the lock of the table held exclusively.
+func (m *memoryStore) set(database, table string, r *store.Record, opts store.WriteOptions) error {
+	prefix := m.prefix(database, table)
+	existing, err := m.get(prefix, r.Key)
+	if err == store.ErrNotFound {
+		existing = nil
+	} else if err != nil {
+		return err
+	}
+	if err := opts.Check(existing); err != nil {
+		return err
+	}
+
 	// copy the incoming record and then
 	// convert the expiry in to a hard timestamp
 	i := &storeRecord{}
@@ -109,6 +159,16 @@ func (m *memoryStore) set(prefix string, r *store.Record) {
 	i.value = make([]byte, len(r.Value))
 	i.metadata = make(map[string]interface{})
 
+	// set the version
+	switch {
+	case m.keepVersion():
+		i.version = r.Version
+	case existing != nil:
+		i.version = existing.Version + 1
+	default:
+		i.version = 1
+	}
+
 	// copy the the value
 	copy(i.value, r.Value)
 
@@ -123,13 +183,45 @@ func (m *memoryStore) set(prefix string, r *store.Record) {
 	}
 
 	m.getStore(prefix).Set(r.Key, i, r.Expiry)
+
+	typ := store.Create
+	if existing != nil {
+		typ = store.Update
+	}
+	rec, err := m.get(prefix, r.Key)
+	if err != nil {
+		return err
+	}
+	m.notify(typ, database, table, rec)
+	return nil
 }
 
-func (m *memoryStore) delete(prefix, key string) {
+// delete the record. It must be called with the lock of the table held exclusively.
+func (m *memoryStore) delete(database, table, key string) {
+	prefix := m.prefix(database, table)
+	existing, err := m.get(prefix, key)
+	if err != nil {
+		return
+	}
 	m.getStore(prefix).Delete(key)
+	m.notify(store.Delete, database, table, existing)
+}
+
+// notify the watchers of a change. It must be called with the lock of the table held exclusively.
+func (m *memoryStore) notify(typ store.EventType, database, table string, r *store.Record) {
+	database, table = m.resolve(database, table)
+	t := m.table(m.prefix(database, table))
+	t.revision++
+	m.feed.Send(&store.Event{
+		Type:     typ,
+		Database: database,
+		Table:    table,
+		Record:   r,
+		Revision: t.revision,
+	})
 }
 
-func (m *memoryStore) list(prefix string, limit, offset uint, prefixFilter, suffixFilter string) []string {
+func (m *memoryStore) list(prefix string, limit, offset uint, r store.KeyRange, suffixFilter string) []string {
 
 	allItems := m.getStore(prefix).Items()
 
@@ -142,9 +234,13 @@ func (m *memoryStore) list(prefix string, limit, offset uint, prefixFilter, suff
 		i++
 	}
 	keys := make([]string, 0, len(allKeys))
-	sort.Slice(allKeys, func(i, j int) bool { return allKeys[i] < allKeys[j] })
+	if r.Order == store.OrderDesc {
+		sort.Sort(sort.Reverse(sort.StringSlice(allKeys)))
+	} else {
+		sort.Strings(allKeys)
+	}
 	for _, k := range allKeys {
-		if prefixFilter != "" && !strings.HasPrefix(k, prefixFilter) {
+		if !r.Contains(k) {
 			continue
 		}
 		if suffixFilter != "" && !strings.HasSuffix(k, suffixFilter) {
@@ -192,18 +288,22 @@ func (m *memoryStore) Read(key string, opts ...store.ReadOption) ([]*store.Recor
 
 	prefix := m.prefix(readOpts.Database, readOpts.Table)
 
+	t := m.table(prefix)
+	t.RLock()
+	defer t.RUnlock()
+
 	var keys []string
-	// Handle Prefix / suffix
-	if readOpts.Prefix || readOpts.Suffix {
-		prefixFilter := ""
-		if readOpts.Prefix {
-			prefixFilter = key
+	// Handle Prefix / suffix / range
+	if readOpts.Scan() {
+		r, err := readOpts.Range(key)
+		if err != nil {
+			return nil, err
 		}
 		suffixFilter := ""
 		if readOpts.Suffix {
 			suffixFilter = key
 		}
-		keys = m.list(prefix, readOpts.Limit, readOpts.Offset, prefixFilter, suffixFilter)
+		keys = m.list(prefix, readOpts.Limit, readOpts.Offset, r, suffixFilter)
 	} else {
 		keys = []string{key}
 	}
@@ -227,7 +327,9 @@ func (m *memoryStore) Write(r *store.Record, opts ...store.WriteOption) error {
 		o(&writeOpts)
 	}
 
-	prefix := m.prefix(writeOpts.Database, writeOpts.Table)
+	t := m.table(m.prefix(writeOpts.Database, writeOpts.Table))
+	t.Lock()
+	defer t.Unlock()
 
 	if len(opts) > 0 {
 		// Copy the record before applying options, or the incoming record will be mutated
@@ -237,19 +339,17 @@ func (m *memoryStore) Write(r *store.Record, opts ...store.WriteOption) error {
 		newRecord.Metadata = make(map[string]interface{})
 		copy(newRecord.Value, r.Value)
 		newRecord.Expiry = r.Expiry
+		newRecord.Version = r.Version
 
 		for k, v := range r.Metadata {
 			newRecord.Metadata[k] = v
 		}
 
-		m.set(prefix, &newRecord)
-		return nil
+		return m.set(writeOpts.Database, writeOpts.Table, &newRecord, writeOpts)
 	}
 
 	// set
-	m.set(prefix, r)
-
-	return nil
+	return m.set(writeOpts.Database, writeOpts.Table, r, writeOpts)
 }
 
 func (m *memoryStore) Delete(key string, opts ...store.DeleteOption) error {
@@ -258,8 +358,11 @@ func (m *memoryStore) Delete(key string, opts ...store.DeleteOption) error {
 		o(&deleteOptions)
 	}
 
-	prefix := m.prefix(deleteOptions.Database, deleteOptions.Table)
-	m.delete(prefix, key)
+	t := m.table(m.prefix(deleteOptions.Database, deleteOptions.Table))
+	t.Lock()
+	defer t.Unlock()
+
+	m.delete(deleteOptions.Database, deleteOptions.Table, key)
 	return nil
 }
 
@@ -275,6 +378,81 @@ func (m *memoryStore) List(opts ...store.ListOption) ([]string, error) {
 	}
 
 	prefix := m.prefix(listOptions.Database, listOptions.Table)
-	keys := m.list(prefix, listOptions.Limit, listOptions.Offset, listOptions.Prefix, listOptions.Suffix)
+
+	r, err := listOptions.Range()
+	if err != nil {
+		return nil, err
+	}
+
+	t := m.table(prefix)
+	t.RLock()
+	defer t.RUnlock()
+
+	keys := m.list(prefix, listOptions.Limit, listOptions.Offset, r, listOptions.Suffix)
 	return keys, nil
 }
+
+// Begin a transaction. Its writes and deletes are applied under the locks
+// of the tables they're made to, so other operations see all or none of them.
+func (m *memoryStore) Begin() (store.Tx, error) {
+	return store.NewTx(m, m.commit), nil
+}
+
+func (m *memoryStore) commit(ops []store.Op) error {
+	// lock the tables written to, in order so commits don't deadlock
+	var prefixes []string
+	seen := make(map[string]bool)
+	for _, op := range ops {
+		prefix := m.prefix(op.Database, op.Table)
+		if !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		t := m.table(prefix)
+		t.Lock()
+		defer t.Unlock()
+	}
+
+	// check every condition before anything is applied
+	for _, op := range ops {
+		if op.Delete || !op.Options.Conditional() {
+			continue
+		}
+		existing, err := m.get(m.prefix(op.Database, op.Table), op.Record.Key)
+		if err == store.ErrNotFound {
+			existing = nil
+		} else if err != nil {
+			return err
+		}
+		if err := op.Options.Check(existing); err != nil {
+			return err
+		}
+	}
+
+	for _, op := range ops {
+		if op.Delete {
+			m.delete(op.Database, op.Table, op.Record.Key)
+			continue
+		}
+		if err := m.set(op.Database, op.Table, op.Record, store.WriteOptions{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Watch the keys with the prefix. Every write and delete is sent to the
+// watchers in the order it was made, numbered by the revision of its table.
+func (m *memoryStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	var watchOpts store.WatchOptions
+	for _, o := range opts {
+		o(&watchOpts)
+	}
+
+	database, table := m.resolve(watchOpts.Database, watchOpts.Table)
+	return m.feed.Watch(database, table, prefix), nil
+}
@@ -0,0 +1,29 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v3/store"
+)
+
+type keepVersionKey struct{}
+
+// KeepVersion stores the version of each written record as given rather
+// than incrementing it. It's intended for caching the records of another
+// store, so the versions read from the cache are those of the original.
+func KeepVersion() store.Option {
+	return func(o *store.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, keepVersionKey{}, true)
+	}
+}
+
+func (m *memoryStore) keepVersion() bool {
+	if m.options.Context == nil {
+		return false
+	}
+	keep, _ := m.options.Context.Value(keepVersionKey{}).(bool)
+	return keep
+}
@@ -19,6 +19,15 @@ func (n *noopStore) Read(key string, opts ...ReadOption) ([]*Record, error) {
 }
 
 func (n *noopStore) Write(r *Record, opts ...WriteOption) error {
+	var options WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// nothing is stored so conditions can't be checked
+	if options.Conditional() {
+		return ErrConditionNotSupported
+	}
 	return nil
 }
 
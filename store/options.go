@@ -1,6 +1,7 @@
 package store
 
 import (
+	"bytes"
 	"context"
 )
 
@@ -62,6 +63,14 @@ type ReadOptions struct {
 	Limit uint
 	// Offset when combined with Limit supports pagination
 	Offset uint
+	// Start returns records with keys from start onwards
+	Start string
+	// End returns records with keys before end
+	End string
+	// Order the records are returned in, by key
+	Order Order
+	// Cursor continues a previous read after the key it was made from
+	Cursor string
 }
 
 // ReadOption sets values in ReadOptions
@@ -103,10 +112,40 @@ func ReadOffset(o uint) ReadOption {
 	}
 }
 
+// ReadRange returns the records with keys from start up to but not including end.
+// Either may be empty to leave that side of the range open.
+func ReadRange(start, end string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Start = start
+		r.End = end
+	}
+}
+
+// ReadOrder returns the records in the order of their keys
+func ReadOrder(o Order) ReadOption {
+	return func(r *ReadOptions) {
+		r.Order = o
+	}
+}
+
+// ReadCursor continues a read after the key the cursor was made from. Unlike
+// an offset, the keys already read don't have to be scanned again.
+func ReadCursor(c string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Cursor = c
+	}
+}
+
 // WriteOptions configures an individual Write operation
 // If Expiry and TTL are set TTL takes precedence
 type WriteOptions struct {
 	Database, Table string
+	// IfNotExists only writes the record if the key doesn't exist
+	IfNotExists bool
+	// IfVersion only writes the record if the version of the existing record matches
+	IfVersion uint64
+	// IfValue only writes the record if the value of the existing record matches
+	IfValue []byte
 }
 
 // WriteOption sets values in WriteOptions
@@ -120,6 +159,53 @@ func WriteTo(database, table string) WriteOption {
 	}
 }
 
+// WriteIfNotExists only writes the record if the key doesn't exist, otherwise ErrExists is returned
+func WriteIfNotExists() WriteOption {
+	return func(w *WriteOptions) {
+		w.IfNotExists = true
+	}
+}
+
+// WriteIfVersion only writes the record if the existing record is at version v,
+// otherwise ErrVersionMismatch is returned
+func WriteIfVersion(v uint64) WriteOption {
+	return func(w *WriteOptions) {
+		w.IfVersion = v
+	}
+}
+
+// WriteIfValue only writes the record if the existing record has the value v,
+// otherwise ErrValueMismatch is returned
+func WriteIfValue(v []byte) WriteOption {
+	return func(w *WriteOptions) {
+		w.IfValue = v
+	}
+}
+
+// Conditional returns true if the write has any conditions
+func (w WriteOptions) Conditional() bool {
+	return w.IfNotExists || w.IfVersion > 0 || w.IfValue != nil
+}
+
+// Check returns an error if the existing record, which is nil if the key
+// doesn't exist, doesn't satisfy the conditions of the write. It's intended
+// for implementations of conditional writes.
+func (w WriteOptions) Check(existing *Record) error {
+	if w.IfNotExists && existing != nil {
+		return ErrExists
+	}
+	if (w.IfVersion > 0 || w.IfValue != nil) && existing == nil {
+		return ErrNotFound
+	}
+	if w.IfVersion > 0 && existing.Version != w.IfVersion {
+		return ErrVersionMismatch
+	}
+	if w.IfValue != nil && !bytes.Equal(existing.Value, w.IfValue) {
+		return ErrValueMismatch
+	}
+	return nil
+}
+
 // DeleteOptions configures an individual Delete operation
 type DeleteOptions struct {
 	Database, Table string
@@ -148,6 +234,14 @@ type ListOptions struct {
 	Limit uint
 	// Offset when combined with Limit supports pagination
 	Offset uint
+	// Start returns keys from start onwards
+	Start string
+	// End returns keys before end
+	End string
+	// Order the keys are returned in
+	Order Order
+	// Cursor continues a previous list after the key it was made from
+	Cursor string
 }
 
 // ListOption sets values in ListOptions
@@ -188,3 +282,27 @@ func ListOffset(o uint) ListOption {
 		l.Offset = o
 	}
 }
+
+// ListRange returns the keys from start up to but not including end.
+// Either may be empty to leave that side of the range open.
+func ListRange(start, end string) ListOption {
+	return func(l *ListOptions) {
+		l.Start = start
+		l.End = end
+	}
+}
+
+// ListOrder returns the keys in the order given
+func ListOrder(o Order) ListOption {
+	return func(l *ListOptions) {
+		l.Order = o
+	}
+}
+
+// ListCursor continues a list after the key the cursor was made from. Unlike
+// an offset, the keys already listed don't have to be scanned again.
+func ListCursor(c string) ListOption {
+	return func(l *ListOptions) {
+		l.Cursor = c
+	}
+}
@@ -10,6 +10,14 @@ import (
 var (
 	// ErrNotFound is returned when a key doesn't exist
 	ErrNotFound = errors.New("not found")
+	// ErrExists is returned by a write conditional on the key not existing when it does
+	ErrExists = errors.New("already exists")
+	// ErrVersionMismatch is returned by a write conditional on a version which isn't current
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrValueMismatch is returned by a write conditional on a value which isn't current
+	ErrValueMismatch = errors.New("value mismatch")
+	// ErrConditionNotSupported is returned by stores which can't make conditional writes
+	ErrConditionNotSupported = errors.New("conditional writes not supported")
 	// DefaultStore is the memory store.
 	DefaultStore Store = new(noopStore)
 )
@@ -44,4 +52,7 @@ type Record struct {
 	Metadata map[string]interface{} `json:"metadata"`
 	// Time to expire a record: TODO: change to timestamp
 	Expiry time.Duration `json:"expiry,omitempty"`
+	// Version of the record, incremented each time it's written. It's
+	// set by stores which support conditional writes, otherwise zero.
+	Version uint64 `json:"version,omitempty"`
 }
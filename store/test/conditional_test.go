@@ -0,0 +1,122 @@
+package test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/cache"
+	"github.com/micro/go-micro/v3/store/file"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func TestStoreConditionalWrites(t *testing.T) {
+	tcs := []struct {
+		name    string
+		s       store.Store
+		cleanup func(db string, s store.Store)
+	}{
+		{name: "file", s: file.NewStore(store.Database("conddb")), cleanup: fileStoreCleanup},
+		{name: "memory", s: memory.NewStore(store.Database("conddb")), cleanup: memoryCleanup},
+		{name: "cache", s: cache.NewStore(file.NewStore(store.Database("conddb"))), cleanup: fileStoreCleanup},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.cleanup("conddb", tc.s)
+			runConditionalTest(tc.s, t)
+		})
+	}
+
+	// stores which can't check conditions say so
+	for _, opt := range []store.WriteOption{store.WriteIfNotExists(), store.WriteIfVersion(1), store.WriteIfValue([]byte("a"))} {
+		if err := store.DefaultStore.Write(&store.Record{Key: "lease"}, opt); err != store.ErrConditionNotSupported {
+			t.Fatalf("Expected ErrConditionNotSupported, got %v", err)
+		}
+	}
+}
+
+func runConditionalTest(s store.Store, t *testing.T) {
+	// only if absent
+	if err := s.Write(&store.Record{Key: "lease", Value: []byte("a")}, store.WriteIfNotExists()); err != nil {
+		t.Fatalf("Unexpected error writing absent key: %v", err)
+	}
+	if err := s.Write(&store.Record{Key: "lease", Value: []byte("b")}, store.WriteIfNotExists()); err != store.ErrExists {
+		t.Fatalf("Expected ErrExists, got %v", err)
+	}
+
+	// only if the value matches
+	if err := s.Write(&store.Record{Key: "lease", Value: []byte("c")}, store.WriteIfValue([]byte("b"))); err != store.ErrValueMismatch {
+		t.Fatalf("Expected ErrValueMismatch, got %v", err)
+	}
+	if err := s.Write(&store.Record{Key: "lease", Value: []byte("c")}, store.WriteIfValue([]byte("a"))); err != nil {
+		t.Fatalf("Unexpected error writing matching value: %v", err)
+	}
+
+	// only if the version matches
+	recs, err := s.Read("lease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs[0].Version != 2 {
+		t.Fatalf("Expected version 2, got %v", recs[0].Version)
+	}
+	if err := s.Write(&store.Record{Key: "lease", Value: []byte("d")}, store.WriteIfVersion(1)); err != store.ErrVersionMismatch {
+		t.Fatalf("Expected ErrVersionMismatch, got %v", err)
+	}
+	if err := s.Write(&store.Record{Key: "missing"}, store.WriteIfVersion(1)); err != store.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	// a counter incremented concurrently with compare-and-swap
+	if err := s.Write(&store.Record{Key: "counter", Value: []byte("0")}); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				recs, err := s.Read("counter")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n, _ := strconv.Atoi(string(recs[0].Value))
+				err = s.Write(&store.Record{
+					Key:   "counter",
+					Value: []byte(strconv.Itoa(n + 1)),
+				}, store.WriteIfVersion(recs[0].Version))
+				if err == store.ErrVersionMismatch {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+		}()
+	}
+	wg.Wait()
+
+	if recs, err := s.Read("counter"); err != nil || string(recs[0].Value) != "5" {
+		t.Fatalf("Expected the counter to be 5, got %v %v", recs, err)
+	}
+
+	// a failed condition in a transaction applies nothing
+	tx, err := store.Begin(s)
+	if err == store.ErrTxNotSupported {
+		return
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	tx.Write(&store.Record{Key: "other", Value: []byte("x")})
+	tx.Write(&store.Record{Key: "lease", Value: []byte("x")}, store.WriteIfNotExists())
+	if err := tx.Commit(); err != store.ErrExists {
+		t.Fatalf("Expected ErrExists committing, got %v", err)
+	}
+	if _, err := s.Read("other"); err != store.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound after a failed commit, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/file"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func TestStoreRanges(t *testing.T) {
+	tcs := []struct {
+		name    string
+		s       store.Store
+		cleanup func(db string, s store.Store)
+	}{
+		{name: "file", s: file.NewStore(store.Database("rangedb")), cleanup: fileStoreCleanup},
+		{name: "memory", s: memory.NewStore(store.Database("rangedb")), cleanup: memoryCleanup},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.cleanup("rangedb", tc.s)
+			runRangeTest(tc.s, t)
+		})
+	}
+}
+
+func runRangeTest(s store.Store, t *testing.T) {
+	for i := 0; i < 10; i++ {
+		if err := s.Write(&store.Record{Key: fmt.Sprintf("a%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Write(&store.Record{Key: "b"})
+
+	tcs := []struct {
+		name     string
+		opts     []store.ListOption
+		expected []string
+	}{
+		{"range", []store.ListOption{store.ListRange("a3", "a6")}, []string{"a3", "a4", "a5"}},
+		{"open end", []store.ListOption{store.ListRange("a8", "")}, []string{"a8", "a9", "b"}},
+		{"open start", []store.ListOption{store.ListRange("", "a2")}, []string{"a0", "a1"}},
+		{"prefix", []store.ListOption{store.ListPrefix("a"), store.ListRange("a8", "")}, []string{"a8", "a9"}},
+		{"desc", []store.ListOption{store.ListRange("a3", "a6"), store.ListOrder(store.OrderDesc)}, []string{"a5", "a4", "a3"}},
+		{"desc prefix", []store.ListOption{store.ListPrefix("a"), store.ListOrder(store.OrderDesc), store.ListLimit(2)}, []string{"a9", "a8"}},
+		{"cursor", []store.ListOption{store.ListCursor(store.Cursor("a7"))}, []string{"a8", "a9", "b"}},
+		{"desc cursor", []store.ListOption{store.ListCursor(store.Cursor("a2")), store.ListOrder(store.OrderDesc)}, []string{"a1", "a0"}},
+		{"empty", []store.ListOption{store.ListRange("a6", "a3")}, nil},
+	}
+	for _, tc := range tcs {
+		keys, err := s.List(tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if len(keys) != len(tc.expected) || (len(keys) > 0 && !reflect.DeepEqual(keys, tc.expected)) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.expected, keys)
+		}
+	}
+
+	if _, err := s.List(store.ListCursor("!")); err != store.ErrInvalidCursor {
+		t.Fatalf("Expected ErrInvalidCursor, got %v", err)
+	}
+
+	// page through every record in both orders with a cursor
+	for _, order := range []store.Order{store.OrderAsc, store.OrderDesc} {
+		var keys []string
+		var cursor string
+		for {
+			recs, err := s.Read("a", store.ReadPrefix(), store.ReadOrder(order), store.ReadCursor(cursor), store.ReadLimit(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recs) == 0 {
+				break
+			}
+			for _, r := range recs {
+				keys = append(keys, r.Key)
+			}
+			cursor = store.Cursor(recs[len(recs)-1].Key)
+		}
+		if len(keys) != 10 {
+			t.Fatalf("Expected to page through 10 records in %v order, got %v", order, keys)
+		}
+		if (order == store.OrderAsc) != (keys[0] == "a0") {
+			t.Fatalf("Expected the records in %v order, got %v", order, keys)
+		}
+	}
+
+	// a transaction reads ranges including its own writes
+	tx, err := store.Begin(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tx.Write(&store.Record{Key: "a45"})
+	tx.Delete("a4")
+	recs, err := tx.Read("", store.ReadRange("a3", "a6"), store.ReadOrder(store.OrderDesc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, r := range recs {
+		keys = append(keys, r.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a5", "a45", "a3"}) {
+		t.Fatalf("Expected the range to include the transaction, got %v", keys)
+	}
+}
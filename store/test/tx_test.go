@@ -0,0 +1,140 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/cache"
+	"github.com/micro/go-micro/v3/store/file"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func TestStoreTransactions(t *testing.T) {
+	tcs := []struct {
+		name    string
+		s       store.Store
+		cleanup func(db string, s store.Store)
+	}{
+		{name: "file", s: file.NewStore(store.Database("txdb")), cleanup: fileStoreCleanup},
+		{name: "memory", s: memory.NewStore(store.Database("txdb")), cleanup: memoryCleanup},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.cleanup("txdb", tc.s)
+			runTxTest(tc.s, t)
+		})
+	}
+
+	if _, err := store.Begin(cache.NewStore(memory.NewStore())); err != store.ErrTxNotSupported {
+		t.Fatalf("Expected ErrTxNotSupported, got %v", err)
+	}
+}
+
+func runTxTest(s store.Store, t *testing.T) {
+	if err := s.Write(&store.Record{Key: "from", Value: []byte("value")}, store.WriteTo("", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// move the key between tables
+	tx, err := store.Begin(s)
+	if err != nil {
+		t.Fatalf("Unexpected error beginning transaction: %v", err)
+	}
+	recs, err := tx.Read("from", store.ReadFrom("", "a"))
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("Expected to read the record, got %v %v", recs, err)
+	}
+	if err := tx.Write(&store.Record{Key: "to", Value: recs[0].Value}, store.WriteTo("", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete("from", store.DeleteFrom("", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the transaction sees its own writes and deletes
+	if _, err := tx.Read("from", store.ReadFrom("", "a")); err != store.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound reading a deleted key, got %v", err)
+	}
+	if recs, err := tx.Read("t", store.ReadPrefix(), store.ReadFrom("", "b")); err != nil || len(recs) != 1 {
+		t.Fatalf("Expected to read the written record by prefix, got %v %v", recs, err)
+	}
+
+	// but nothing else does until it's committed
+	if _, err := s.Read("to", store.ReadFrom("", "b")); err != store.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound before commit, got %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Unexpected error committing: %v", err)
+	}
+	if err := tx.Commit(); err != store.ErrTxDone {
+		t.Fatalf("Expected ErrTxDone, got %v", err)
+	}
+
+	if _, err := s.Read("from", store.ReadFrom("", "a")); err != store.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound after commit, got %v", err)
+	}
+	if recs, err := s.Read("to", store.ReadFrom("", "b")); err != nil || string(recs[0].Value) != "value" {
+		t.Fatalf("Expected the moved record, got %v %v", recs, err)
+	}
+
+	// a rolled back transaction changes nothing
+	tx, err = store.Begin(s)
+	if err != nil {
+		t.Fatalf("Unexpected error beginning transaction: %v", err)
+	}
+	tx.Delete("to", store.DeleteFrom("", "b"))
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Unexpected error rolling back: %v", err)
+	}
+	if _, err := tx.Read("to", store.ReadFrom("", "b")); err != store.ErrTxDone {
+		t.Fatalf("Expected ErrTxDone, got %v", err)
+	}
+	if _, err := s.Read("to", store.ReadFrom("", "b")); err != nil {
+		t.Fatalf("Expected the record to remain after rollback, got %v", err)
+	}
+}
+
+func TestStoreConcurrentTables(t *testing.T) {
+	s := memory.NewStore(store.Database("txdb"))
+	defer memoryCleanup("txdb", s)
+
+	// transactions over the same tables in any order don't deadlock
+	// with each other or with writes to a single table
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			tx, _ := store.Begin(s)
+			tx.Write(&store.Record{Key: fmt.Sprintf("ab%d", i)}, store.WriteTo("", "a"))
+			tx.Write(&store.Record{Key: fmt.Sprintf("ab%d", i)}, store.WriteTo("", "b"))
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			tx, _ := store.Begin(s)
+			tx.Write(&store.Record{Key: fmt.Sprintf("ba%d", i)}, store.WriteTo("", "b"))
+			tx.Write(&store.Record{Key: fmt.Sprintf("ba%d", i)}, store.WriteTo("", "a"))
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.Write(&store.Record{Key: fmt.Sprintf("c%d", i)}, store.WriteTo("", "c")); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for table, n := range map[string]int{"a": 20, "b": 20, "c": 10} {
+		if keys, err := s.List(store.ListFrom("", table)); err != nil || len(keys) != n {
+			t.Fatalf("Expected %d keys in %v, got %v %v", n, table, len(keys), err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/cache"
+	"github.com/micro/go-micro/v3/store/file"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func TestStoreWatch(t *testing.T) {
+	tcs := []struct {
+		name    string
+		s       store.Store
+		cleanup func(db string, s store.Store)
+	}{
+		{name: "file", s: file.NewStore(store.Database("watchdb")), cleanup: fileStoreCleanup},
+		{name: "memory", s: memory.NewStore(store.Database("watchdb")), cleanup: memoryCleanup},
+		{name: "cache", s: cache.NewStore(file.NewStore(store.Database("watchdb"))), cleanup: fileStoreCleanup},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.cleanup("watchdb", tc.s)
+			runWatchTest(tc.s, t)
+		})
+	}
+}
+
+func runWatchTest(s store.Store, t *testing.T) {
+	w, err := store.Watch(s, "user/")
+	if err != nil {
+		t.Fatalf("Unexpected error watching: %v", err)
+	}
+	defer w.Stop()
+
+	s.Write(&store.Record{Key: "user/1", Value: []byte("a")})
+	s.Write(&store.Record{Key: "other", Value: []byte("b")})
+	s.Write(&store.Record{Key: "user/1", Value: []byte("c")}, store.WriteIfVersion(1))
+	s.Write(&store.Record{Key: "user/2"}, store.WriteTo("", "elsewhere"))
+	s.Delete("user/1")
+	s.Delete("user/3")
+
+	expected := []struct {
+		typ   store.EventType
+		value string
+	}{
+		{store.Create, "a"},
+		{store.Update, "c"},
+		{store.Delete, "c"},
+	}
+
+	var revision uint64
+	for _, e := range expected {
+		ev, err := next(w)
+		if err != nil {
+			t.Fatalf("Unexpected error waiting for %v: %v", e.typ, err)
+		}
+		if ev.Type != e.typ || ev.Record.Key != "user/1" || string(ev.Record.Value) != e.value {
+			t.Fatalf("Expected %v of user/1 to %v, got %v of %v to %v", e.typ, e.value, ev.Type, ev.Record.Key, string(ev.Record.Value))
+		}
+		if ev.Revision <= revision {
+			t.Fatalf("Expected the revision to increase from %v, got %v", revision, ev.Revision)
+		}
+		revision = ev.Revision
+	}
+
+	// a committed transaction is seen by the watcher, and
+	// nothing else was sent before it
+	tx, err := store.Begin(s)
+	if err == store.ErrTxNotSupported {
+		return
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	tx.Write(&store.Record{Key: "user/4"})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if ev, err := next(w); err != nil || ev.Type != store.Create || ev.Record.Key != "user/4" {
+		t.Fatalf("Expected create of user/4, got %v %v", ev, err)
+	}
+
+	w.Stop()
+	if _, err := w.Next(); err != store.ErrWatcherStopped {
+		t.Fatalf("Expected ErrWatcherStopped, got %v", err)
+	}
+}
+
+// next waits briefly for an event, stopping the watcher if there isn't one
+func next(w store.Watcher) (*store.Event, error) {
+	t := time.AfterFunc(time.Second, w.Stop)
+	defer t.Stop()
+	return w.Next()
+}
+
+func TestStoreWatchOverflow(t *testing.T) {
+	s := memory.NewStore(store.Database("watchdb"))
+	defer memoryCleanup("watchdb", s)
+
+	queue := store.WatchQueue
+	store.WatchQueue = 2
+	defer func() { store.WatchQueue = queue }()
+
+	w, err := store.Watch(s, "")
+	if err != nil {
+		t.Fatalf("Unexpected error watching: %v", err)
+	}
+	defer w.Stop()
+
+	// each table has its own revisions
+	s.Write(&store.Record{Key: "a"})
+	s.Write(&store.Record{Key: "a"}, store.WriteTo("", "elsewhere"))
+	s.Write(&store.Record{Key: "b"})
+	for _, revision := range []uint64{1, 2} {
+		if ev, err := next(w); err != nil || ev.Revision != revision {
+			t.Fatalf("Expected revision %v, got %v %v", revision, ev, err)
+		}
+	}
+
+	// a watcher which falls behind is stopped
+	for _, k := range []string{"c", "d", "e"} {
+		s.Write(&store.Record{Key: k})
+	}
+	if _, err := w.Next(); err != store.ErrWatcherOverflow {
+		t.Fatalf("Expected ErrWatcherOverflow, got %v", err)
+	}
+}
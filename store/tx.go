@@ -0,0 +1,287 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrTxNotSupported is returned by Begin when the store doesn't support transactions
+	ErrTxNotSupported = errors.New("transactions not supported")
+	// ErrTxDone is returned when a transaction is used after it was committed or rolled back
+	ErrTxDone = errors.New("transaction has already been committed or rolled back")
+)
+
+// Transactional is implemented by stores which can apply a batch of
+// writes and deletes atomically
+type Transactional interface {
+	// Begin a new transaction
+	Begin() (Tx, error)
+}
+
+// Tx is a batch of writes and deletes which are applied together on Commit.
+// Reads within the transaction see its own writes and deletes. Records are
+// not locked, so the last transaction to commit a key wins unless its writes
+// are conditional, in which case nothing is applied if a condition fails.
+type Tx interface {
+	// Read from the store, including the writes and deletes of the transaction
+	Read(key string, opts ...ReadOption) ([]*Record, error)
+	// Write a record when the transaction is committed
+	Write(r *Record, opts ...WriteOption) error
+	// Delete a record when the transaction is committed
+	Delete(key string, opts ...DeleteOption) error
+	// Commit applies the writes and deletes
+	Commit() error
+	// Rollback discards the writes and deletes
+	Rollback() error
+}
+
+// Op is a write or delete buffered by a transaction
+type Op struct {
+	// Database and Table the op applies to
+	Database, Table string
+	// Record to write. Only the key is set for a delete.
+	Record *Record
+	// Delete the record rather than write it
+	Delete bool
+	// Options of a write, including any conditions. Conditions are
+	// checked against the records as they were before the commit.
+	Options WriteOptions
+}
+
+// Begin a transaction on the store. ErrTxNotSupported is returned
+// if the store doesn't implement Transactional.
+func Begin(s Store) (Tx, error) {
+	t, ok := s.(Transactional)
+	if !ok {
+		return nil, ErrTxNotSupported
+	}
+	return t.Begin()
+}
+
+// NewTx returns a transaction which buffers writes and deletes in memory,
+// reading through to the store, and calls commit with the batch of ops.
+// It's intended for implementations of Transactional, whose commit must
+// apply the ops atomically.
+func NewTx(s Store, commit func([]Op) error) Tx {
+	return &bufferedTx{
+		store:  s,
+		commit: commit,
+	}
+}
+
+type bufferedTx struct {
+	sync.Mutex
+	store  Store
+	commit func([]Op) error
+	ops    []Op
+	done   bool
+}
+
+// resolve the database and table defaults of the store
+func (t *bufferedTx) resolve(database, table string) (string, string) {
+	opts := t.store.Options()
+	if len(database) == 0 {
+		database = opts.Database
+	}
+	if len(table) == 0 {
+		table = opts.Table
+	}
+	return database, table
+}
+
+func (t *bufferedTx) Read(key string, opts ...ReadOption) ([]*Record, error) {
+	var options ReadOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.done {
+		return nil, ErrTxDone
+	}
+
+	database, table := t.resolve(options.Database, options.Table)
+
+	// a single key is read from the latest op on it, if any
+	if !options.Scan() {
+		for i := len(t.ops) - 1; i >= 0; i-- {
+			op := t.ops[i]
+			if op.Database != database || op.Table != table || op.Record.Key != key {
+				continue
+			}
+			if op.Delete {
+				return nil, ErrNotFound
+			}
+			return []*Record{copyRecord(op.Record)}, nil
+		}
+		return t.store.Read(key, ReadFrom(database, table))
+	}
+
+	r, err := options.Range(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// read every match from the store and overlay the ops before
+	// applying the offset and limit
+	readOpts := []ReadOption{
+		ReadFrom(database, table),
+		ReadRange(options.Start, options.End),
+		ReadOrder(options.Order),
+		ReadCursor(options.Cursor),
+	}
+	if options.Prefix {
+		readOpts = append(readOpts, ReadPrefix())
+	}
+	if options.Suffix {
+		readOpts = append(readOpts, ReadSuffix())
+	}
+	recs, err := t.store.Read(key, readOpts...)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	matches := make(map[string]*Record, len(recs))
+	for _, r := range recs {
+		matches[r.Key] = r
+	}
+
+	for _, op := range t.ops {
+		if op.Database != database || op.Table != table {
+			continue
+		}
+		k := op.Record.Key
+		if !r.Contains(k) {
+			continue
+		}
+		if options.Suffix && !strings.HasSuffix(k, key) {
+			continue
+		}
+		if op.Delete {
+			delete(matches, k)
+		} else {
+			matches[k] = copyRecord(op.Record)
+		}
+	}
+
+	keys := make([]string, 0, len(matches))
+	for k := range matches {
+		keys = append(keys, k)
+	}
+	if r.Order == OrderDesc {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	} else {
+		sort.Strings(keys)
+	}
+
+	if options.Offset >= uint(len(keys)) {
+		return nil, nil
+	}
+	keys = keys[options.Offset:]
+	if options.Limit > 0 && options.Limit < uint(len(keys)) {
+		keys = keys[:options.Limit]
+	}
+
+	results := make([]*Record, 0, len(keys))
+	for _, k := range keys {
+		results = append(results, matches[k])
+	}
+	return results, nil
+}
+
+func (t *bufferedTx) Write(r *Record, opts ...WriteOption) error {
+	var options WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.done {
+		return ErrTxDone
+	}
+
+	database, table := t.resolve(options.Database, options.Table)
+	options.Database, options.Table = database, table
+	t.ops = append(t.ops, Op{
+		Database: database,
+		Table:    table,
+		Record:   copyRecord(r),
+		Options:  options,
+	})
+	return nil
+}
+
+func (t *bufferedTx) Delete(key string, opts ...DeleteOption) error {
+	var options DeleteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.done {
+		return ErrTxDone
+	}
+
+	database, table := t.resolve(options.Database, options.Table)
+	t.ops = append(t.ops, Op{
+		Database: database,
+		Table:    table,
+		Record:   &Record{Key: key},
+		Delete:   true,
+	})
+	return nil
+}
+
+func (t *bufferedTx) Commit() error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+
+	if len(t.ops) == 0 {
+		return nil
+	}
+	return t.commit(t.ops)
+}
+
+func (t *bufferedTx) Rollback() error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	t.ops = nil
+	return nil
+}
+
+// copyRecord so the caller can't mutate a buffered record
+func copyRecord(r *Record) *Record {
+	c := &Record{
+		Key:     r.Key,
+		Value:   make([]byte, len(r.Value)),
+		Expiry:  r.Expiry,
+		Version: r.Version,
+	}
+	copy(c.Value, r.Value)
+	if r.Metadata != nil {
+		c.Metadata = make(map[string]interface{}, len(r.Metadata))
+		for k, v := range r.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return c
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrWatchNotSupported is returned by Watch when the store can't be watched
+	ErrWatchNotSupported = errors.New("watch not supported")
+	// ErrWatcherStopped is returned by Next when the watcher has been stopped
+	ErrWatcherStopped = errors.New("watcher stopped")
+	// ErrWatcherOverflow is returned by Next when the watcher fell behind by
+	// more than WatchQueue events, and was stopped
+	ErrWatcherOverflow = errors.New("watcher overflow")
+
+	// WatchQueue is the maximum number of events queued for a watcher
+	// which isn't calling Next
+	WatchQueue = 1024
+)
+
+// Watchable is implemented by stores which can stream changes to their records
+type Watchable interface {
+	// Watch the keys with the given prefix, or every key if it's empty
+	Watch(prefix string, opts ...WatchOption) (Watcher, error)
+}
+
+// Watcher streams the changes to a store
+type Watcher interface {
+	// Next blocks until there's an event or the watcher is stopped
+	Next() (*Event, error)
+	// Stop watching
+	Stop()
+}
+
+// EventType is the type of change to a record
+type EventType int
+
+const (
+	// Create is emitted when a key is written which didn't exist
+	Create EventType = iota
+	// Update is emitted when an existing key is written
+	Update
+	// Delete is emitted when an existing key is deleted
+	Delete
+)
+
+// String returns human readable event type
+func (t EventType) String() string {
+	switch t {
+	case Create:
+		return "create"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a record. Records which expire don't emit events.
+type Event struct {
+	// Type of change
+	Type EventType
+	// Database and Table of the record
+	Database, Table string
+	// Record as written, or as it was before it was deleted
+	Record *Record
+	// Revision after the change. It increases with every change to the
+	// table, so the events of a table can be ordered and compared.
+	Revision uint64
+}
+
+// WatchOptions configures a Watch
+type WatchOptions struct {
+	Database, Table string
+}
+
+// WatchOption sets values in WatchOptions
+type WatchOption func(w *WatchOptions)
+
+// WatchFrom the database and table
+func WatchFrom(database, table string) WatchOption {
+	return func(w *WatchOptions) {
+		w.Database = database
+		w.Table = table
+	}
+}
+
+// Watch the store. ErrWatchNotSupported is returned if the
+// store doesn't implement Watchable.
+func Watch(s Store, prefix string, opts ...WatchOption) (Watcher, error) {
+	w, ok := s.(Watchable)
+	if !ok {
+		return nil, ErrWatchNotSupported
+	}
+	return w.Watch(prefix, opts...)
+}
+
+// Feed sends events to the watchers of a store. Sending never blocks;
+// events are queued for each watcher until it calls Next, and a watcher
+// which falls behind by more than WatchQueue events is stopped. It's
+// intended for implementations of Watchable. The zero value is ready to use.
+type Feed struct {
+	sync.RWMutex
+	watchers map[*feedWatcher]bool
+}
+
+// Watch returns a watcher of the keys in the database and table with the prefix
+func (f *Feed) Watch(database, table, prefix string) Watcher {
+	w := &feedWatcher{
+		feed:     f,
+		database: database,
+		table:    table,
+		prefix:   prefix,
+		notify:   make(chan bool, 1),
+		exit:     make(chan bool),
+	}
+
+	f.Lock()
+	if f.watchers == nil {
+		f.watchers = make(map[*feedWatcher]bool)
+	}
+	f.watchers[w] = true
+	f.Unlock()
+
+	return w
+}
+
+// Send the event to the watchers it matches
+func (f *Feed) Send(ev *Event) {
+	var overflowed []*feedWatcher
+
+	f.RLock()
+	for w := range f.watchers {
+		if w.database != ev.Database || w.table != ev.Table {
+			continue
+		}
+		if !strings.HasPrefix(ev.Record.Key, w.prefix) {
+			continue
+		}
+		if !w.push(ev) {
+			overflowed = append(overflowed, w)
+		}
+	}
+	f.RUnlock()
+
+	if len(overflowed) == 0 {
+		return
+	}
+	f.Lock()
+	for _, w := range overflowed {
+		delete(f.watchers, w)
+	}
+	f.Unlock()
+}
+
+type feedWatcher struct {
+	feed                    *Feed
+	database, table, prefix string
+
+	sync.Mutex
+	queue []*Event
+	// whether the queue overflowed, stopping the watcher
+	overflow bool
+	notify   chan bool
+	exit     chan bool
+}
+
+// push the event onto the queue, returning false if the queue is full
+func (w *feedWatcher) push(ev *Event) bool {
+	// each watcher gets its own copy of the record
+	c := *ev
+	c.Record = copyRecord(ev.Record)
+
+	w.Lock()
+	if w.overflow {
+		w.Unlock()
+		return false
+	}
+	if len(w.queue) >= WatchQueue {
+		w.overflow = true
+		w.queue = nil
+	} else {
+		w.queue = append(w.queue, &c)
+	}
+	ok := !w.overflow
+	w.Unlock()
+
+	select {
+	case w.notify <- true:
+	default:
+	}
+	return ok
+}
+
+func (w *feedWatcher) Next() (*Event, error) {
+	for {
+		select {
+		case <-w.exit:
+			return nil, ErrWatcherStopped
+		default:
+		}
+
+		w.Lock()
+		if w.overflow {
+			w.Unlock()
+			return nil, ErrWatcherOverflow
+		}
+		if len(w.queue) > 0 {
+			ev := w.queue[0]
+			w.queue[0] = nil
+			w.queue = w.queue[1:]
+			w.Unlock()
+			return ev, nil
+		}
+		w.Unlock()
+
+		select {
+		case <-w.notify:
+		case <-w.exit:
+			return nil, ErrWatcherStopped
+		}
+	}
+}
+
+func (w *feedWatcher) Stop() {
+	w.feed.Lock()
+	delete(w.feed.watchers, w)
+	w.feed.Unlock()
+
+	w.Lock()
+	defer w.Unlock()
+
+	select {
+	case <-w.exit:
+	default:
+		close(w.exit)
+		w.queue = nil
+	}
+}
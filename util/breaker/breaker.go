@@ -0,0 +1,171 @@
+// Package breaker stops calls to the endpoints of services which keep failing.
+// Each endpoint has a circuit which opens after a number of failures in a row,
+// failing calls immediately, and is half open once a timeout has passed. A
+// trial call made while it's half open closes the circuit if it succeeds.
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metrics"
+)
+
+var (
+	// Code of the error returned while a circuit is open. It's distinct from
+	// the codes returned by services so callers can fall back, and a client
+	// error so the call isn't retried or counted against the routes selected.
+	Code int32 = 499
+
+	// OpenedMetric counts the circuits opened, tagged by service and endpoint
+	OpenedMetric = "client.breaker.opened"
+)
+
+type state int
+
+const (
+	closed state = iota
+	open
+	halfOpen
+)
+
+type key struct {
+	service, endpoint string
+}
+
+type circuit struct {
+	state state
+	// failures in a row while closed
+	failures int
+	// when the circuit was opened
+	opened time.Time
+	// trial calls in flight while half open
+	trials int
+}
+
+type breaker struct {
+	opts Options
+
+	sync.Mutex
+	circuits map[key]*circuit
+}
+
+// NewCallWrapper returns a call wrapper with a circuit breaker for each endpoint
+// of the services called. While a circuit is open calls fail immediately with
+// an error with the breaker Code.
+func NewCallWrapper(opts ...Option) client.CallWrapper {
+	b := &breaker{
+		opts:     newOptions(opts...),
+		circuits: make(map[key]*circuit),
+	}
+
+	return func(fn client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			options := callOptions(b.opts, opts)
+			if options.Failures <= 0 {
+				return fn(ctx, addr, req, rsp, opts)
+			}
+
+			k := key{req.Service(), req.Endpoint()}
+			admitted, ok := b.allow(k, options)
+			if !ok {
+				return errors.New("go.micro.client", "circuit open for "+req.Service()+" "+req.Endpoint(), Code)
+			}
+
+			err := fn(ctx, addr, req, rsp, opts)
+			if ctx.Err() == context.Canceled {
+				// the call was abandoned, such as the slower of hedged calls
+				b.done(k, admitted, options, context.Canceled)
+			} else {
+				b.done(k, admitted, options, err)
+			}
+			return err
+		}
+	}
+}
+
+// allow reports whether a call can be made, and the state it was made in
+func (b *breaker) allow(k key, opts Options) (state, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.circuits[k]
+	if !ok {
+		c = &circuit{}
+		b.circuits[k] = c
+	}
+
+	switch c.state {
+	case open:
+		if time.Since(c.opened) < opts.Timeout {
+			return open, false
+		}
+		c.state = halfOpen
+		c.trials = 0
+		fallthrough
+	case halfOpen:
+		if c.trials >= opts.HalfOpen {
+			return halfOpen, false
+		}
+		c.trials++
+		return halfOpen, true
+	default:
+		return closed, true
+	}
+}
+
+// done records the result of a call made in the admitted state. The results of
+// calls made before the state changed are ignored, as are those of calls which
+// were abandoned, which are done with context.Canceled.
+func (b *breaker) done(k key, admitted state, opts Options, err error) {
+	failed := opts.Failure(err)
+
+	b.Lock()
+	defer b.Unlock()
+
+	c := b.circuits[k]
+	if c.state != admitted {
+		return
+	}
+
+	if err == context.Canceled {
+		// let another trial call be made
+		if c.state == halfOpen {
+			c.trials--
+		}
+		return
+	}
+
+	switch c.state {
+	case closed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= opts.Failures {
+			b.open(k, c, opts)
+		}
+	case halfOpen:
+		c.trials--
+		if failed {
+			b.open(k, c, opts)
+			return
+		}
+		c.state = closed
+		c.failures = 0
+	}
+}
+
+func (b *breaker) open(k key, c *circuit, opts Options) {
+	c.state = open
+	c.opened = time.Now()
+	c.failures = 0
+
+	if opts.Reporter != nil {
+		opts.Reporter.Count(OpenedMetric, 1, metrics.Tags{"service": k.service, "endpoint": k.endpoint})
+	}
+}
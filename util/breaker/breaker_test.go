@@ -0,0 +1,167 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/errors"
+)
+
+var errFailed = errors.InternalServerError("test.service", "failed")
+
+type testRequest struct {
+	client.Request
+	service, endpoint string
+}
+
+func (r *testRequest) Service() string {
+	return r.service
+}
+
+func (r *testRequest) Endpoint() string {
+	return r.endpoint
+}
+
+// testCall returns a call func which returns the error set, counting the calls
+func testCall(calls *int, err *error) client.CallFunc {
+	return func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+		*calls++
+		return *err
+	}
+}
+
+func call(fn client.CallFunc, endpoint string, opts ...client.CallOption) error {
+	var options client.CallOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	req := &testRequest{service: "test.service", endpoint: endpoint}
+	return fn(context.Background(), "127.0.0.1:8000", req, nil, options)
+}
+
+func isOpen(err error) bool {
+	return err != nil && errors.FromError(err).Code == Code
+}
+
+func TestBreaker(t *testing.T) {
+	var calls int
+	var err error
+	fn := NewCallWrapper(Failures(3), Timeout(time.Millisecond*50))(testCall(&calls, &err))
+
+	// errors which aren't failures don't open the circuit
+	err = errors.BadRequest("test.service", "bad request")
+	for i := 0; i < 5; i++ {
+		if e := call(fn, "Test.Call"); isOpen(e) {
+			t.Fatal("Expected the circuit to be closed after bad requests")
+		}
+	}
+
+	// a success resets the failures
+	err = errFailed
+	call(fn, "Test.Call")
+	call(fn, "Test.Call")
+	err = nil
+	call(fn, "Test.Call")
+	err = errFailed
+	call(fn, "Test.Call")
+	call(fn, "Test.Call")
+	if e := call(fn, "Test.Call"); isOpen(e) {
+		t.Fatal("Expected the circuit to be closed until there are failures in a row")
+	}
+
+	// the circuit is open, calls fail without being made
+	calls = 0
+	if e := call(fn, "Test.Call"); !isOpen(e) {
+		t.Fatalf("Expected the circuit to be open, got %v", e)
+	}
+	if calls != 0 {
+		t.Fatal("Expected no calls to be made while the circuit is open")
+	}
+
+	// other endpoints have their own circuit
+	if e := call(fn, "Test.Other"); isOpen(e) {
+		t.Fatal("Expected the circuit of another endpoint to be closed")
+	}
+
+	// the call can be made regardless of the circuit
+	if e := call(fn, "Test.Call", CallOptions(Failures(0))); isOpen(e) {
+		t.Fatal("Expected the breaker to be disabled by the call options")
+	}
+
+	// calls abandoned by the client don't count, and let another trial be made
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := &testRequest{service: "test.service", endpoint: "Test.Abandoned"}
+	for i := 0; i < 5; i++ {
+		if e := fn(ctx, "127.0.0.1:8000", req, nil, client.CallOptions{}); isOpen(e) {
+			t.Fatal("Expected the circuit to be closed after abandoned calls")
+		}
+	}
+
+	// a failed trial opens the circuit again
+	time.Sleep(time.Millisecond * 60)
+	calls = 0
+	if e := call(fn, "Test.Call"); isOpen(e) || calls != 1 {
+		t.Fatal("Expected a trial call once the timeout has passed")
+	}
+	if e := call(fn, "Test.Call"); !isOpen(e) {
+		t.Fatal("Expected the circuit to open after a failed trial")
+	}
+
+	// a successful trial closes it
+	time.Sleep(time.Millisecond * 60)
+	err = nil
+	if e := call(fn, "Test.Call"); e != nil {
+		t.Fatal(e)
+	}
+	err = errFailed
+	if e := call(fn, "Test.Call"); isOpen(e) {
+		t.Fatal("Expected the circuit to close after a successful trial")
+	}
+}
+
+func TestHalfOpen(t *testing.T) {
+	var calls int
+	err := error(errFailed)
+
+	// the trial call blocks until released
+	release := make(chan bool)
+	started := make(chan bool)
+	var trial bool
+	fn := NewCallWrapper(Failures(1), Timeout(time.Millisecond*10))(
+		func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			if trial {
+				started <- true
+				<-release
+				return nil
+			}
+			return testCall(&calls, &err)(ctx, addr, req, rsp, opts)
+		},
+	)
+
+	call(fn, "Test.Call")
+	time.Sleep(time.Millisecond * 20)
+
+	trial = true
+	done := make(chan error)
+	go func() {
+		done <- call(fn, "Test.Call")
+	}()
+	<-started
+
+	// only one trial call is made at once
+	if e := call(fn, "Test.Call"); !isOpen(e) {
+		t.Fatalf("Expected calls to fail during the trial, got %v", e)
+	}
+
+	close(release)
+	if e := <-done; e != nil {
+		t.Fatal(e)
+	}
+	trial = false
+	if e := call(fn, "Test.Call"); isOpen(e) {
+		t.Fatalf("Expected the circuit to be closed, got %v", e)
+	}
+}
@@ -0,0 +1,107 @@
+package breaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metrics"
+)
+
+// Options for the circuit breaker
+type Options struct {
+	// Failures in a row which open a circuit, zero to disable the breaker
+	Failures int
+	// Timeout is how long a circuit stays open before trial calls are made
+	Timeout time.Duration
+	// HalfOpen is the number of trial calls made at once. The circuit closes
+	// when one succeeds and opens again when one fails.
+	HalfOpen int
+	// Failure reports whether the error of a call counts against the circuit
+	Failure func(error) bool
+	// Reporter the circuits opened are reported to
+	Reporter metrics.Reporter
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+type callOptionsKey struct{}
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Failures: 5,
+		Timeout:  time.Second * 10,
+		HalfOpen: 1,
+		Failure:  errors.IsFailure,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// Failures sets the number of failures in a row which open a circuit
+func Failures(n int) Option {
+	return func(o *Options) {
+		o.Failures = n
+	}
+}
+
+// Timeout sets how long a circuit stays open before trial calls are made
+func Timeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// HalfOpen sets the number of trial calls made at once when a circuit is half open
+func HalfOpen(n int) Option {
+	return func(o *Options) {
+		o.HalfOpen = n
+	}
+}
+
+// Failure sets the func which decides whether an error counts against a circuit
+func Failure(fn func(error) bool) Option {
+	return func(o *Options) {
+		o.Failure = fn
+	}
+}
+
+// Reporter sets the reporter the circuits opened are reported to
+func Reporter(r metrics.Reporter) Option {
+	return func(o *Options) {
+		o.Reporter = r
+	}
+}
+
+// CallOptions overrides the options of the breaker for a call,
+// e.g. Failures(0) to make the call regardless of the circuit
+func CallOptions(opts ...Option) client.CallOption {
+	return func(o *client.CallOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		if prev, ok := o.Context.Value(callOptionsKey{}).([]Option); ok {
+			opts = append(append([]Option{}, prev...), opts...)
+		}
+		o.Context = context.WithValue(o.Context, callOptionsKey{}, opts)
+	}
+}
+
+// callOptions returns the options for a call
+func callOptions(options Options, opts client.CallOptions) Options {
+	if opts.Context == nil {
+		return options
+	}
+	o, ok := opts.Context.Value(callOptionsKey{}).([]Option)
+	if !ok {
+		return options
+	}
+	for _, fn := range o {
+		fn(&options)
+	}
+	return options
+}
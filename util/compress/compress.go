@@ -0,0 +1,117 @@
+// Package compress compresses the bodies of messages, with the encoding
+// negotiated through their headers in the style of http
+package compress
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// AcceptKey is the header listing the encodings a peer accepts
+	AcceptKey = "Accept-Encoding"
+	// EncodingKey is the header set to the encoding of a compressed body
+	EncodingKey = "Content-Encoding"
+)
+
+var (
+	// ErrUnsupported is returned when decoding a body with an unknown encoding
+	ErrUnsupported = errors.New("unsupported content encoding")
+	// ErrTooLarge is returned when a body decompresses to more than MaxSize
+	ErrTooLarge = errors.New("decompressed body too large")
+
+	// DefaultEncodings are negotiated in order of preference
+	DefaultEncodings = []string{"zstd", "snappy", "gzip"}
+	// DefaultThreshold is the minimum size of body worth compressing
+	DefaultThreshold = 1024
+	// MaxSize is the largest a body can decompress to, so a small body from a
+	// peer can't exhaust memory. It's read when zstd is first used.
+	MaxSize = 64 << 20
+
+	// Compressors by the name of their encoding
+	Compressors = map[string]Compressor{
+		"gzip":   new(gzipCompressor),
+		"snappy": new(snappyCompressor),
+		"zstd":   new(zstdCompressor),
+	}
+)
+
+// Compressor compresses and decompresses message bodies
+type Compressor interface {
+	Compress([]byte) ([]byte, error)
+	Decompress([]byte) ([]byte, error)
+	String() string
+}
+
+// Accept returns the value of the accept header for the encodings given,
+// leaving out any without a compressor
+func Accept(encodings []string) string {
+	var supported []string
+	for _, e := range encodings {
+		if _, ok := Compressors[e]; ok {
+			supported = append(supported, e)
+		}
+	}
+	return strings.Join(supported, ", ")
+}
+
+// Negotiate returns the first of the encodings given which the accept header
+// includes, or an empty string if there are none
+func Negotiate(accept string, encodings []string) string {
+	if len(accept) == 0 {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	for _, e := range strings.Split(accept, ",") {
+		// ignore any quality value
+		e = strings.TrimSpace(strings.Split(e, ";")[0])
+		accepted[e] = true
+	}
+	for _, e := range encodings {
+		if _, ok := Compressors[e]; ok && accepted[e] {
+			return e
+		}
+	}
+	return ""
+}
+
+// Encode compresses the body with the encoding, if it's at least the threshold
+// in size, and records the encoding in the header. Bodies which aren't
+// compressed are returned as they are.
+func Encode(header map[string]string, body []byte, encoding string, threshold int) ([]byte, error) {
+	delete(header, EncodingKey)
+
+	c, ok := Compressors[encoding]
+	if !ok || len(body) == 0 || len(body) < threshold {
+		return body, nil
+	}
+
+	b, err := c.Compress(body)
+	if err != nil {
+		return nil, err
+	}
+	header[EncodingKey] = encoding
+	return b, nil
+}
+
+// Decode decompresses the body with the encoding recorded in the header,
+// removing it from the header. Bodies without an encoding are returned as
+// they are.
+func Decode(header map[string]string, body []byte) ([]byte, error) {
+	encoding, ok := header[EncodingKey]
+	if !ok {
+		return body, nil
+	}
+
+	c, ok := Compressors[encoding]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	b, err := c.Decompress(body)
+	if err != nil {
+		return nil, err
+	}
+	delete(header, EncodingKey)
+	return b, nil
+}
@@ -0,0 +1,106 @@
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	body := bytes.Repeat([]byte("hello world "), 1024)
+
+	for name := range Compressors {
+		t.Run(name, func(t *testing.T) {
+			hdr := map[string]string{}
+			b, err := Encode(hdr, body, name, DefaultThreshold)
+			if err != nil {
+				t.Fatalf("Unexpected error encoding: %v", err)
+			}
+			if hdr[EncodingKey] != name {
+				t.Fatalf("Expected encoding %v, got %v", name, hdr[EncodingKey])
+			}
+			if len(b) >= len(body) {
+				t.Fatalf("Expected body to be compressed, got %v bytes from %v", len(b), len(body))
+			}
+
+			b, err = Decode(hdr, b)
+			if err != nil {
+				t.Fatalf("Unexpected error decoding: %v", err)
+			}
+			if !bytes.Equal(b, body) {
+				t.Fatal("Decoded body doesn't match")
+			}
+			if _, ok := hdr[EncodingKey]; ok {
+				t.Fatal("Expected encoding to be removed from the header")
+			}
+		})
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	hdr := map[string]string{EncodingKey: "gzip"}
+	b, err := Encode(hdr, []byte("small"), "gzip", DefaultThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "small" {
+		t.Fatalf("Expected body under the threshold to be unchanged, got %q", b)
+	}
+	if _, ok := hdr[EncodingKey]; ok {
+		t.Fatal("Expected stale encoding to be removed from the header")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tt := []struct {
+		accept    string
+		encodings []string
+		expect    string
+	}{
+		{"", DefaultEncodings, ""},
+		{"gzip", DefaultEncodings, "gzip"},
+		{"gzip, zstd", DefaultEncodings, "zstd"},
+		{"gzip;q=1.0, snappy;q=0.5", DefaultEncodings, "snappy"},
+		{"br, deflate", DefaultEncodings, ""},
+		{"zstd, snappy, gzip", nil, ""},
+	}
+
+	for _, tc := range tt {
+		if got := Negotiate(tc.accept, tc.encodings); got != tc.expect {
+			t.Errorf("Negotiate(%q, %v) expected %q, got %q", tc.accept, tc.encodings, tc.expect, got)
+		}
+	}
+
+	if got := Accept([]string{"zstd", "br", "gzip"}); got != "zstd, gzip" {
+		t.Errorf("Expected unsupported encodings to be left out, got %q", got)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	if _, err := Decode(map[string]string{EncodingKey: "br"}, []byte("data")); err != ErrUnsupported {
+		t.Fatalf("Expected %v, got %v", ErrUnsupported, err)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	defer func(n int) { MaxSize = n }(MaxSize)
+	MaxSize = 1 << 16
+
+	compressors := []Compressor{
+		Compressors["gzip"],
+		Compressors["snappy"],
+		// zstd reads the max size when it's first used
+		new(zstdCompressor),
+	}
+
+	for _, c := range compressors {
+		for size, expect := range map[int]error{MaxSize: nil, MaxSize + 1: ErrTooLarge} {
+			b, err := c.Compress(bytes.Repeat([]byte("a"), size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Decompress(b); err != expect {
+				t.Fatalf("Expected %v decompressing %v bytes with %v, got %v", expect, size, c, err)
+			}
+		}
+	}
+}
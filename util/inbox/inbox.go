@@ -0,0 +1,109 @@
+// Package inbox deduplicates the messages received by subscribers
+package inbox
+
+import (
+	"context"
+	"errors"
+
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/metrics"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// ErrInProgress is returned for a message which is being processed by another delivery
+	ErrInProgress = errors.New("message is being processed")
+
+	// DuplicatesMetric is the id of the count of duplicate messages, tagged by topic
+	DuplicatesMetric = "service.subscriber.duplicates"
+	// InProgressMetric is the id of the count of messages received while another delivery of
+	// them was being processed, tagged by topic
+	InProgressMetric = "service.subscriber.in_progress"
+
+	// values of the records of messages being processed and those processed
+	processing = []byte("processing")
+	processed  = []byte("processed")
+)
+
+// NewWrapper returns a subscriber wrapper which records the ids of the messages
+// it has processed and skips any it receives again. A message is claimed before
+// it's processed, so concurrent deliveries of it aren't processed twice, and the
+// claim is released if the subscriber returns an error so it can be retried.
+// Messages without an id are always processed.
+func NewWrapper(opts ...Option) server.SubscriberWrapper {
+	options := newOptions(opts...)
+
+	return func(fn server.SubscriberFunc) server.SubscriberFunc {
+		return func(ctx context.Context, msg server.Message) error {
+			id := messageId(msg, options.Headers)
+			if len(id) == 0 {
+				return fn(ctx, msg)
+			}
+
+			st := options.store()
+			key := msg.Topic() + "/" + id
+			table := store.WriteTo(options.Database, options.Table)
+
+			// claim the message
+			err := st.Write(&store.Record{Key: key, Value: processing, Expiry: options.Lease}, table, store.WriteIfNotExists())
+			switch err {
+			case nil:
+			case store.ErrExists:
+				return duplicate(options, msg, key)
+			case store.ErrConditionNotSupported:
+				// the store can't claim the message, so only skip those already processed
+				if recs, err := st.Read(key, store.ReadFrom(options.Database, options.Table)); err == nil && len(recs) > 0 {
+					return duplicate(options, msg, key)
+				}
+			default:
+				return err
+			}
+
+			if err := fn(ctx, msg); err != nil {
+				// release the claim so a redelivery is processed
+				if err := st.Delete(key, store.DeleteFrom(options.Database, options.Table)); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("Error releasing message %v on %v: %v", id, msg.Topic(), err)
+				}
+				return err
+			}
+
+			return st.Write(&store.Record{Key: key, Value: processed, Expiry: options.TTL}, table)
+		}
+	}
+}
+
+// messageId returns the first id set in the headers of the message
+func messageId(msg server.Message, headers []string) string {
+	for _, h := range headers {
+		if id := msg.Header()[h]; len(id) > 0 {
+			return id
+		}
+	}
+	return ""
+}
+
+// duplicate handles a message which was received again. It's skipped and counted as a
+// duplicate if it was processed, otherwise ErrInProgress is returned so it's redelivered later.
+func duplicate(options Options, msg server.Message, key string) error {
+	recs, err := options.store().Read(key, store.ReadFrom(options.Database, options.Table))
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	// the claim is held by another delivery, or was released or expired since
+	if len(recs) == 0 || string(recs[0].Value) != string(processed) {
+		report(options, InProgressMetric, msg)
+		return ErrInProgress
+	}
+
+	report(options, DuplicatesMetric, msg)
+	return nil
+}
+
+// report a message to the count with the id given
+func report(options Options, id string, msg server.Message) {
+	if options.Reporter != nil {
+		options.Reporter.Count(id, 1, metrics.Tags{"topic": msg.Topic()})
+	}
+}
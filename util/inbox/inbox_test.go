@@ -0,0 +1,151 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/metrics"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/store/memory"
+	"github.com/micro/go-micro/v3/util/outbox"
+)
+
+type testMessage struct {
+	topic  string
+	header map[string]string
+}
+
+func (m *testMessage) Topic() string             { return m.topic }
+func (m *testMessage) Payload() interface{}      { return nil }
+func (m *testMessage) ContentType() string       { return "application/json" }
+func (m *testMessage) Header() map[string]string { return m.header }
+func (m *testMessage) Body() []byte              { return nil }
+func (m *testMessage) Codec() codec.Reader       { return nil }
+
+// testReporter sums the counts reported for each topic
+type testReporter struct {
+	metrics.Reporter
+	counts map[string]int64
+}
+
+func (r *testReporter) Count(id string, value int64, tags metrics.Tags) error {
+	r.counts[id+"/"+tags["topic"]] += value
+	return nil
+}
+
+func newMessage(topic, id string) server.Message {
+	return &testMessage{topic: topic, header: map[string]string{outbox.IdKey: id}}
+}
+
+func TestInbox(t *testing.T) {
+	reporter := &testReporter{counts: map[string]int64{}}
+	wrapper := NewWrapper(Store(memory.NewStore()), Reporter(reporter))
+
+	var processed int
+	fail := errors.New("failed")
+	fn := wrapper(func(ctx context.Context, msg server.Message) error {
+		processed++
+		if msg.Header()["fail"] == "true" {
+			return fail
+		}
+		return nil
+	})
+
+	// the same message is processed once
+	for i := 0; i < 3; i++ {
+		if err := fn(context.TODO(), newMessage("foo", "1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if processed != 1 {
+		t.Fatalf("Expected the message to be processed once, got %v", processed)
+	}
+	if c := reporter.counts[DuplicatesMetric+"/foo"]; c != 2 {
+		t.Fatalf("Expected 2 duplicates on foo, got %v", c)
+	}
+
+	// the id is scoped to the topic
+	if err := fn(context.TODO(), newMessage("bar", "1")); err != nil {
+		t.Fatal(err)
+	}
+	if processed != 2 {
+		t.Fatalf("Expected the message on another topic to be processed, got %v", processed)
+	}
+
+	// a message which failed is processed again
+	msg := newMessage("foo", "2")
+	msg.Header()["fail"] = "true"
+	if err := fn(context.TODO(), msg); err != fail {
+		t.Fatalf("Expected the subscriber error, got %v", err)
+	}
+	delete(msg.Header(), "fail")
+	if err := fn(context.TODO(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if processed != 4 {
+		t.Fatalf("Expected the failed message to be processed again, got %v", processed)
+	}
+
+	// messages without an id are always processed
+	for i := 0; i < 2; i++ {
+		if err := fn(context.TODO(), &testMessage{topic: "foo", header: map[string]string{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if processed != 6 {
+		t.Fatalf("Expected messages without an id to be processed, got %v", processed)
+	}
+}
+
+func TestInboxInProgress(t *testing.T) {
+	reporter := &testReporter{counts: map[string]int64{}}
+	wrapper := NewWrapper(Store(memory.NewStore()), Reporter(reporter))
+
+	started, done := make(chan bool), make(chan bool)
+	fn := wrapper(func(ctx context.Context, msg server.Message) error {
+		started <- true
+		<-done
+		return nil
+	})
+
+	errc := make(chan error, 1)
+	go func() { errc <- fn(context.TODO(), newMessage("foo", "1")) }()
+	<-started
+
+	// a redelivery while the message is processed is rejected
+	if err := fn(context.TODO(), newMessage("foo", "1")); err != ErrInProgress {
+		t.Fatalf("Expected %v, got %v", ErrInProgress, err)
+	}
+	if c := reporter.counts[InProgressMetric+"/foo"]; c != 1 {
+		t.Fatalf("Expected 1 message in progress on foo, got %v", c)
+	}
+	if c := reporter.counts[DuplicatesMetric+"/foo"]; c != 0 {
+		t.Fatalf("Expected a message in progress not to be counted as a duplicate, got %v", c)
+	}
+
+	close(done)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInboxTTL(t *testing.T) {
+	wrapper := NewWrapper(Store(memory.NewStore()), TTL(time.Millisecond*50))
+
+	var processed int
+	fn := wrapper(func(ctx context.Context, msg server.Message) error {
+		processed++
+		return nil
+	})
+
+	fn(context.TODO(), newMessage("foo", "1"))
+	time.Sleep(time.Millisecond * 100)
+	fn(context.TODO(), newMessage("foo", "1"))
+
+	if processed != 2 {
+		t.Fatalf("Expected the message to be processed again once its id expired, got %v", processed)
+	}
+}
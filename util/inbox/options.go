@@ -0,0 +1,97 @@
+package inbox
+
+import (
+	"time"
+
+	"github.com/micro/go-micro/v3/metrics"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/util/outbox"
+)
+
+// Options for the inbox wrapper
+type Options struct {
+	// Store the processed message ids are recorded in, store.DefaultStore if nil
+	Store store.Store
+	// Database and Table the ids are recorded in
+	Database, Table string
+	// TTL is how long the id of a processed message is kept
+	TTL time.Duration
+	// Lease is how long a message being processed is claimed for. If the
+	// subscriber dies while processing it, redeliveries are processed once
+	// the lease has expired.
+	Lease time.Duration
+	// Headers the message id is read from, in order of preference
+	Headers []string
+	// Reporter the counts of duplicate and in progress messages are reported to
+	Reporter metrics.Reporter
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Table: "inbox",
+		TTL:   time.Hour * 24,
+		Lease: time.Minute,
+		// the outbox id is the same each time a message is relayed,
+		// unlike the id set by the client when publishing
+		Headers: []string{outbox.IdKey, "Micro-Id"},
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// store returns the store the ids are recorded in. The default store is
+// looked up on use as it's usually set once the service has been configured.
+func (o Options) store() store.Store {
+	if o.Store == nil {
+		return store.DefaultStore
+	}
+	return o.Store
+}
+
+// Store sets the store the processed message ids are recorded in
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Table sets the database and table the ids are recorded in
+func Table(database, table string) Option {
+	return func(o *Options) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// TTL sets how long the id of a processed message is kept
+func TTL(d time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = d
+	}
+}
+
+// Lease sets how long a message being processed is claimed for
+func Lease(d time.Duration) Option {
+	return func(o *Options) {
+		o.Lease = d
+	}
+}
+
+// Headers sets the headers the message id is read from, in order of preference
+func Headers(h ...string) Option {
+	return func(o *Options) {
+		o.Headers = h
+	}
+}
+
+// Reporter sets the reporter the duplicate counts are reported to
+func Reporter(r metrics.Reporter) Option {
+	return func(o *Options) {
+		o.Reporter = r
+	}
+}
@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/util/backoff"
+)
+
+// Options for the outbox wrapper and relay
+type Options struct {
+	// Store the messages are recorded in, store.DefaultStore if nil. It
+	// must be the store the transactions passed with NewContext were begun on.
+	Store store.Store
+	// Database and Table the messages are recorded in
+	Database, Table string
+	// Interval between checks for messages to relay
+	Interval time.Duration
+	// Backoff returns how long to wait before retrying a message
+	// which has failed to publish the given number of times
+	Backoff func(attempts int) time.Duration
+	// Retention is how long sent messages are kept
+	Retention time.Duration
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Table:     "outbox",
+		Interval:  time.Second,
+		Backoff:   backoff.Do,
+		Retention: time.Hour * 24,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// store returns the store the messages are recorded in. The default store is
+// looked up on use as it's usually set once the service has been configured.
+func (o Options) store() store.Store {
+	if o.Store == nil {
+		return store.DefaultStore
+	}
+	return o.Store
+}
+
+// Store sets the store the messages are recorded in
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Table sets the database and table the messages are recorded in
+func Table(database, table string) Option {
+	return func(o *Options) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// Interval sets the time between checks for messages to relay
+func Interval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// Backoff sets the function which returns how long to wait before retrying a message
+func Backoff(fn func(attempts int) time.Duration) Option {
+	return func(o *Options) {
+		o.Backoff = fn
+	}
+}
+
+// Retention sets how long sent messages are kept
+func Retention(d time.Duration) Option {
+	return func(o *Options) {
+		o.Retention = d
+	}
+}
+
+type txKey struct{}
+
+// NewContext returns a context carrying the transaction. Messages published
+// with it are recorded in the transaction, so they're only relayed if it's
+// committed along with the rest of its writes.
+func NewContext(ctx context.Context, tx store.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// FromContext returns the transaction carried by the context, if any
+func FromContext(ctx context.Context) (store.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(store.Tx)
+	return tx, ok
+}
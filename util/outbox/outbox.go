@@ -0,0 +1,154 @@
+// Package outbox records published messages in a store, alongside the writes
+// they announce, and relays them to the broker once they're committed.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/codec"
+	raw "github.com/micro/go-micro/v3/codec/bytes"
+	jsonCodec "github.com/micro/go-micro/v3/codec/json"
+	protoCodec "github.com/micro/go-micro/v3/codec/proto"
+	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// ErrUnsupportedContentType is returned when a message can't be encoded for the outbox
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+
+	// Marshalers used to encode messages by content type
+	Marshalers = map[string]codec.Marshaler{
+		"application/json":         jsonCodec.Marshaler{},
+		"application/grpc+json":    jsonCodec.Marshaler{},
+		"application/protobuf":     protoCodec.Marshaler{},
+		"application/grpc":         protoCodec.Marshaler{},
+		"application/grpc+proto":   protoCodec.Marshaler{},
+		"application/octet-stream": raw.Marshaler{},
+	}
+
+	// IdKey is the header relayed messages carry their outbox id in, so
+	// subscribers can recognise a message which was relayed more than once
+	IdKey = "Micro-Outbox-Id"
+
+	// prefixes of the keys of messages waiting to be relayed and those sent
+	pendingPrefix = "pending/"
+	sentPrefix    = "sent/"
+)
+
+// Message is a published message recorded in the outbox
+type Message struct {
+	Id          string
+	Topic       string
+	Exchange    string
+	ContentType string
+	Header      map[string]string
+	Body        []byte
+	// Created is when the message was published
+	Created time.Time
+	// Attempts to relay the message which have failed
+	Attempts int
+	// Retry is the time the next attempt is due after a failure
+	Retry time.Time
+	// Error returned by the last attempt
+	Error string
+	// Sent is when the message was relayed
+	Sent time.Time
+}
+
+// key of the message while it's pending, ordered by the time it was published
+func (m *Message) key() string {
+	return fmt.Sprintf("%s%020d/%s", pendingPrefix, m.Created.UnixNano(), m.Id)
+}
+
+func (m *Message) record(key string, expiry time.Duration) (*store.Record, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return &store.Record{Key: key, Value: b, Expiry: expiry}, nil
+}
+
+// NewWrapper returns a client wrapper which records published messages in the
+// outbox rather than publishing them. If the context carries a transaction, see
+// NewContext, the message is written in it. A Relay publishes the messages.
+func NewWrapper(opts ...Option) client.Wrapper {
+	options := newOptions(opts...)
+	return func(c client.Client) client.Client {
+		return &outboxClient{Client: c, opts: options}
+	}
+}
+
+type outboxClient struct {
+	client.Client
+	opts Options
+}
+
+type relayKey struct{}
+
+func (o *outboxClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	// messages being relayed are published as usual
+	if ctx.Value(relayKey{}) != nil {
+		return o.Client.Publish(ctx, msg, opts...)
+	}
+
+	var options client.PublishOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	body, err := encode(msg)
+	if err != nil {
+		return err
+	}
+
+	header := make(map[string]string)
+	if md, ok := metadata.FromContext(ctx); ok {
+		for k, v := range md {
+			header[k] = v
+		}
+	}
+
+	m := &Message{
+		Id:          uuid.New().String(),
+		Topic:       msg.Topic(),
+		Exchange:    options.Exchange,
+		ContentType: msg.ContentType(),
+		Header:      header,
+		Body:        body,
+		Created:     time.Now(),
+	}
+	rec, err := m.record(m.key(), 0)
+	if err != nil {
+		return err
+	}
+
+	table := store.WriteTo(o.opts.Database, o.opts.Table)
+	if tx, ok := FromContext(ctx); ok {
+		return tx.Write(rec, table)
+	}
+	return o.opts.store().Write(rec, table)
+}
+
+// encode the payload of the message as the client would
+func encode(msg client.Message) ([]byte, error) {
+	if f, ok := msg.Payload().(*raw.Frame); ok {
+		return f.Data, nil
+	}
+	m, ok := Marshalers[msg.ContentType()]
+	if !ok {
+		return nil, ErrUnsupportedContentType
+	}
+	b, err := m.Marshal(msg.Payload())
+	if err != nil {
+		return nil, err
+	}
+	// the marshalers may reuse their buffers
+	return append([]byte(nil), b...), nil
+}
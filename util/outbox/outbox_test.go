@@ -0,0 +1,150 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/client"
+	raw "github.com/micro/go-micro/v3/codec/bytes"
+	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+type testMessage struct {
+	topic   string
+	payload interface{}
+	ct      string
+}
+
+func (m *testMessage) Topic() string        { return m.topic }
+func (m *testMessage) Payload() interface{} { return m.payload }
+func (m *testMessage) ContentType() string  { return m.ct }
+
+// testClient records the messages it publishes, failing while err is set
+type testClient struct {
+	client.Client
+	err       error
+	published []client.Message
+	headers   []metadata.Metadata
+}
+
+func (c *testClient) NewMessage(topic string, msg interface{}, opts ...client.MessageOption) client.Message {
+	options := client.MessageOptions{ContentType: "application/json"}
+	for _, o := range opts {
+		o(&options)
+	}
+	return &testMessage{topic: topic, payload: msg, ct: options.ContentType}
+}
+
+func (c *testClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	if c.err != nil {
+		return c.err
+	}
+	md, _ := metadata.FromContext(ctx)
+	c.published = append(c.published, msg)
+	c.headers = append(c.headers, md)
+	return nil
+}
+
+func pending(t *testing.T, s store.Store) []string {
+	keys, err := s.List(store.ListFrom("", "outbox"), store.ListPrefix(pendingPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestOutbox(t *testing.T) {
+	st := memory.NewStore()
+	tc := &testClient{}
+	c := NewWrapper(Store(st))(tc)
+	relay := NewRelay(c, Store(st), Backoff(func(int) time.Duration { return 0 }))
+
+	// a message published in a transaction is only recorded if it's committed
+	tx, err := store.Begin(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(context.Background(), tx)
+	if err := c.Publish(ctx, c.NewMessage("orders", map[string]string{"id": "1"})); err != nil {
+		t.Fatalf("Unexpected error publishing: %v", err)
+	}
+	tx.Rollback()
+	if keys := pending(t, st); len(keys) != 0 {
+		t.Fatalf("Expected nothing to be recorded after a rollback, got %v", keys)
+	}
+
+	tx, _ = store.Begin(st)
+	ctx = metadata.Set(NewContext(context.Background(), tx), "Foo", "bar")
+	tx.Write(&store.Record{Key: "order/1"})
+	if err := c.Publish(ctx, c.NewMessage("orders", map[string]string{"id": "1"})); err != nil {
+		t.Fatalf("Unexpected error publishing: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := pending(t, st); len(keys) != 1 {
+		t.Fatalf("Expected the message to be recorded, got %v", keys)
+	}
+	if len(tc.published) != 0 {
+		t.Fatalf("Expected the message not to be published until it's relayed")
+	}
+
+	// a failed publish is retried
+	tc.err = errors.New("broker unavailable")
+	if err := relay.Flush(); err != nil {
+		t.Fatalf("Unexpected error relaying: %v", err)
+	}
+	keys := pending(t, st)
+	if len(keys) != 1 {
+		t.Fatalf("Expected the message to remain pending, got %v", keys)
+	}
+	recs, _ := st.Read(keys[0], store.ReadFrom("", "outbox"))
+	var m Message
+	if err := json.Unmarshal(recs[0].Value, &m); err != nil || m.Attempts != 1 || m.Error != "broker unavailable" {
+		t.Fatalf("Expected the failed attempt to be recorded, got %+v %v", m, err)
+	}
+
+	tc.err = nil
+	if err := relay.Flush(); err != nil {
+		t.Fatalf("Unexpected error relaying: %v", err)
+	}
+	if len(tc.published) != 1 {
+		t.Fatalf("Expected the message to be published once, got %v", len(tc.published))
+	}
+	msg := tc.published[0]
+	if f, ok := msg.Payload().(*raw.Frame); !ok || string(f.Data) != `{"id":"1"}` {
+		t.Fatalf("Expected the encoded payload to be relayed, got %v", msg.Payload())
+	}
+	if msg.Topic() != "orders" || tc.headers[0]["Foo"] != "bar" || len(tc.headers[0][IdKey]) == 0 {
+		t.Fatalf("Expected the topic and headers to be relayed, got %v %v", msg.Topic(), tc.headers[0])
+	}
+
+	// and it's marked sent
+	if keys := pending(t, st); len(keys) != 0 {
+		t.Fatalf("Expected no pending messages, got %v", keys)
+	}
+	if keys, _ := st.List(store.ListFrom("", "outbox"), store.ListPrefix(sentPrefix)); len(keys) != 1 {
+		t.Fatalf("Expected the message to be marked sent, got %v", keys)
+	}
+	relay.Flush()
+	if len(tc.published) != 1 {
+		t.Fatalf("Expected a sent message not to be published again")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	// raw bytes are recorded as they are, as the client would send them
+	b, err := encode(&testMessage{payload: []byte("raw"), ct: "application/octet-stream"})
+	if err != nil || string(b) != "raw" {
+		t.Fatalf("Expected the bytes to be recorded as they are, got %q %v", b, err)
+	}
+
+	if _, err := encode(&testMessage{payload: "raw", ct: "text/plain"}); err != ErrUnsupportedContentType {
+		t.Fatalf("Expected ErrUnsupportedContentType, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/client"
+	raw "github.com/micro/go-micro/v3/codec/bytes"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/store"
+)
+
+// number of pending messages read at a time
+var batchSize uint = 100
+
+// Relay publishes the messages recorded in the outbox, retrying those which
+// fail, and marks them sent. A message is marked sent after it's published,
+// so it's delivered at least once.
+type Relay struct {
+	opts   Options
+	client client.Client
+
+	sync.Mutex
+	exit chan bool
+}
+
+// NewRelay returns a relay which publishes the messages with the client. The
+// client may be wrapped by the outbox, messages being relayed aren't recorded.
+func NewRelay(c client.Client, opts ...Option) *Relay {
+	return &Relay{
+		opts:   newOptions(opts...),
+		client: c,
+	}
+}
+
+// Start relaying messages
+func (r *Relay) Start() {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.exit != nil {
+		return
+	}
+	r.exit = make(chan bool)
+	go r.run(r.exit)
+}
+
+// Stop relaying messages
+func (r *Relay) Stop() {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.exit == nil {
+		return
+	}
+	close(r.exit)
+	r.exit = nil
+}
+
+func (r *Relay) run(exit chan bool) {
+	t := time.NewTicker(r.opts.Interval)
+	defer t.Stop()
+
+	for {
+		if err := r.Flush(); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error relaying outbox messages: %v", err)
+		}
+
+		select {
+		case <-t.C:
+		case <-exit:
+			return
+		}
+	}
+}
+
+// Flush publishes the pending messages, in the order they were recorded,
+// other than those waiting to be retried
+func (r *Relay) Flush() error {
+	var cursor string
+	for {
+		keys, err := r.opts.store().List(
+			store.ListFrom(r.opts.Database, r.opts.Table),
+			store.ListPrefix(pendingPrefix),
+			store.ListCursor(cursor),
+			store.ListLimit(batchSize),
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := r.relay(k); err != nil {
+				return err
+			}
+		}
+
+		if uint(len(keys)) < batchSize {
+			return nil
+		}
+		cursor = store.Cursor(keys[len(keys)-1])
+	}
+}
+
+// relay the message, returning an error only if the outbox can't be updated
+func (r *Relay) relay(key string) error {
+	st := r.opts.store()
+	from := store.ReadFrom(r.opts.Database, r.opts.Table)
+	to := store.WriteTo(r.opts.Database, r.opts.Table)
+
+	recs, err := st.Read(key, from)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var m Message
+	if err := json.Unmarshal(recs[0].Value, &m); err != nil {
+		return err
+	}
+	if time.Now().Before(m.Retry) {
+		return nil
+	}
+
+	if err := r.publish(&m); err != nil {
+		// record the failure and try again after the backoff
+		m.Attempts++
+		m.Error = err.Error()
+		m.Retry = time.Now().Add(r.opts.Backoff(m.Attempts))
+		rec, err := m.record(key, 0)
+		if err != nil {
+			return err
+		}
+		return st.Write(rec, to)
+	}
+
+	// move the message to those sent, in a transaction if the store supports them
+	m.Sent = time.Now()
+	m.Error = ""
+	rec, err := m.record(sentPrefix+m.Id, r.opts.Retention)
+	if err != nil {
+		return err
+	}
+
+	tx, err := store.Begin(st)
+	if err == store.ErrTxNotSupported {
+		if err := st.Write(rec, to); err != nil {
+			return err
+		}
+		return st.Delete(key, store.DeleteFrom(r.opts.Database, r.opts.Table))
+	} else if err != nil {
+		return err
+	}
+	if err := tx.Write(rec, to); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Delete(key, store.DeleteFrom(r.opts.Database, r.opts.Table)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Relay) publish(m *Message) error {
+	md := metadata.Metadata{}
+	for k, v := range m.Header {
+		md[k] = v
+	}
+	md[IdKey] = m.Id
+
+	ctx := metadata.NewContext(context.WithValue(context.Background(), relayKey{}, true), md)
+	msg := r.client.NewMessage(m.Topic, &raw.Frame{Data: m.Body}, client.WithMessageContentType(m.ContentType))
+
+	var opts []client.PublishOption
+	if len(m.Exchange) > 0 {
+		opts = append(opts, client.WithExchange(m.Exchange))
+	}
+	return r.client.Publish(ctx, msg, opts...)
+}
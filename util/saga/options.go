@@ -0,0 +1,84 @@
+package saga
+
+import (
+	"time"
+
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/util/backoff"
+)
+
+// Options for the orchestrator
+type Options struct {
+	// Store the state of the sagas is kept in, store.DefaultStore if nil
+	Store store.Store
+	// Database and Table the state is kept in
+	Database, Table string
+	// Retries is the number of times a compensation is retried before the saga fails
+	Retries int
+	// Backoff returns how long to wait before the given attempt at a compensation is retried
+	Backoff func(attempts int) time.Duration
+	// Retention is how long the state of a saga is kept once it's finished.
+	// Zero keeps it indefinitely.
+	Retention time.Duration
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Table:     "sagas",
+		Retries:   3,
+		Backoff:   backoff.Do,
+		Retention: time.Hour * 24 * 7,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// store returns the store the state is kept in. The default store is looked
+// up on use as it's usually set once the service has been configured.
+func (o Options) store() store.Store {
+	if o.Store == nil {
+		return store.DefaultStore
+	}
+	return o.Store
+}
+
+// Store sets the store the state of the sagas is kept in
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Table sets the database and table the state is kept in
+func Table(database, table string) Option {
+	return func(o *Options) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// Retries sets the number of times a compensation is retried before the saga fails
+func Retries(n int) Option {
+	return func(o *Options) {
+		o.Retries = n
+	}
+}
+
+// Backoff sets how long to wait before a failed compensation is retried
+func Backoff(fn func(attempts int) time.Duration) Option {
+	return func(o *Options) {
+		o.Backoff = fn
+	}
+}
+
+// Retention sets how long the state of a finished saga is kept
+func Retention(d time.Duration) Option {
+	return func(o *Options) {
+		o.Retention = d
+	}
+}
@@ -0,0 +1,461 @@
+// Package saga orchestrates workflows which span services. Each step of a saga
+// calls an action on a service; if one fails, the steps completed so far are
+// undone in reverse order by calling their compensations. The state of each saga
+// is kept in a store and its progress is driven by events, so sagas in flight are
+// resumed after a restart.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/events"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// ErrNotFound is returned when a saga doesn't exist
+	ErrNotFound = errors.New("saga not found")
+	// ErrUndefined is returned when running a saga which hasn't been defined
+	ErrUndefined = errors.New("saga not defined")
+	// ErrDefined is returned when defining a saga twice, or once the orchestrator has started
+	ErrDefined = errors.New("saga already defined")
+
+	// how long to wait for a step to be processed before it's redelivered
+	ackWait = time.Minute
+)
+
+const (
+	// IdKey is the metadata key the id of the saga is passed to actions and compensations in.
+	// Steps may be retried, so services should use it with StepKey to make them idempotent.
+	IdKey = "Micro-Saga-Id"
+	// StepKey is the metadata key the name of the step is passed to actions and compensations in
+	StepKey = "Micro-Saga-Step"
+
+	// prefix of the topics the progress of each saga is published to
+	topicPrefix = "saga."
+)
+
+// Status of a saga
+type Status string
+
+const (
+	// Running sagas are executing their steps
+	Running Status = "running"
+	// Compensating sagas are undoing their steps after one failed
+	Compensating Status = "compensating"
+	// Completed sagas executed every step
+	Completed Status = "completed"
+	// Aborted sagas undid every step completed before one failed
+	Aborted Status = "aborted"
+	// Failed sagas couldn't undo a step, so need to be resolved by hand
+	Failed Status = "failed"
+)
+
+// Finished returns true if the saga won't make any more progress
+func (s Status) Finished() bool {
+	return s == Completed || s == Aborted || s == Failed
+}
+
+// Call is an endpoint of a service. The data of the saga is the request, as JSON.
+type Call struct {
+	Service  string
+	Endpoint string
+}
+
+// Step of a saga
+type Step struct {
+	// Name of the step, which its result is recorded under
+	Name string
+	// Action called to execute the step
+	Action Call
+	// Compensate is called to undo the step if a later one fails. Steps
+	// without a compensation have nothing to undo.
+	Compensate Call
+}
+
+// State of a saga
+type State struct {
+	// Id of the saga
+	Id string
+	// Saga is the name of the saga definition
+	Saga string
+	// Status of the saga
+	Status Status
+	// Step is the index of the step to execute while running, or to undo while compensating
+	Step int
+	// Data the saga was run with
+	Data json.RawMessage
+	// Results of the actions, by step name
+	Results map[string]json.RawMessage
+	// Error of the step which failed
+	Error string
+	// Attempts at the current compensation
+	Attempts int
+	// Created and Updated are the times the saga was run and last progressed
+	Created, Updated time.Time
+
+	// version of the record the state was read from
+	version uint64
+}
+
+// progress is the event published to drive the next step of a saga. Events
+// which don't match the state of the saga are stale and ignored.
+type progress struct {
+	Id       string
+	Status   Status
+	Step     int
+	Attempts int
+}
+
+// Orchestrator runs sagas
+type Orchestrator struct {
+	opts   Options
+	client client.Client
+	stream events.Stream
+
+	sync.Mutex
+	sagas map[string][]Step
+	// cancel ends the subscriptions once the orchestrator is stopped
+	cancel context.CancelFunc
+}
+
+// NewOrchestrator returns an orchestrator which calls steps with the client and
+// drives their progress with events published to the stream
+func NewOrchestrator(c client.Client, s events.Stream, opts ...Option) *Orchestrator {
+	return &Orchestrator{
+		opts:   newOptions(opts...),
+		client: c,
+		stream: s,
+		sagas:  make(map[string][]Step),
+	}
+}
+
+// Define a saga with the steps given. Sagas must be defined before the orchestrator is started.
+func (o *Orchestrator) Define(name string, steps ...Step) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if _, ok := o.sagas[name]; ok || o.cancel != nil {
+		return ErrDefined
+	}
+	o.sagas[name] = steps
+	return nil
+}
+
+// Start processing the sagas defined, resuming those in flight. Every orchestrator
+// sharing the stream and store should define the same sagas; each step is processed
+// by one of them.
+func (o *Orchestrator) Start() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.cancel != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for name := range o.sagas {
+		topic := topicPrefix + name
+		ch, err := o.stream.Subscribe(topic,
+			events.WithQueue(topic),
+			events.WithAutoAck(false, ackWait),
+			events.WithContext(ctx),
+		)
+		if err != nil {
+			cancel()
+			return err
+		}
+		go o.process(ctx, ch)
+	}
+	o.cancel = cancel
+
+	return o.resume()
+}
+
+// Stop processing sagas. Those in flight are resumed when an orchestrator is started.
+func (o *Orchestrator) Stop() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.cancel == nil {
+		return nil
+	}
+	o.cancel()
+	o.cancel = nil
+	return nil
+}
+
+// Run a saga with the data given, returning its id. The data is encoded as JSON
+// unless it's already bytes. The saga runs asynchronously; its progress can be
+// checked with State.
+func (o *Orchestrator) Run(name string, data interface{}) (string, error) {
+	o.Lock()
+	_, ok := o.sagas[name]
+	o.Unlock()
+	if !ok {
+		return "", ErrUndefined
+	}
+
+	b, ok := data.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(data); err != nil {
+			return "", err
+		}
+	}
+
+	now := time.Now()
+	state := &State{
+		Id:      uuid.New().String(),
+		Saga:    name,
+		Status:  Running,
+		Data:    b,
+		Results: make(map[string]json.RawMessage),
+		Created: now,
+		Updated: now,
+	}
+	if err := o.save(state); err != nil {
+		return "", err
+	}
+	if err := o.publish(state); err != nil {
+		return "", err
+	}
+	return state.Id, nil
+}
+
+// State returns the state of a saga
+func (o *Orchestrator) State(id string) (*State, error) {
+	recs, err := o.opts.store().Read(id, store.ReadFrom(o.opts.Database, o.opts.Table))
+	if err == store.ErrNotFound || (err == nil && len(recs) == 0) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := json.Unmarshal(recs[0].Value, &state); err != nil {
+		return nil, err
+	}
+	state.version = recs[0].Version
+	return &state, nil
+}
+
+// resume the sagas in flight by publishing their progress again
+func (o *Orchestrator) resume() error {
+	ids, err := o.opts.store().List(store.ListFrom(o.opts.Database, o.opts.Table))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		state, err := o.State(id)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if _, ok := o.sagas[state.Saga]; !ok || state.Status.Finished() {
+			continue
+		}
+		if err := o.publish(state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// save the state, unless it's been changed since it was read
+func (o *Orchestrator) save(state *State) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	rec := &store.Record{Key: state.Id, Value: b}
+	if state.Status.Finished() {
+		rec.Expiry = o.opts.Retention
+	}
+
+	opts := []store.WriteOption{store.WriteTo(o.opts.Database, o.opts.Table)}
+	if state.version > 0 {
+		opts = append(opts, store.WriteIfVersion(state.version))
+	} else {
+		opts = append(opts, store.WriteIfNotExists())
+	}
+
+	err = o.opts.store().Write(rec, opts...)
+	if err == store.ErrConditionNotSupported {
+		err = o.opts.store().Write(rec, opts[0])
+	}
+	return err
+}
+
+// publish the progress of the saga, so its next step is processed
+func (o *Orchestrator) publish(state *State) error {
+	return o.stream.Publish(topicPrefix+state.Saga, &progress{
+		Id:       state.Id,
+		Status:   state.Status,
+		Step:     state.Step,
+		Attempts: state.Attempts,
+	})
+}
+
+func (o *Orchestrator) process(ctx context.Context, ch <-chan events.Event) {
+	for {
+		select {
+		case ev := <-ch:
+			if err := o.handle(ctx, &ev); err != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("Error processing saga step: %v", err)
+				}
+				ev.Nack()
+				continue
+			}
+			ev.Ack()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handle an event, executing the next step of the saga
+func (o *Orchestrator) handle(ctx context.Context, ev *events.Event) error {
+	var p progress
+	if err := ev.Unmarshal(&p); err != nil {
+		// an event which can't be decoded never will be
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Dropping invalid saga event %v: %v", ev.ID, err)
+		}
+		return nil
+	}
+
+	state, err := o.State(p.Id)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// skip events for steps which have already been processed
+	if state.Status != p.Status || state.Step != p.Step || state.Attempts != p.Attempts {
+		return nil
+	}
+
+	o.Lock()
+	steps, ok := o.sagas[state.Saga]
+	o.Unlock()
+	if !ok {
+		return ErrUndefined
+	}
+
+	o.step(state, steps)
+	state.Updated = time.Now()
+
+	switch err := o.save(state); err {
+	case nil:
+	case store.ErrVersionMismatch:
+		// another orchestrator processed the step
+		return nil
+	default:
+		return err
+	}
+
+	if state.Status.Finished() {
+		return nil
+	}
+	if state.Attempts > 0 {
+		o.retry(ctx, state)
+		return nil
+	}
+	return o.publish(state)
+}
+
+// retry a failed compensation once it's backed off. If the orchestrator stops
+// first, the compensation is retried once the saga is resumed.
+func (o *Orchestrator) retry(ctx context.Context, state *State) {
+	go func() {
+		select {
+		case <-time.After(o.opts.Backoff(state.Attempts)):
+		case <-ctx.Done():
+			return
+		}
+		if err := o.publish(state); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error retrying compensation of saga %v: %v", state.Id, err)
+		}
+	}()
+}
+
+// step executes or undoes the current step of the saga, updating its state
+func (o *Orchestrator) step(state *State, steps []Step) {
+	switch state.Status {
+	case Running:
+		if state.Step >= len(steps) {
+			state.Status = Completed
+			return
+		}
+
+		step := steps[state.Step]
+		rsp, err := o.call(state, step.Name, step.Action)
+		if err != nil {
+			// the step failed, so undo those before it
+			state.Status = Compensating
+			state.Error = err.Error()
+			state.Step--
+		} else {
+			state.Results[step.Name] = rsp
+			state.Step++
+		}
+	case Compensating:
+		if state.Step < 0 {
+			state.Status = Aborted
+			return
+		}
+
+		step := steps[state.Step]
+		if len(step.Compensate.Service) > 0 {
+			if _, err := o.call(state, step.Name, step.Compensate); err != nil {
+				state.Attempts++
+				if state.Attempts > o.opts.Retries {
+					state.Status = Failed
+					state.Error = err.Error()
+				}
+				return
+			}
+		}
+		state.Step--
+		state.Attempts = 0
+	}
+
+	// finish without waiting for another event
+	if state.Status == Running && state.Step >= len(steps) {
+		state.Status = Completed
+	}
+	if state.Status == Compensating && state.Step < 0 {
+		state.Status = Aborted
+	}
+}
+
+// call the endpoint with the data of the saga
+func (o *Orchestrator) call(state *State, step string, c Call) (json.RawMessage, error) {
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{
+		IdKey:   state.Id,
+		StepKey: step,
+	})
+
+	req := o.client.NewRequest(c.Service, c.Endpoint, state.Data, client.WithContentType("application/json"))
+
+	var rsp json.RawMessage
+	if err := o.client.Call(ctx, req, &rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
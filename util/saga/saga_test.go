@@ -0,0 +1,259 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/events/stream/memory"
+	"github.com/micro/go-micro/v3/metadata"
+	smemory "github.com/micro/go-micro/v3/store/memory"
+)
+
+type testRequest struct {
+	client.Request
+	service, endpoint string
+	body              interface{}
+}
+
+func (r *testRequest) Service() string   { return r.service }
+func (r *testRequest) Endpoint() string  { return r.endpoint }
+func (r *testRequest) Body() interface{} { return r.body }
+
+// testClient records the endpoints called, failing those in fail
+type testClient struct {
+	client.Client
+
+	sync.Mutex
+	calls []string
+	fail  map[string]bool
+}
+
+func (c *testClient) NewRequest(service, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	return &testRequest{service: service, endpoint: endpoint, body: req}
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.Lock()
+	defer c.Unlock()
+
+	md, _ := metadata.FromContext(ctx)
+	if _, ok := md.Get(IdKey); !ok {
+		return errors.New("missing saga id")
+	}
+
+	c.calls = append(c.calls, req.Endpoint())
+	if c.fail[req.Endpoint()] {
+		return errors.New("failed")
+	}
+	*rsp.(*json.RawMessage) = json.RawMessage(`"` + req.Endpoint() + ` done"`)
+	return nil
+}
+
+func (c *testClient) called() []string {
+	c.Lock()
+	defer c.Unlock()
+	return append([]string{}, c.calls...)
+}
+
+var checkout = []Step{
+	{Name: "reserve", Action: Call{"stock", "Stock.Reserve"}, Compensate: Call{"stock", "Stock.Release"}},
+	{Name: "charge", Action: Call{"payments", "Payments.Charge"}, Compensate: Call{"payments", "Payments.Refund"}},
+	{Name: "ship", Action: Call{"shipping", "Shipping.Ship"}},
+}
+
+func newOrchestrator(t *testing.T, c client.Client, opts ...Option) *Orchestrator {
+	stream, err := memory.NewStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewOrchestrator(c, stream, opts...)
+	if err := o.Define("checkout", checkout...); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// wait for the saga to finish
+func wait(t *testing.T, o *Orchestrator, id string) *State {
+	for i := 0; i < 100; i++ {
+		state, err := o.State(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Status.Finished() {
+			return state
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("Saga didn't finish")
+	return nil
+}
+
+func TestSaga(t *testing.T) {
+	c := &testClient{}
+	o := newOrchestrator(t, c, Store(smemory.NewStore()))
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	if err := o.Define("other"); err != ErrDefined {
+		t.Fatalf("Expected %v defining a saga once started, got %v", ErrDefined, err)
+	}
+	if _, err := o.Run("other", nil); err != ErrUndefined {
+		t.Fatalf("Expected %v, got %v", ErrUndefined, err)
+	}
+
+	id, err := o.Run("checkout", map[string]string{"order": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := wait(t, o, id)
+	if state.Status != Completed {
+		t.Fatalf("Expected the saga to complete, got %v: %v", state.Status, state.Error)
+	}
+	if string(state.Results["charge"]) != `"Payments.Charge done"` {
+		t.Fatalf("Expected the result of the charge to be recorded, got %s", state.Results["charge"])
+	}
+	if calls := c.called(); len(calls) != 3 || calls[0] != "Stock.Reserve" || calls[2] != "Shipping.Ship" {
+		t.Fatalf("Expected every step to be called in order, got %v", calls)
+	}
+}
+
+func TestSagaCompensation(t *testing.T) {
+	c := &testClient{fail: map[string]bool{"Shipping.Ship": true}}
+	o := newOrchestrator(t, c, Store(smemory.NewStore()))
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	id, err := o.Run("checkout", map[string]string{"order": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := wait(t, o, id)
+	if state.Status != Aborted || state.Error != "failed" {
+		t.Fatalf("Expected the saga to abort, got %v: %v", state.Status, state.Error)
+	}
+
+	expected := []string{"Stock.Reserve", "Payments.Charge", "Shipping.Ship", "Payments.Refund", "Stock.Release"}
+	calls := c.called()
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("Expected calls %v, got %v", expected, calls)
+		}
+	}
+}
+
+func TestSagaCompensationFailure(t *testing.T) {
+	c := &testClient{fail: map[string]bool{"Shipping.Ship": true, "Payments.Refund": true}}
+	o := newOrchestrator(t, c, Store(smemory.NewStore()), Retries(2), Backoff(func(attempts int) time.Duration {
+		return time.Millisecond * 50 * time.Duration(attempts)
+	}))
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	start := time.Now()
+	id, err := o.Run("checkout", map[string]string{"order": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := wait(t, o, id)
+	if state.Status != Failed || state.Step != 1 {
+		t.Fatalf("Expected the saga to fail compensating the charge, got %v at %v", state.Status, state.Step)
+	}
+
+	var refunds int
+	for _, call := range c.called() {
+		if call == "Payments.Refund" {
+			refunds++
+		}
+	}
+	if refunds != 3 {
+		t.Fatalf("Expected the refund to be attempted 3 times, got %v", refunds)
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*150 {
+		t.Fatalf("Expected the refund to be retried after backing off, finished in %v", elapsed)
+	}
+}
+
+func TestSagaResume(t *testing.T) {
+	st := smemory.NewStore()
+	c := &testClient{}
+
+	// a saga is run but its orchestrator stops before processing it
+	o := newOrchestrator(t, c, Store(st))
+	id, err := o.Run("checkout", map[string]string{"order": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another orchestrator on the same store picks it up
+	o = newOrchestrator(t, c, Store(st))
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	if state := wait(t, o, id); state.Status != Completed {
+		t.Fatalf("Expected the saga to be resumed and complete, got %v", state.Status)
+	}
+	if calls := c.called(); len(calls) != 3 {
+		t.Fatalf("Expected each step to be called once, got %v", calls)
+	}
+}
+
+func TestSagaStop(t *testing.T) {
+	stream, err := memory.NewStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := smemory.NewStore()
+	c := &testClient{}
+
+	// an orchestrator is started then stopped, ending its subscription
+	o := NewOrchestrator(c, stream, Store(st))
+	if err := o.Define("checkout", checkout...); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// another on the same stream processes the sagas run after
+	o = NewOrchestrator(c, stream, Store(st))
+	if err := o.Define("checkout", checkout...); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	for i := 0; i < 5; i++ {
+		id, err := o.Run("checkout", map[string]string{"order": "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state := wait(t, o, id); state.Status != Completed {
+			t.Fatalf("Expected the saga to complete, got %v", state.Status)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type jsonFormat struct{}
+
+// jsonSchema is the supported subset of a JSON Schema document
+type jsonSchema struct {
+	Type                 jsonTypes              `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *jsonAdditional        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Pattern              string                 `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// jsonTypes is a type keyword, which may be a single type or a list
+type jsonTypes []string
+
+func (t *jsonTypes) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = jsonTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+// allows returns true if the type is allowed. An integer is also a number.
+func (t jsonTypes) allows(typ string) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, a := range t {
+		if a == typ || (a == "number" && typ == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonAdditional is an additionalProperties keyword, either a boolean or a schema
+type jsonAdditional struct {
+	Allowed bool
+	Schema  *jsonSchema
+}
+
+func (a *jsonAdditional) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(b, &a.Schema)
+}
+
+// additional returns whether properties which aren't listed are allowed, and their schema
+func (s *jsonSchema) additional() (bool, *jsonSchema) {
+	if s.AdditionalProperties == nil {
+		return true, nil
+	}
+	return s.AdditionalProperties.Allowed, s.AdditionalProperties.Schema
+}
+
+func (jsonFormat) parse(s *Schema) (definition, error) {
+	var js jsonSchema
+	if err := json.Unmarshal(s.Definition, &js); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	if err := js.compile(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return &js, nil
+}
+
+// compile the patterns of the schema and its subschemas
+func (s *jsonSchema) compile() error {
+	if len(s.Pattern) > 0 {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	for _, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	if _, as := s.additional(); as != nil {
+		if err := as.compile(); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+	return nil
+}
+
+func (s *jsonSchema) validate(contentType string, payload []byte) error {
+	if len(contentType) > 0 && !strings.Contains(contentType, "json") {
+		return ErrUnsupportedContentType
+	}
+
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if err := s.check("", v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return nil
+}
+
+// jsonType returns the JSON Schema type of a decoded value
+func jsonType(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// check the value at the path matches the schema
+func (s *jsonSchema) check(path string, v interface{}) error {
+	if typ := jsonType(v); !s.Type.allows(typ) {
+		return fmt.Errorf("%s: expected %s, got %s", at(path), strings.Join(s.Type, " or "), typ)
+	}
+
+	if len(s.Enum) > 0 {
+		var found bool
+		for _, e := range s.Enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: not one of the allowed values", at(path))
+		}
+	}
+
+	switch val := v.(type) {
+	case json.Number:
+		f, _ := val.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: less than the minimum %v", at(path), *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: greater than the maximum %v", at(path), *s.Maximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fmt.Errorf("%s: shorter than %v", at(path), *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fmt.Errorf("%s: longer than %v", at(path), *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			return fmt.Errorf("%s: doesn't match %v", at(path), s.Pattern)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			return fmt.Errorf("%s: fewer than %v items", at(path), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			return fmt.Errorf("%s: more than %v items", at(path), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				if err := s.Items.check(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, r := range s.Required {
+			if _, ok := val[r]; !ok {
+				return fmt.Errorf("%s: missing required property %s", at(path), r)
+			}
+		}
+
+		allowed, as := s.additional()
+		for _, k := range sortedKeys(val) {
+			ps, ok := s.Properties[k]
+			if !ok && !allowed {
+				return fmt.Errorf("%s: unexpected property %s", at(path), k)
+			}
+			if !ok {
+				ps = as
+			}
+			if ps == nil {
+				continue
+			}
+			if err := ps.check(path+"."+k, val[k]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// canRead returns an error if a value written with the writer
+// schema may not match this one
+func (s *jsonSchema) canRead(writer definition) error {
+	w, ok := writer.(*jsonSchema)
+	if !ok {
+		return ErrUnsupportedFormat
+	}
+	return s.reads("", w)
+}
+
+func (s *jsonSchema) reads(path string, w *jsonSchema) error {
+	// every type the writer allows must be allowed
+	if len(s.Type) > 0 {
+		if len(w.Type) == 0 {
+			return fmt.Errorf("%s: type restricted to %s", at(path), strings.Join(s.Type, " or "))
+		}
+		for _, t := range w.Type {
+			if !s.Type.allows(t) {
+				return fmt.Errorf("%s: type %s no longer allowed", at(path), t)
+			}
+		}
+	}
+
+	// as must every value
+	if len(s.Enum) > 0 {
+		if len(w.Enum) == 0 {
+			return fmt.Errorf("%s: values restricted", at(path))
+		}
+		for _, e := range w.Enum {
+			var found bool
+			for _, a := range s.Enum {
+				if equal(a, e) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: value %v no longer allowed", at(path), e)
+			}
+		}
+	}
+
+	// bounds may only be relaxed
+	if !lower(s.Minimum, w.Minimum) || !upper(s.Maximum, w.Maximum) {
+		return fmt.Errorf("%s: range narrowed", at(path))
+	}
+	if !lowerInt(s.MinLength, w.MinLength) || !upperInt(s.MaxLength, w.MaxLength) {
+		return fmt.Errorf("%s: length narrowed", at(path))
+	}
+	if !lowerInt(s.MinItems, w.MinItems) || !upperInt(s.MaxItems, w.MaxItems) {
+		return fmt.Errorf("%s: number of items narrowed", at(path))
+	}
+	if len(s.Pattern) > 0 && s.Pattern != w.Pattern {
+		return fmt.Errorf("%s: pattern changed", at(path))
+	}
+
+	// properties the reader requires must always be written
+	for _, r := range s.Required {
+		if !contains(w.Required, r) {
+			return fmt.Errorf("%s: property %s is required", at(path), r)
+		}
+	}
+
+	// properties the writer may write must be readable
+	allowed, as := s.additional()
+	for _, k := range sortedKeys(w.Properties) {
+		rs, ok := s.Properties[k]
+		if !ok && !allowed {
+			return fmt.Errorf("%s: property %s is no longer allowed", at(path), k)
+		}
+		if !ok {
+			rs = as
+		}
+		if rs == nil {
+			continue
+		}
+		if err := rs.reads(path+"."+k, w.Properties[k]); err != nil {
+			return err
+		}
+	}
+	if wAllowed, _ := w.additional(); wAllowed && !allowed {
+		return fmt.Errorf("%s: additional properties are no longer allowed", at(path))
+	}
+
+	if s.Items != nil {
+		if w.Items == nil {
+			return fmt.Errorf("%s: items restricted", at(path))
+		}
+		return s.Items.reads(path+"[]", w.Items)
+	}
+
+	return nil
+}
+
+// lower returns true if the writer's lower bound is at or above the reader's
+func lower(r, w *float64) bool {
+	return r == nil || (w != nil && *w >= *r)
+}
+
+// upper returns true if the writer's upper bound is at or below the reader's
+func upper(r, w *float64) bool {
+	return r == nil || (w != nil && *w <= *r)
+}
+
+func lowerInt(r, w *int) bool {
+	return r == nil || (w != nil && *w >= *r)
+}
+
+func upperInt(r, w *int) bool {
+	return r == nil || (w != nil && *w <= *r)
+}
+
+// equal compares values decoded from a schema and a payload. Numbers
+// are compared by value as they may be decoded differently.
+func equal(a, b interface{}) bool {
+	if an, ok := number(a); ok {
+		bn, ok := number(b)
+		return ok && an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// at returns the path for errors, with the root as "$"
+func at(path string) string {
+	return "$" + path
+}
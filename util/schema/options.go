@@ -0,0 +1,58 @@
+package schema
+
+import "github.com/micro/go-micro/v3/store"
+
+// Options for the registry
+type Options struct {
+	// Store the schemas are kept in, store.DefaultStore if nil
+	Store store.Store
+	// Database and Table the schemas are kept in
+	Database, Table string
+	// Compatibility required between the versions of the schema of a topic
+	Compatibility Compatibility
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Table:         "schemas",
+		Compatibility: Backward,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// store returns the store the schemas are kept in. The default store is
+// looked up on use as it's usually set once the service has been configured.
+func (o Options) store() store.Store {
+	if o.Store == nil {
+		return store.DefaultStore
+	}
+	return o.Store
+}
+
+// Store sets the store the schemas are kept in
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Table sets the database and table the schemas are kept in
+func Table(database, table string) Option {
+	return func(o *Options) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// WithCompatibility sets the compatibility required between versions
+func WithCompatibility(c Compatibility) Option {
+	return func(o *Options) {
+		o.Compatibility = c
+	}
+}
@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type protoFormat struct{}
+
+// protoSchema is the descriptor of the message type of a topic
+type protoSchema struct {
+	desc protoreflect.MessageDescriptor
+}
+
+// NewProtoSchema returns the schema of the topic for the type of the message
+// given, with the descriptors of the file it's defined in and its dependencies.
+func NewProtoSchema(topic string, m proto.Message) (*Schema, error) {
+	desc := m.ProtoReflect().Descriptor()
+
+	// collect the files in dependency order
+	var set descriptorpb.FileDescriptorSet
+	seen := make(map[string]bool)
+
+	var add func(f protoreflect.FileDescriptor)
+	add = func(f protoreflect.FileDescriptor) {
+		if seen[f.Path()] {
+			return
+		}
+		seen[f.Path()] = true
+		imports := f.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(f))
+	}
+	add(desc.ParentFile())
+
+	b, err := proto.Marshal(&set)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schema{
+		Topic:      topic,
+		Format:     Protobuf,
+		Definition: b,
+		Message:    string(desc.FullName()),
+	}, nil
+}
+
+func (protoFormat) parse(s *Schema) (definition, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(s.Definition, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(s.Message))
+	if err != nil {
+		return nil, fmt.Errorf("%w: message %v: %v", ErrInvalidSchema, s.Message, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v isn't a message", ErrInvalidSchema, s.Message)
+	}
+
+	return &protoSchema{desc: md}, nil
+}
+
+func (s *protoSchema) validate(contentType string, payload []byte) error {
+	msg := dynamicpb.NewMessage(s.desc)
+
+	var err error
+	switch {
+	case strings.Contains(contentType, "json"):
+		err = protojson.Unmarshal(payload, msg)
+	case len(contentType) == 0, strings.Contains(contentType, "proto"):
+		err = proto.Unmarshal(payload, msg)
+	default:
+		return ErrUnsupportedContentType
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return nil
+}
+
+func (s *protoSchema) canRead(writer definition) error {
+	w, ok := writer.(*protoSchema)
+	if !ok {
+		return ErrUnsupportedFormat
+	}
+	return readsMessage(s.desc, w.desc, make(map[protoreflect.FullName]bool))
+}
+
+// readsMessage returns an error if a message written with the writer descriptor
+// may not be read with the reader. Fields are matched by number, as on the wire;
+// fields only the writer has are skipped as unknown fields by the reader.
+func readsMessage(r, w protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) error {
+	if seen[r.FullName()] {
+		return nil
+	}
+	seen[r.FullName()] = true
+
+	rf, wf := r.Fields(), w.Fields()
+	for i := 0; i < rf.Len(); i++ {
+		f := rf.Get(i)
+		g := wf.ByNumber(f.Number())
+
+		if g == nil {
+			if f.Cardinality() == protoreflect.Required {
+				return fmt.Errorf("%v: required field %v isn't written", r.FullName(), f.Name())
+			}
+			continue
+		}
+
+		if f.Cardinality() == protoreflect.Required && g.Cardinality() != protoreflect.Required {
+			return fmt.Errorf("%v: field %v is required", r.FullName(), f.Name())
+		}
+		if f.IsList() != g.IsList() || f.IsMap() != g.IsMap() {
+			return fmt.Errorf("%v: field %v changed between singular and repeated", r.FullName(), f.Name())
+		}
+		if f.Kind() != g.Kind() {
+			return fmt.Errorf("%v: field %v changed from %v to %v", r.FullName(), f.Name(), g.Kind(), f.Kind())
+		}
+		if f.Message() != nil {
+			if err := readsMessage(f.Message(), g.Message(), seen); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
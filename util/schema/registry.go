@@ -0,0 +1,186 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/store"
+)
+
+// Registry keeps the versions of the schema of each topic in a store. A new version
+// is only registered if it's compatible with the latest one.
+type Registry struct {
+	opts Options
+
+	// parsed definitions by topic and version
+	sync.RWMutex
+	parsed map[string]definition
+}
+
+// NewRegistry returns a registry of schemas
+func NewRegistry(opts ...Option) *Registry {
+	return &Registry{
+		opts:   newOptions(opts...),
+		parsed: make(map[string]definition),
+	}
+}
+
+// versionPrefix is the prefix of the keys of the versions of the topic's schema.
+// The topic is escaped so topics which share a prefix aren't confused.
+func versionPrefix(topic string) string {
+	return url.PathEscape(topic) + "/"
+}
+
+// versionKey is the key of a version of the topic's schema. The version is
+// padded so the versions are stored in order.
+func versionKey(topic string, version int) string {
+	return fmt.Sprintf("%s%010d", versionPrefix(topic), version)
+}
+
+// Register a new version of the schema of a topic, returning the version. Registering
+// the latest version again returns its version. An error wrapping ErrIncompatible is
+// returned if it isn't compatible with the latest version.
+func (r *Registry) Register(s *Schema) (int, error) {
+	if err := Check(s); err != nil {
+		return 0, err
+	}
+
+	for {
+		latest, err := r.Latest(s.Topic)
+		if err != nil && err != ErrNotFound {
+			return 0, err
+		}
+
+		next := *s
+		next.Version = 1
+		if latest != nil {
+			if latest.Format == s.Format && latest.Message == s.Message && bytes.Equal(latest.Definition, s.Definition) {
+				return latest.Version, nil
+			}
+			if err := Compatible(latest, s, r.opts.Compatibility); err != nil {
+				return 0, err
+			}
+			next.Version = latest.Version + 1
+		}
+		next.Created = time.Now()
+
+		b, err := json.Marshal(&next)
+		if err != nil {
+			return 0, err
+		}
+
+		// only write the version if nobody else has registered it since
+		rec := &store.Record{Key: versionKey(s.Topic, next.Version), Value: b}
+		table := store.WriteTo(r.opts.Database, r.opts.Table)
+		err = r.opts.store().Write(rec, table, store.WriteIfNotExists())
+		if err == store.ErrConditionNotSupported {
+			err = r.opts.store().Write(rec, table)
+		}
+
+		switch err {
+		case nil:
+			return next.Version, nil
+		case store.ErrExists:
+			// check against the version registered meanwhile
+			continue
+		default:
+			return 0, err
+		}
+	}
+}
+
+// Latest returns the latest version of the schema of the topic
+func (r *Registry) Latest(topic string) (*Schema, error) {
+	recs, err := r.opts.store().Read(versionPrefix(topic),
+		store.ReadFrom(r.opts.Database, r.opts.Table),
+		store.ReadPrefix(),
+		store.ReadOrder(store.OrderDesc),
+		store.ReadLimit(1),
+	)
+	if err == store.ErrRangeNotSupported {
+		// read every version to find the latest
+		recs, err = r.opts.store().Read(versionPrefix(topic),
+			store.ReadFrom(r.opts.Database, r.opts.Table),
+			store.ReadPrefix(),
+		)
+		if len(recs) > 0 {
+			recs = recs[len(recs)-1:]
+		}
+	}
+	if err == store.ErrNotFound || (err == nil && len(recs) == 0) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return unmarshalSchema(recs[0])
+}
+
+// Get a version of the schema of the topic
+func (r *Registry) Get(topic string, version int) (*Schema, error) {
+	recs, err := r.opts.store().Read(versionKey(topic, version), store.ReadFrom(r.opts.Database, r.opts.Table))
+	if err == store.ErrNotFound || (err == nil && len(recs) == 0) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return unmarshalSchema(recs[0])
+}
+
+// Versions returns every version of the schema of the topic, oldest first
+func (r *Registry) Versions(topic string) ([]*Schema, error) {
+	recs, err := r.opts.store().Read(versionPrefix(topic),
+		store.ReadFrom(r.opts.Database, r.opts.Table),
+		store.ReadPrefix(),
+	)
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+
+	schemas := make([]*Schema, 0, len(recs))
+	for _, rec := range recs {
+		s, err := unmarshalSchema(rec)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, nil
+}
+
+// Validate the payload, encoded with the content type, against the latest version of
+// the schema of the topic, returning the version. ErrNotFound is returned if the topic
+// has no schema.
+func (r *Registry) Validate(topic, contentType string, payload []byte) (int, error) {
+	s, err := r.Latest(topic)
+	if err != nil {
+		return 0, err
+	}
+
+	// versions don't change once registered, so their definitions are only parsed once
+	key := versionKey(topic, s.Version)
+	r.RLock()
+	d, ok := r.parsed[key]
+	r.RUnlock()
+	if !ok {
+		if d, err = parse(s); err != nil {
+			return 0, err
+		}
+		r.Lock()
+		r.parsed[key] = d
+		r.Unlock()
+	}
+
+	return s.Version, d.validate(contentType, payload)
+}
+
+func unmarshalSchema(rec *store.Record) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(rec.Value, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
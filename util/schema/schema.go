@@ -0,0 +1,165 @@
+// Package schema is a registry of the schemas of the payloads published to topics
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a topic has no schema
+	ErrNotFound = errors.New("schema not found")
+	// ErrInvalidSchema is returned when a schema definition can't be parsed
+	ErrInvalidSchema = errors.New("invalid schema")
+	// ErrIncompatible is returned when a schema isn't compatible with the previous version
+	ErrIncompatible = errors.New("schema incompatible")
+	// ErrInvalidPayload is returned when a payload doesn't match the schema of its topic
+	ErrInvalidPayload = errors.New("payload doesn't match schema")
+	// ErrUnsupportedFormat is returned for a schema format which isn't supported
+	ErrUnsupportedFormat = errors.New("unsupported schema format")
+	// ErrUnsupportedContentType is returned when a payload can't be validated against a schema
+	// because of its content type, such as a protobuf payload with a JSON Schema
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+)
+
+// VersionKey is the header or metadata key the version of the schema a payload was
+// validated against is recorded under
+const VersionKey = "Micro-Schema-Version"
+
+// Format of a schema definition
+type Format string
+
+const (
+	// JSONSchema definitions are JSON Schema documents. The type, properties, required,
+	// additionalProperties, items, enum and bounds keywords are supported.
+	JSONSchema Format = "jsonschema"
+	// Protobuf definitions are serialized FileDescriptorSets, with the Message naming
+	// the message type of the payloads
+	Protobuf Format = "protobuf"
+)
+
+// Compatibility between the versions of the schema of a topic
+type Compatibility int
+
+const (
+	// Backward compatible schemas can read payloads written with the previous version,
+	// so consumers can be upgraded before producers
+	Backward Compatibility = iota
+	// Forward compatible schemas write payloads which can be read with the previous
+	// version, so producers can be upgraded before consumers
+	Forward
+	// Full compatibility is both backward and forward
+	Full
+	// None doesn't check the compatibility of new versions
+	None
+)
+
+// String returns human readable compatibility
+func (c Compatibility) String() string {
+	switch c {
+	case Backward:
+		return "backward"
+	case Forward:
+		return "forward"
+	case Full:
+		return "full"
+	case None:
+		return "none"
+	default:
+		return "unknown"
+	}
+}
+
+// Schema of the payloads published to a topic
+type Schema struct {
+	// Topic the schema applies to
+	Topic string
+	// Version of the schema, set when it's registered
+	Version int
+	// Format of the definition
+	Format Format
+	// Definition of the schema
+	Definition []byte
+	// Message is the full name of the message type, for protobuf definitions
+	Message string
+	// Created is the time the version was registered
+	Created time.Time
+}
+
+// format parses, validates and compares the definitions of a format
+type format interface {
+	// parse the definition of the schema
+	parse(s *Schema) (definition, error)
+}
+
+// definition is a parsed schema
+type definition interface {
+	// validate the payload, encoded with the content type
+	validate(contentType string, payload []byte) error
+	// canRead returns an error if payloads written with the
+	// definition given can't be read with this one
+	canRead(writer definition) error
+}
+
+var formats = map[Format]format{
+	JSONSchema: jsonFormat{},
+	Protobuf:   protoFormat{},
+}
+
+// parse the definition of a schema
+func parse(s *Schema) (definition, error) {
+	f, ok := formats[s.Format]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+	return f.parse(s)
+}
+
+// Check returns an error if the definition of the schema is invalid
+func Check(s *Schema) error {
+	_, err := parse(s)
+	return err
+}
+
+// Compatible returns an error wrapping ErrIncompatible if the new version of a
+// schema isn't compatible with the previous one
+func Compatible(prev, next *Schema, c Compatibility) error {
+	if c == None {
+		return nil
+	}
+	if prev.Format != next.Format {
+		return fmt.Errorf("%w: format changed from %v to %v", ErrIncompatible, prev.Format, next.Format)
+	}
+
+	p, err := parse(prev)
+	if err != nil {
+		return err
+	}
+	n, err := parse(next)
+	if err != nil {
+		return err
+	}
+
+	if c == Backward || c == Full {
+		if err := n.canRead(p); err != nil {
+			return fmt.Errorf("%w: not backward compatible: %v", ErrIncompatible, err)
+		}
+	}
+	if c == Forward || c == Full {
+		if err := p.canRead(n); err != nil {
+			return fmt.Errorf("%w: not forward compatible: %v", ErrIncompatible, err)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error wrapping ErrInvalidPayload if the payload, encoded with
+// the content type, doesn't match the schema
+func Validate(s *Schema, contentType string, payload []byte) error {
+	d, err := parse(s)
+	if err != nil {
+		return err
+	}
+	return d.validate(contentType, payload)
+}
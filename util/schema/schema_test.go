@@ -0,0 +1,282 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/micro/go-micro/v3/broker"
+	bmemory "github.com/micro/go-micro/v3/broker/memory"
+	pb "github.com/micro/go-micro/v3/errors/proto"
+	"github.com/micro/go-micro/v3/events"
+	smemory "github.com/micro/go-micro/v3/events/stream/memory"
+	"github.com/micro/go-micro/v3/store/memory"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const orderV1 = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "string", "minLength": 1},
+		"amount": {"type": "integer", "minimum": 0},
+		"status": {"enum": ["new", "paid"]}
+	},
+	"required": ["id", "amount"]
+}`
+
+func newJSONSchema(topic, def string) *Schema {
+	return &Schema{Topic: topic, Format: JSONSchema, Definition: []byte(def)}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(Store(memory.NewStore()))
+
+	if _, err := r.Latest("orders"); err != ErrNotFound {
+		t.Fatalf("Expected %v, got %v", ErrNotFound, err)
+	}
+
+	v, err := r.Register(newJSONSchema("orders", orderV1))
+	if err != nil || v != 1 {
+		t.Fatalf("Expected version 1, got %v %v", v, err)
+	}
+
+	// registering the same schema again is a no-op
+	if v, err := r.Register(newJSONSchema("orders", orderV1)); err != nil || v != 1 {
+		t.Fatalf("Expected version 1, got %v %v", v, err)
+	}
+
+	// adding an optional property is backward compatible
+	v2 := `{"type": "object", "properties": {"id": {"type": "string", "minLength": 1}, "amount": {"type": "integer", "minimum": 0},
+		"status": {"enum": ["new", "paid"]}, "note": {"type": "string"}}, "required": ["id", "amount"]}`
+	if v, err := r.Register(newJSONSchema("orders", v2)); err != nil || v != 2 {
+		t.Fatalf("Expected version 2, got %v %v", v, err)
+	}
+
+	incompatible := []string{
+		// a new required property
+		`{"type": "object", "properties": {"id": {"type": "string"}, "amount": {"type": "integer"}, "currency": {"type": "string"}},
+			"required": ["id", "amount", "currency"]}`,
+		// a changed type
+		`{"type": "object", "properties": {"id": {"type": "integer"}, "amount": {"type": "integer"}}, "required": ["id", "amount"]}`,
+		// a removed value
+		`{"type": "object", "properties": {"id": {"type": "string"}, "amount": {"type": "integer"}, "status": {"enum": ["new"]}},
+			"required": ["id", "amount"]}`,
+		// a narrowed range
+		`{"type": "object", "properties": {"id": {"type": "string"}, "amount": {"type": "integer", "minimum": 10}}, "required": ["id", "amount"]}`,
+	}
+	for i, def := range incompatible {
+		if _, err := r.Register(newJSONSchema("orders", def)); !errors.Is(err, ErrIncompatible) {
+			t.Fatalf("Expected schema %v to be incompatible, got %v", i, err)
+		}
+	}
+
+	if _, err := r.Register(newJSONSchema("orders", `{"type": `)); !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("Expected %v, got %v", ErrInvalidSchema, err)
+	}
+
+	versions, err := r.Versions("orders")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %v %v", len(versions), err)
+	}
+	if s, err := r.Get("orders", 1); err != nil || string(s.Definition) != orderV1 {
+		t.Fatalf("Expected version 1, got %v", err)
+	}
+
+	valid := []string{
+		`{"id": "1", "amount": 10}`,
+		`{"id": "1", "amount": 0, "status": "paid", "note": "thanks"}`,
+	}
+	for _, p := range valid {
+		if v, err := r.Validate("orders", "application/json", []byte(p)); err != nil || v != 2 {
+			t.Fatalf("Expected %v to be valid against version 2, got %v %v", p, v, err)
+		}
+	}
+
+	invalid := []string{
+		`{"id": "1"}`,
+		`{"id": "", "amount": 1}`,
+		`{"id": "1", "amount": 1.5}`,
+		`{"id": "1", "amount": -1}`,
+		`{"id": "1", "amount": 1, "status": "lost"}`,
+		`[]`,
+		`{`,
+	}
+	for _, p := range invalid {
+		if _, err := r.Validate("orders", "application/json", []byte(p)); !errors.Is(err, ErrInvalidPayload) {
+			t.Fatalf("Expected %v to be invalid, got %v", p, err)
+		}
+	}
+
+	if _, err := r.Validate("orders", "application/protobuf", []byte(valid[0])); err != ErrUnsupportedContentType {
+		t.Fatalf("Expected %v, got %v", ErrUnsupportedContentType, err)
+	}
+}
+
+func TestCompatibility(t *testing.T) {
+	prev := newJSONSchema("orders", `{"type": "object", "properties": {"id": {"type": "string"}}, "required": ["id"]}`)
+	// drops the required id, so consumers of the previous version can't read it
+	next := newJSONSchema("orders", `{"type": "object", "properties": {"id": {"type": "string"}}}`)
+
+	if err := Compatible(prev, next, Backward); err != nil {
+		t.Fatalf("Expected backward compatibility, got %v", err)
+	}
+	if err := Compatible(prev, next, Forward); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("Expected forward incompatibility, got %v", err)
+	}
+	if err := Compatible(prev, next, Full); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("Expected full incompatibility, got %v", err)
+	}
+	if err := Compatible(prev, next, None); err != nil {
+		t.Fatalf("Expected no check, got %v", err)
+	}
+}
+
+// protoSchemaOf returns a schema for a message with the fields given
+func protoSchemaOf(fields ...*descriptorpb.FieldDescriptorProto) *Schema {
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("order.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:  proto.String("Order"),
+			Field: fields,
+		}},
+	}}}
+	b, _ := proto.Marshal(set)
+	return &Schema{Topic: "orders", Format: Protobuf, Definition: b, Message: "test.Order"}
+}
+
+func field(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(num), Type: typ.Enum(), Label: label.Enum()}
+}
+
+func TestProtobuf(t *testing.T) {
+	const (
+		str      = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i64      = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		required = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	)
+
+	prev := protoSchemaOf(field("id", 1, str, required), field("amount", 2, i64, optional))
+
+	tests := []struct {
+		name       string
+		next       *Schema
+		compatible bool
+	}{
+		{"added optional field", protoSchemaOf(field("id", 1, str, required), field("amount", 2, i64, optional), field("note", 3, str, optional)), true},
+		{"removed optional field", protoSchemaOf(field("id", 1, str, required)), true},
+		{"renamed field", protoSchemaOf(field("id", 1, str, required), field("total", 2, i64, optional)), true},
+		{"added required field", protoSchemaOf(field("id", 1, str, required), field("amount", 2, i64, optional), field("note", 3, str, required)), false},
+		{"changed type", protoSchemaOf(field("id", 1, str, required), field("amount", 2, str, optional)), false},
+		{"made repeated", protoSchemaOf(field("id", 1, str, required), field("amount", 2, i64, repeated)), false},
+	}
+	for _, tt := range tests {
+		err := Compatible(prev, tt.next, Backward)
+		if tt.compatible && err != nil {
+			t.Fatalf("Expected %v to be compatible, got %v", tt.name, err)
+		}
+		if !tt.compatible && !errors.Is(err, ErrIncompatible) {
+			t.Fatalf("Expected %v to be incompatible, got %v", tt.name, err)
+		}
+	}
+
+	// schemas can be taken from generated messages
+	s, err := NewProtoSchema("errors", &pb.Error{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := proto.Marshal(&pb.Error{Id: "foo", Code: 500})
+	if err := Validate(s, "application/protobuf", b); err != nil {
+		t.Fatalf("Expected the payload to be valid, got %v", err)
+	}
+	if err := Validate(s, "application/json", []byte(`{"id": "foo", "code": 500}`)); err != nil {
+		t.Fatalf("Expected the JSON payload to be valid, got %v", err)
+	}
+	if err := Validate(s, "application/json", []byte(`{"id": 1}`)); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("Expected the JSON payload to be invalid, got %v", err)
+	}
+	if err := Validate(s, "application/protobuf", []byte{0xff}); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("Expected the payload to be invalid, got %v", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	r := NewRegistry(Store(memory.NewStore()))
+	if _, err := r.Register(newJSONSchema("orders", orderV1)); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := smemory.NewStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStream(ms, r)
+
+	ch, err := s.Subscribe("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Publish("orders", map[string]interface{}{"id": "1"}); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("Expected the invalid event to be rejected, got %v", err)
+	}
+	if err := s.Publish("orders", map[string]interface{}{"id": "1", "amount": 5}); err != nil {
+		t.Fatal(err)
+	}
+	// topics without a schema aren't validated
+	if err := s.Publish("other", map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-ch:
+		if ev.Metadata[VersionKey] != "1" {
+			t.Fatalf("Expected schema version 1, got %v", ev.Metadata[VersionKey])
+		}
+		if ev.Metadata[events.CodecKey] != "json" || ev.Metadata[events.ContentTypeKey] != "application/json" {
+			t.Fatalf("Expected the codec to be recorded, got %v", ev.Metadata)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Event was not received")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	r := NewRegistry(Store(memory.NewStore()))
+	if _, err := r.Register(newJSONSchema("orders", orderV1)); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBroker(bmemory.NewBroker(), r)
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	var received []*broker.Message
+	if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+		received = append(received, m)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	header := map[string]string{"Content-Type": "application/json"}
+	if err := b.Publish("orders", &broker.Message{Header: header, Body: []byte(`{"id": "1"}`)}); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("Expected the invalid message to be rejected, got %v", err)
+	}
+	if err := b.Publish("orders", &broker.Message{Header: header, Body: []byte(`{"id": "1", "amount": 5}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 1 || received[0].Header[VersionKey] != "1" {
+		t.Fatalf("Expected one message validated against version 1, got %v", received)
+	}
+	if _, ok := header[VersionKey]; ok {
+		t.Fatal("Expected the header of the message published not to be modified")
+	}
+}
@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"strconv"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/codec/json"
+	"github.com/micro/go-micro/v3/events"
+)
+
+type schemaStream struct {
+	events.Stream
+	registry *Registry
+}
+
+// NewStream returns a stream which validates the payloads of the events published
+// against the latest schema of their topic, recording the version in their metadata.
+// Events published to topics without a schema aren't validated.
+func NewStream(s events.Stream, r *Registry) events.Stream {
+	return &schemaStream{Stream: s, registry: r}
+}
+
+func (s *schemaStream) Publish(topic string, msg interface{}, opts ...events.PublishOption) error {
+	var options events.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Codec == nil {
+		options.Codec = json.Marshaler{}
+	}
+
+	// encode the message to validate it
+	payload, err := events.Encode(msg, &options)
+	if err != nil {
+		return err
+	}
+
+	version, err := s.registry.Validate(topic, options.Metadata[events.ContentTypeKey], payload)
+	switch err {
+	case nil:
+		options.Metadata[VersionKey] = strconv.Itoa(version)
+	case ErrNotFound:
+	default:
+		return err
+	}
+
+	return s.Stream.Publish(topic, payload, append(opts,
+		events.WithCodec(options.Codec),
+		events.WithMetadata(options.Metadata),
+	)...)
+}
+
+type schemaBroker struct {
+	broker.Broker
+	registry *Registry
+}
+
+// NewBroker returns a broker which validates the bodies of the messages published
+// against the latest schema of their topic, using their Content-Type header, and
+// records the version in their header. Messages published to topics without a
+// schema aren't validated.
+func NewBroker(b broker.Broker, r *Registry) broker.Broker {
+	return &schemaBroker{Broker: b, registry: r}
+}
+
+func (b *schemaBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	version, err := b.registry.Validate(topic, msg.Header["Content-Type"], msg.Body)
+	switch err {
+	case nil:
+	case ErrNotFound:
+		return b.Broker.Publish(topic, msg, opts...)
+	default:
+		return err
+	}
+
+	// copy the message so the caller's header isn't modified
+	m := &broker.Message{Header: make(map[string]string, len(msg.Header)+1), Body: msg.Body}
+	for k, v := range msg.Header {
+		m.Header[k] = v
+	}
+	m.Header[VersionKey] = strconv.Itoa(version)
+
+	return b.Broker.Publish(topic, m, opts...)
+}